@@ -0,0 +1,251 @@
+// Command admin runs maintenance tasks against the database the server uses.
+// It reads the same config as the server.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/vdavid/web-annotator/backend/internal/config"
+	"github.com/vdavid/web-annotator/backend/internal/db"
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/url"
+)
+
+const usage = `Usage: admin [-config file] <command> [flags]
+
+Commands:
+  recompute-stats [-dry-run]  Recompute every page's stored stats from its ratings and report drift.
+                              With -dry-run, only report, and exit with status 1 if there's any drift.
+  rehash-pages [-dry-run]     Normalize every page's URL again with the current URL rules, and move pages whose
+                              URL changed. Pages that end up with the same URL are merged, keeping each user's
+                              newest rating. With -dry-run, only report.
+  merge-pages <into> <from>...
+                              Merge the pages of the <from> URLs into the page of <into>. Each user keeps their
+                              newest rating, and the <from> URLs become aliases of <into>.
+  merge-canonical [-dry-run]  Merge every fetched page into the page of its <link rel=canonical>, like AMP, mobile,
+                              and print versions into the article. Only within a site, so amp.example.com can merge
+                              into example.com, but other-site.com can't. With -dry-run, only report.
+`
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+// errDriftFound makes a dry run exit with a non-zero status, so it can be used as a periodic check.
+var errDriftFound = errors.New("found pages with drifted stats")
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("admin", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(flags.Output(), usage) }
+	configFile := flags.String("config", "", "path to an optional YAML or TOML config file (default: $CONFIG_FILE)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Pages.URLRulesFile != "" {
+		rules, err := url.LoadRulesFile(cfg.Pages.URLRulesFile)
+		if err != nil {
+			return err
+		}
+		url.SetRules(rules)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pool, err := db.NewPool(ctx, cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	command, commandArgs := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "recompute-stats":
+		return recomputeStats(ctx, repository.NewPagesRepository(pool), commandArgs)
+	case "rehash-pages":
+		return rehashPages(ctx, repository.NewPagesRepository(pool), commandArgs)
+	case "merge-pages":
+		return mergePages(ctx, repository.NewPagesRepository(pool), commandArgs)
+	case "merge-canonical":
+		return mergeCanonical(ctx, repository.NewPagesRepository(pool), commandArgs)
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// recomputeStats fixes the stored stats on pages and prints what was wrong.
+func recomputeStats(ctx context.Context, pagesRepo *repository.PagesRepository, args []string) error {
+	flags := flag.NewFlagSet("recompute-stats", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only report drift, don't fix it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	drift, err := pagesRepo.RecomputePageStats(ctx, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, page := range drift {
+		fmt.Printf("Page %d (%s): count %d -> %d, sum %d -> %d, histogram %v -> %v\n",
+			page.PageID, page.NormalizedURL,
+			page.StoredRatingCount, page.ActualRatingCount,
+			page.StoredScoreSum, page.ActualScoreSum,
+			page.StoredHistogram, page.ActualHistogram)
+	}
+
+	switch {
+	case len(drift) == 0:
+		fmt.Println("All page stats are correct.")
+	case *dryRun:
+		fmt.Printf("%d page(s) have drifted stats. Run without -dry-run to fix them.\n", len(drift))
+		return errDriftFound
+	default:
+		fmt.Printf("Fixed the stats of %d page(s).\n", len(drift))
+	}
+	return nil
+}
+
+// rehashPages moves pages to the hashes of their URLs under the current rules and prints what moved.
+func rehashPages(ctx context.Context, pagesRepo *repository.PagesRepository, args []string) error {
+	flags := flag.NewFlagSet("rehash-pages", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only report what would change, don't change it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	fmt.Printf("Using URL rules version %d.\n", url.CurrentRules().Version())
+	changes, err := pagesRepo.RehashPages(ctx, url.Normalize, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	merged := 0
+	for _, change := range changes {
+		if change.MergedInto != nil {
+			merged++
+			fmt.Printf("Page %d: %s -> %s, merged into page %d\n", change.PageID, change.OldURL, change.NewURL, *change.MergedInto)
+		} else {
+			fmt.Printf("Page %d: %s -> %s\n", change.PageID, change.OldURL, change.NewURL)
+		}
+	}
+
+	switch {
+	case len(changes) == 0:
+		fmt.Println("All pages are up to date.")
+	case *dryRun:
+		fmt.Printf("%d page(s) would move, %d of them by merging. Run without -dry-run to move them.\n", len(changes), merged)
+	default:
+		fmt.Printf("Moved %d page(s), %d of them by merging. Servers may show cached stats for up to PAGE_STATS_CACHE_TTL.\n", len(changes), merged)
+	}
+	return nil
+}
+
+// mergePages merges the pages of the given URLs into the page of the first one.
+func mergePages(ctx context.Context, pagesRepo *repository.PagesRepository, args []string) error {
+	if len(args) < 2 {
+		return errors.New("merge-pages needs the URL to merge into and at least one URL to merge")
+	}
+	intoURL, err := url.Normalize(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", args[0], err)
+	}
+
+	for _, rawURL := range args[1:] {
+		fromURL, err := url.Normalize(rawURL)
+		if err != nil {
+			return fmt.Errorf("invalid URL %q: %w", rawURL, err)
+		}
+		fromID, intoID, err := pagesRepo.MergePages(ctx, fromURL, intoURL)
+		if errors.Is(err, repository.ErrPageNotFound) {
+			fmt.Printf("Skipped %s: there's no page for it.\n", fromURL)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if fromID == intoID {
+			fmt.Printf("Skipped %s: it's already page %d.\n", fromURL, intoID)
+			continue
+		}
+		fmt.Printf("Merged page %d (%s) into page %d (%s).\n", fromID, fromURL, intoID, intoURL)
+	}
+	return nil
+}
+
+// mergeCanonical merges pages into the pages of their canonical URLs, within the same site.
+func mergeCanonical(ctx context.Context, pagesRepo *repository.PagesRepository, args []string) error {
+	flags := flag.NewFlagSet("merge-canonical", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only report what would be merged, don't merge it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	canonicals, err := pagesRepo.ListCanonicalURLs(ctx)
+	if err != nil {
+		return err
+	}
+
+	merged, skipped := 0, 0
+	for _, page := range canonicals {
+		canonicalURL, err := url.Normalize(page.CanonicalURL)
+		if err != nil || canonicalURL == page.NormalizedURL {
+			continue
+		}
+		// A page can claim any canonical URL, so one from another site could take over that site's ratings
+		if !sameSite(url.Domain(page.NormalizedURL), url.Domain(canonicalURL)) {
+			skipped++
+			fmt.Printf("Skipped page %d (%s): its canonical URL %s is on another site.\n", page.PageID, page.NormalizedURL, canonicalURL)
+			continue
+		}
+
+		merged++
+		if *dryRun {
+			fmt.Printf("Page %d (%s) would merge into %s.\n", page.PageID, page.NormalizedURL, canonicalURL)
+			continue
+		}
+		_, intoID, err := pagesRepo.MergePages(ctx, page.NormalizedURL, canonicalURL)
+		if err != nil {
+			return fmt.Errorf("failed to merge page %d: %w", page.PageID, err)
+		}
+		fmt.Printf("Merged page %d (%s) into page %d (%s).\n", page.PageID, page.NormalizedURL, intoID, canonicalURL)
+	}
+
+	switch {
+	case *dryRun:
+		fmt.Printf("%d page(s) would merge. Run without -dry-run to merge them.\n", merged)
+	default:
+		fmt.Printf("Merged %d page(s).\n", merged)
+	}
+	if skipped > 0 {
+		fmt.Printf("Skipped %d page(s) with a canonical URL on another site. If they're syndicated copies, merge them with merge-pages.\n", skipped)
+	}
+	return nil
+}
+
+// sameSite reports whether two hosts are the same, or one is a subdomain of the other, like amp.example.com and
+// example.com.
+func sameSite(a string, b string) bool {
+	return a == b || strings.HasSuffix(a, "."+b) || strings.HasSuffix(b, "."+a)
+}
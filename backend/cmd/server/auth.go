@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vdavid/web-annotator/backend/internal/config"
+	"github.com/vdavid/web-annotator/backend/internal/middleware"
+)
+
+// userStore is what the authenticators need to store users.
+type userStore interface {
+	middleware.ExternalUserStore
+	middleware.UserStore
+}
+
+// newAuthenticator builds the authenticator for the configured auth mode.
+func newAuthenticator(ctx context.Context, cfg config.AuthConfig, users userStore) (middleware.Authenticator, error) {
+	switch cfg.Mode {
+	case config.AuthModeHeader:
+		return middleware.HeaderAuthenticator{}, nil
+	case config.AuthModeProxy:
+		trustedProxies, err := middleware.ParseTrustedProxies(cfg.ProxyTrustedCIDRs)
+		if err != nil {
+			return nil, err
+		}
+		return middleware.NewProxyAuthenticator(middleware.ProxyOptions{
+			TrustedProxies: trustedProxies,
+			AllowedGroups:  cfg.ProxyAllowedGroups,
+		}, users)
+	case config.AuthModeJWT:
+		options := middleware.JWTOptions{
+			HMACSecret: []byte(cfg.JWTSecret),
+			Issuer:     cfg.JWTIssuer,
+			Audience:   cfg.JWTAudience,
+			Leeway:     cfg.JWTLeeway,
+			Users:      users,
+		}
+		switch {
+		case cfg.JWKSFile != "":
+			keys, err := middleware.LoadJWKSFile(cfg.JWKSFile)
+			if err != nil {
+				return nil, err
+			}
+			options.Keys = keys
+		case cfg.JWKSURL != "":
+			keys, err := middleware.NewRemoteKeySet(ctx, cfg.JWKSURL, nil)
+			if err != nil {
+				return nil, err
+			}
+			options.Keys = keys
+		}
+		return middleware.NewJWTAuthenticator(options)
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q", cfg.Mode)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/vdavid/web-annotator/backend/internal/article"
+	"github.com/vdavid/web-annotator/backend/internal/cache"
+	"github.com/vdavid/web-annotator/backend/internal/config"
+	"github.com/vdavid/web-annotator/backend/internal/db"
+	"github.com/vdavid/web-annotator/backend/internal/live"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/scoring"
+	"github.com/vdavid/web-annotator/backend/internal/url"
+	"github.com/vdavid/web-annotator/backend/internal/webhooks"
+	"github.com/vdavid/web-annotator/backend/migrations"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+}
+
+// run wires up the dependencies, starts the HTTP server, and blocks until it's stopped.
+func run() error {
+	configFile := flag.String("config", "", "path to an optional YAML or TOML config file (default: $CONFIG_FILE)")
+	printConfig := flag.Bool("print-config", false, "print the effective config with secrets redacted, then exit")
+	migrateOnly := flag.Bool("migrate-only", false, "apply pending migrations, then exit")
+	migrateDown := flag.Int("migrate-down", 0, "roll back this many migrations, then exit")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		return err
+	}
+	if *printConfig {
+		fmt.Print(cfg.Redacted())
+		return nil
+	}
+
+	if cfg.Pages.URLRulesFile != "" {
+		rules, err := url.LoadRulesFile(cfg.Pages.URLRulesFile)
+		if err != nil {
+			return err
+		}
+		url.SetRules(rules)
+	}
+	log.Printf("Using URL rules version %d", url.CurrentRules().Version())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pool, err := db.NewPool(ctx, cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	migrator, err := db.NewMigrator(pool, migrations.FS)
+	if err != nil {
+		return err
+	}
+	if *migrateDown > 0 {
+		rolledBack, err := migrator.Down(ctx, *migrateDown)
+		log.Printf("Rolled back %d migration(s)", rolledBack)
+		return err
+	}
+	if *migrateOnly || cfg.Database.AutoMigrate {
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("Applied %d pending migration(s)", applied)
+	}
+	if *migrateOnly {
+		return nil
+	}
+
+	pagesRepo := repository.NewPagesRepository(pool)
+	ratingsRepo := repository.NewRatingsRepository(pool)
+	usersRepo := repository.NewUsersRepository(pool)
+	tokensRepo := repository.NewTokensRepository(pool)
+	webhooksRepo := repository.NewWebhooksRepository(pool)
+
+	authenticator, err := newAuthenticator(ctx, cfg.Auth, usersRepo)
+	if err != nil {
+		return err
+	}
+	if cfg.Auth.Mode == config.AuthModeHeader {
+		log.Println("WARNING: AUTH_MODE=header trusts X-User-ID as is. Only use it for development.")
+	}
+
+	var pages repository.PagesRepositoryInterface = pagesRepo
+	var checkCache *repository.PageCheckCache
+	if cfg.Cache.Enabled {
+		checkCache = cache.NewLRU[string, models.PageCheck](cfg.Cache.Size, cfg.Cache.TTL)
+		pages = repository.NewCachedPagesRepository(pagesRepo, checkCache)
+	}
+
+	priors := scoring.NewPriors(pagesRepo, scoring.Options{
+		Weight:    cfg.Ratings.PriorWeight,
+		PerDomain: cfg.Ratings.PriorPerDomain,
+		Fallback:  float64(cfg.Ratings.MinScore+cfg.Ratings.MaxScore) / 2,
+	})
+	go priors.Run(ctx, cfg.Ratings.PriorRefreshInterval)
+
+	var hub *live.Hub
+	if cfg.Stream.Backend == config.StreamBackendPostgres {
+		transport := live.NewPostgresTransport(pool)
+		hub = live.NewHub(transport)
+		go transport.Listen(ctx, hub.Deliver)
+	} else {
+		hub = live.NewHub(nil)
+	}
+
+	if cfg.Webhooks.Enabled {
+		worker := webhooks.NewWorker(webhooksRepo, webhooks.Options{
+			Timeout:       cfg.Webhooks.Timeout,
+			MaxAttempts:   cfg.Webhooks.MaxAttempts,
+			RetryDelay:    cfg.Webhooks.RetryDelay,
+			MaxRetryDelay: cfg.Webhooks.MaxRetryDelay,
+			Retention:     cfg.Webhooks.Retention,
+		})
+		go worker.Run(ctx, cfg.Webhooks.PollInterval)
+	}
+
+	// An interface, so it stays a true nil when fetching is off
+	var fetcher article.PageFetcher
+	if cfg.Fetch.Enabled {
+		fetcher = article.NewFetcher(article.FetcherOptions{
+			Timeout:  cfg.Fetch.Timeout,
+			MaxBytes: int64(cfg.Fetch.MaxBytes),
+		})
+		worker := article.NewWorker(pagesRepo, fetcher, article.WorkerOptions{
+			Timeout:     cfg.Fetch.Timeout,
+			MaxAttempts: cfg.Fetch.MaxAttempts,
+			RetryDelay:  cfg.Fetch.RetryDelay,
+		})
+		go worker.Run(ctx, cfg.Fetch.PollInterval)
+	}
+
+	// Same here, for resolving redirects
+	var resolver, cachedResolver url.Resolver
+	if cfg.Pages.ResolveRedirects {
+		redirectResolver := article.NewRedirectResolver(repository.NewRedirectsRepository(pool), cfg.Pages.ResolveTimeout)
+		go redirectResolver.Run(ctx, cfg.Pages.ResolveTTL)
+		resolver, cachedResolver = redirectResolver, redirectResolver.Cached()
+	}
+
+	router := newRouter(cfg, authenticator, pages, ratingsRepo, usersRepo, tokensRepo, webhooksRepo, priors, checkCache, hub, fetcher, resolver, cachedResolver)
+
+	server := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Port),
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	// Open streams never finish on their own, so end them when shutting down
+	server.RegisterOnShutdown(hub.Close)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down, draining in-flight requests...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	log.Println("Server stopped")
+
+	return nil
+}
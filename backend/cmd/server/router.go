@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/vdavid/web-annotator/backend/internal/api"
+	"github.com/vdavid/web-annotator/backend/internal/article"
+	"github.com/vdavid/web-annotator/backend/internal/config"
+	"github.com/vdavid/web-annotator/backend/internal/live"
+	"github.com/vdavid/web-annotator/backend/internal/middleware"
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/scoring"
+	"github.com/vdavid/web-annotator/backend/internal/url"
+)
+
+// newRouter builds the HTTP handler with all routes and middleware attached.
+// /ping and /debug/cache are public; everything under /api/v1 requires an authenticated user.
+// Personal API tokens need the matching scope, and can't manage tokens or webhooks.
+func newRouter(
+	cfg *config.Config,
+	authenticator middleware.Authenticator,
+	pagesRepo repository.PagesRepositoryInterface,
+	ratingsRepo repository.RatingsRepositoryInterface,
+	usersRepo repository.UsersRepositoryInterface,
+	tokensRepo repository.TokensRepositoryInterface,
+	webhooksRepo repository.WebhooksRepositoryInterface,
+	priors *scoring.Priors,
+	checkCache *repository.PageCheckCache,
+	hub *live.Hub,
+	fetcher article.PageFetcher,
+	resolver url.Resolver,
+	cachedResolver url.Resolver,
+) http.Handler {
+	classifier := article.NewClassifier(article.Rules{
+		AllowDomains:    cfg.Articles.AllowDomains,
+		DenyDomains:     cfg.Articles.DenyDomains,
+		RequireVerified: cfg.Articles.RequireVerified,
+	})
+	pagesHandler := api.NewPagesHandler(pagesRepo, priors, hub, classifier, fetcher, resolver, cachedResolver, cfg.Pages.MetadataRefreshAfter)
+	ratingsHandler := api.NewRatingsHandler(pagesRepo, ratingsRepo, usersRepo, api.ScoreRange{
+		Min: cfg.Ratings.MinScore,
+		Max: cfg.Ratings.MaxScore,
+	}, priors, checkCache, hub, classifier, fetcher, resolver, cachedResolver, cfg.Pages.MetadataRefreshAfter)
+
+	tokensHandler := api.NewTokensHandler(tokensRepo, usersRepo)
+	webhooksHandler := api.NewWebhooksHandler(webhooksRepo, usersRepo)
+
+	auth := middleware.AuthMiddleware(authenticator, tokensRepo)
+	canRead := func(h http.HandlerFunc) http.Handler {
+		return auth(middleware.RequireScope(middleware.ScopeRatingsRead)(h))
+	}
+	canWrite := func(h http.HandlerFunc) http.Handler {
+		return auth(middleware.RequireScope(middleware.ScopeRatingsWrite)(h))
+	}
+	primaryOnly := func(h http.HandlerFunc) http.Handler {
+		return auth(middleware.RequirePrimaryAuth(h))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", ping)
+	mux.HandleFunc("GET /debug/cache", cacheStats(checkCache))
+	mux.Handle("PUT /api/v1/pages", canWrite(pagesHandler.Upsert))
+	mux.Handle("/api/v1/pages/check", canRead(pagesHandler.Check))
+	mux.Handle("POST /api/v1/pages/check:batch", canRead(pagesHandler.CheckBatch))
+	mux.Handle("GET /api/v1/pages/top", canRead(pagesHandler.Top))
+	mux.Handle("GET /api/v1/pages/stream", canRead(pagesHandler.Stream))
+	mux.Handle("POST /api/v1/ratings", canWrite(ratingsHandler.Submit))
+	mux.Handle("DELETE /api/v1/ratings", canWrite(ratingsHandler.Delete))
+	mux.Handle("GET /api/v1/ratings/history", canRead(ratingsHandler.History))
+	mux.Handle("GET /api/v1/tokens", primaryOnly(tokensHandler.List))
+	mux.Handle("POST /api/v1/tokens", primaryOnly(tokensHandler.Create))
+	mux.Handle("DELETE /api/v1/tokens", primaryOnly(tokensHandler.Revoke))
+	mux.Handle("GET /api/v1/webhooks", primaryOnly(webhooksHandler.List))
+	mux.Handle("POST /api/v1/webhooks", primaryOnly(webhooksHandler.Create))
+	mux.Handle("DELETE /api/v1/webhooks", primaryOnly(webhooksHandler.Delete))
+	mux.Handle("GET /api/v1/webhooks/dead-letters", primaryOnly(webhooksHandler.DeadLetters))
+
+	return middleware.CORSMiddleware(cfg.CORS.AllowedOrigins)(mux)
+}
+
+// ping handles GET /ping. It's a liveness check for Docker and for humans with curl.
+func ping(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	api.JSONResponse(w, http.StatusOK, map[string]string{"message": "pong"})
+}
+
+// cacheStats handles GET /debug/cache. It returns the page check cache's counters, all zero if caching is off.
+// Nothing else is exposed, since the route is public.
+func cacheStats(checkCache *repository.PageCheckCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		api.JSONResponse(w, http.StatusOK, checkCache.Stats())
+	}
+}
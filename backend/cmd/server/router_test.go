@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vdavid/web-annotator/backend/internal/config"
+	"github.com/vdavid/web-annotator/backend/internal/middleware"
+	"github.com/vdavid/web-annotator/backend/internal/scoring"
+)
+
+func TestRouter(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{
+			name:           "ping",
+			method:         http.MethodGet,
+			path:           "/ping",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "ping wrong method",
+			method:         http.MethodPost,
+			path:           "/ping",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "check requires auth",
+			method:         http.MethodGet,
+			path:           "/api/v1/pages/check?url=https://example.com/article",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "batch check requires auth",
+			method:         http.MethodPost,
+			path:           "/api/v1/pages/check:batch",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "batch check rejects GET",
+			method:         http.MethodGet,
+			path:           "/api/v1/pages/check:batch",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "page upsert requires auth",
+			method:         http.MethodPut,
+			path:           "/api/v1/pages",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "stream requires auth",
+			method:         http.MethodGet,
+			path:           "/api/v1/pages/stream?url=https://example.com/article",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "ratings requires auth",
+			method:         http.MethodPost,
+			path:           "/api/v1/ratings",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "deleting a rating requires auth",
+			method:         http.MethodDelete,
+			path:           "/api/v1/ratings?url=https://example.com/article",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "ratings rejects other methods",
+			method:         http.MethodPut,
+			path:           "/api/v1/ratings",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "webhooks require auth",
+			method:         http.MethodPost,
+			path:           "/api/v1/webhooks",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "preflight skips auth",
+			method:         http.MethodOptions,
+			path:           "/api/v1/ratings",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "cache counters are public",
+			method:         http.MethodGet,
+			path:           "/debug/cache",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "debug vars aren't exposed",
+			method:         http.MethodGet,
+			path:           "/debug/vars",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "unknown route",
+			method:         http.MethodGet,
+			path:           "/nope",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	// The repositories are never reached in these cases, so nil is fine.
+	cfg := config.Default()
+	router := newRouter(&cfg, middleware.HeaderAuthenticator{}, nil, nil, nil, nil, nil, scoring.NewPriors(nil, scoring.Options{}), nil, nil, nil, nil, nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/article"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+// PageMetadataRequest is article metadata as the extension extracted it from the page. Every field is optional.
+// Values that don't make sense, like an unparseable date, are dropped rather than failing the request.
+type PageMetadataRequest struct {
+	Title       string `json:"title"`        // og:title, or the document title
+	SiteName    string `json:"site_name"`    // og:site_name
+	Author      string `json:"author"`       // article:author, or the JSON-LD author's name
+	PublishedAt string `json:"published_at"` // ISO 8601, like article:published_time. A date without a time is fine too.
+	Language    string `json:"language"`     // BCP 47, like "en" or "pt-BR"
+	Headline    string `json:"headline"`     // JSON-LD headline
+}
+
+// PageMetadataResponse describes the article on a page. Unknown fields are left out.
+type PageMetadataResponse struct {
+	Title       *string    `json:"title,omitempty"`
+	SiteName    *string    `json:"site_name,omitempty"`
+	Author      *string    `json:"author,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	Language    *string    `json:"language,omitempty"`
+	Headline    *string    `json:"headline,omitempty"`
+}
+
+// toModel cleans up the metadata. Published times more than a day after now are dropped as bogus.
+func (m *PageMetadataRequest) toModel(now time.Time) *models.PageMetadata {
+	return &models.PageMetadata{
+		Title:       article.CleanText(m.Title, article.MaxTitleLength),
+		SiteName:    article.CleanText(m.SiteName, article.MaxNameLength),
+		Author:      article.CleanText(m.Author, article.MaxNameLength),
+		PublishedAt: article.ParsePublishedTime(m.PublishedAt, now),
+		Language:    article.CleanLanguage(m.Language),
+		Headline:    article.CleanText(m.Headline, article.MaxTitleLength),
+	}
+}
+
+// newPageMetadataResponse converts the page metadata model to its API representation.
+func newPageMetadataResponse(metadata *models.PageMetadata) PageMetadataResponse {
+	if metadata == nil {
+		return PageMetadataResponse{}
+	}
+	return PageMetadataResponse{
+		Title:       metadata.Title,
+		SiteName:    metadata.SiteName,
+		Author:      metadata.Author,
+		PublishedAt: metadata.PublishedAt,
+		Language:    metadata.Language,
+		Headline:    metadata.Headline,
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/article"
+)
+
+func TestPageMetadataRequest_ToModel(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name              string
+		request           PageMetadataRequest
+		expectedTitle     string // Empty means nil
+		expectedLanguage  string
+		expectedPublished string // RFC 3339 in UTC, empty means nil
+		expectEmpty       bool
+	}{
+		{
+			name:        "nothing",
+			expectEmpty: true,
+		},
+		{
+			name:        "only whitespace",
+			request:     PageMetadataRequest{Title: " \n\t ", Author: "  "},
+			expectEmpty: true,
+		},
+		{
+			name:              "full timestamp with offset",
+			request:           PageMetadataRequest{Title: "  How   we\nship  ", Language: "pt-BR", PublishedAt: "2025-05-30T09:15:00+02:00"},
+			expectedTitle:     "How we ship",
+			expectedLanguage:  "pt-BR",
+			expectedPublished: "2025-05-30T07:15:00Z",
+		},
+		{
+			name:              "date only",
+			request:           PageMetadataRequest{PublishedAt: "2024-12-24"},
+			expectedPublished: "2024-12-24T00:00:00Z",
+		},
+		{
+			name:              "local time without zone",
+			request:           PageMetadataRequest{PublishedAt: "2024-12-24T18:30:00"},
+			expectedPublished: "2024-12-24T18:30:00Z",
+		},
+		{
+			name:        "unparseable date and bad language are dropped",
+			request:     PageMetadataRequest{PublishedAt: "last Tuesday", Language: "english (US)"},
+			expectEmpty: true,
+		},
+		{
+			name:        "far future date is dropped",
+			request:     PageMetadataRequest{PublishedAt: "2030-01-01"},
+			expectEmpty: true,
+		},
+		{
+			name:          "long title is cut",
+			request:       PageMetadataRequest{Title: strings.Repeat("é", article.MaxTitleLength+10)},
+			expectedTitle: strings.Repeat("é", article.MaxTitleLength),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := tt.request.toModel(now)
+
+			if metadata.IsEmpty() != tt.expectEmpty {
+				t.Fatalf("Expected IsEmpty() = %v, got %+v", tt.expectEmpty, metadata)
+			}
+			if got := deref(metadata.Title); got != tt.expectedTitle {
+				t.Errorf("Expected title %q, got %q", tt.expectedTitle, got)
+			}
+			if got := deref(metadata.Language); got != tt.expectedLanguage {
+				t.Errorf("Expected language %q, got %q", tt.expectedLanguage, got)
+			}
+			published := ""
+			if metadata.PublishedAt != nil {
+				published = metadata.PublishedAt.Format(time.RFC3339)
+			}
+			if published != tt.expectedPublished {
+				t.Errorf("Expected published time %q, got %q", tt.expectedPublished, published)
+			}
+		})
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
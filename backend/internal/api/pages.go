@@ -1,35 +1,99 @@
 package api
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/vdavid/web-annotator/backend/internal/article"
+	"github.com/vdavid/web-annotator/backend/internal/live"
 	"github.com/vdavid/web-annotator/backend/internal/middleware"
+	"github.com/vdavid/web-annotator/backend/internal/models"
 	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/scoring"
 	"github.com/vdavid/web-annotator/backend/internal/url"
 	"github.com/vdavid/web-annotator/backend/internal/utils"
 )
 
 // PagesHandler handles page-related API endpoints.
 type PagesHandler struct {
-	pagesRepo repository.PagesRepositoryInterface
+	pagesRepo            repository.PagesRepositoryInterface
+	priors               *scoring.Priors
+	hub                  *live.Hub
+	classifier           *article.Classifier
+	fetcher              article.PageFetcher // Nil if fetching is off
+	resolver             url.Resolver        // Nil if resolving redirects is off
+	cachedResolver       url.Resolver        // Same, but never sends a request
+	metadataRefreshAfter time.Duration
 }
 
 // NewPagesHandler creates a new pages handler.
-func NewPagesHandler(pagesRepo repository.PagesRepositoryInterface) *PagesHandler {
-	return &PagesHandler{pagesRepo: pagesRepo}
+// Upsert uses fetcher to classify pages nobody has fetched yet. It may be nil, which leaves them unverified.
+// It follows link wrappers' redirects with resolver when storing a page, and only with cachedResolver when reading one,
+// so reads never wait on other hosts. Both may be nil to only unwrap links offline.
+// Stored metadata older than metadataRefreshAfter gets replaced by new metadata rather than only filled in.
+func NewPagesHandler(pagesRepo repository.PagesRepositoryInterface, priors *scoring.Priors, hub *live.Hub, classifier *article.Classifier, fetcher article.PageFetcher, resolver url.Resolver, cachedResolver url.Resolver, metadataRefreshAfter time.Duration) *PagesHandler {
+	return &PagesHandler{pagesRepo: pagesRepo, priors: priors, hub: hub, classifier: classifier, fetcher: fetcher, resolver: resolver, cachedResolver: cachedResolver, metadataRefreshAfter: metadataRefreshAfter}
 }
 
+const (
+	defaultTopPagesLimit = 20
+	maxTopPagesLimit     = 100
+	maxBatchCheckURLs    = 100
+)
+
 // CheckPageResponse represents the response for the check endpoint.
 type CheckPageResponse struct {
-	CanRate    bool               `json:"can_rate"`
-	Stats      PageStatsResponse  `json:"stats"`
-	UserRating UserRatingResponse `json:"user_rating"`
+	CanRate       bool                 `json:"can_rate"`        // Whether the page is an article, so users may rate it
+	CanRateReason string               `json:"can_rate_reason"` // Why, like "og_type_article" or "not_article". See the article.Reason* constants.
+	Stats         PageStatsResponse    `json:"stats"`
+	Metadata      PageMetadataResponse `json:"metadata"`
+	UserRating    UserRatingResponse   `json:"user_rating"`
 }
 
 // PageStatsResponse contains aggregated statistics for a page.
 type PageStatsResponse struct {
 	TotalRatings int     `json:"total_ratings"`
 	AverageScore float64 `json:"average_score"`
+	// AdjustedScore is a Bayesian average that pulls pages with few ratings towards a prior. Use it for ranking.
+	AdjustedScore float64 `json:"adjusted_score"`
+	Median        float64 `json:"median"`
+	StdDev        float64 `json:"std_dev"`
+	// Histogram has one count per score, from 1 to 10, so a polarizing page stands out from a lukewarm one.
+	Histogram []int `json:"histogram"`
+}
+
+// TopPagesResponse represents the response for the top pages endpoint.
+type TopPagesResponse struct {
+	Pages []TopPageResponse `json:"pages"`
+}
+
+// TopPageResponse is one page in the top pages list.
+type TopPageResponse struct {
+	URL           string  `json:"url"`
+	TotalRatings  int     `json:"total_ratings"`
+	AverageScore  float64 `json:"average_score"`
+	AdjustedScore float64 `json:"adjusted_score"`
+}
+
+// newPageStatsResponse converts the page stats model to its API representation.
+// The adjusted score uses the prior of the page's domain.
+func newPageStatsResponse(stats *models.PageStats, priors models.ScorePriors, normalizedURL string) PageStatsResponse {
+	return PageStatsResponse{
+		TotalRatings:  stats.TotalRatings,
+		AverageScore:  stats.AverageScore,
+		AdjustedScore: priors.AdjustedScore(url.Domain(normalizedURL), stats),
+		Median:        stats.Median,
+		StdDev:        stats.StdDev,
+		Histogram:     stats.Histogram[:],
+	}
 }
 
 // UserRatingResponse contains the current user's rating for a page.
@@ -41,6 +105,7 @@ type UserRatingResponse struct {
 
 // Check handles GET /api/v1/pages/check.
 // It returns page statistics and the current user's rating (if any).
+// It supports If-None-Match and If-Modified-Since, so a client revisiting a tab gets a 304 if nothing changed.
 func (h *PagesHandler) Check(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		Error(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -54,7 +119,7 @@ func (h *PagesHandler) Check(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Normalize the URL
-	normalizedURL, err := url.Normalize(rawURL)
+	normalizedURL, err := url.NormalizeResolving(r.Context(), rawURL, h.cachedResolver)
 	if err != nil {
 		Error(w, http.StatusBadRequest, "Invalid URL")
 		return
@@ -70,32 +135,302 @@ func (h *PagesHandler) Check(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Fetch page statistics
-	stats, err := h.pagesRepo.GetPageStats(ctx, urlHash)
+	// Fetch page statistics, the user's rating, and what the validators are built from in a single query
+	check, err := h.pagesRepo.GetPageCheck(ctx, urlHash, userID)
+	if err == nil && check.PageID == 0 {
+		// The page may be from before normalizing kept the case of paths and query values
+		var found bool
+		if found, err = aliasLegacyPage(ctx, h.pagesRepo, normalizedURL); found {
+			check, err = h.pagesRepo.GetPageCheck(ctx, urlHash, userID)
+		}
+	}
 	if err != nil {
 		Error(w, http.StatusInternalServerError, "Failed to fetch page statistics")
 		return
 	}
-
-	// Fetch user's rating
-	userRating, err := h.pagesRepo.GetUserRating(ctx, urlHash, userID)
-	if err != nil {
-		Error(w, http.StatusInternalServerError, "Failed to fetch user rating")
+	priors := h.priors.Current()
+	etag, lastModified := checkValidators(normalizedURL, check, priors)
+	if NotModified(w, r, etag, lastModified) {
 		return
 	}
 
-	response := CheckPageResponse{
-		CanRate: true, // Server-side validation can be added here if needed
-		Stats: PageStatsResponse{
-			TotalRatings: stats.TotalRatings,
-			AverageScore: stats.AverageScore,
-		},
+	classification := h.classifier.Classify(normalizedURL, check.Signals)
+	JSONResponse(w, http.StatusOK, newCheckPageResponse(check, classification, priors, normalizedURL))
+}
+
+// aliasLegacyPage makes the URL an alias of the page stored under its lowercased form, from before normalizing kept
+// the case of paths and query values. It reports whether there was one, so the caller can look the URL up again.
+// Only call it when looking the URL up found nothing.
+func aliasLegacyPage(ctx context.Context, pagesRepo repository.PagesRepositoryInterface, normalizedURL string) (bool, error) {
+	aliased, err := pagesRepo.AliasLegacyPages(ctx, []string{normalizedURL})
+	return len(aliased) > 0, err
+}
+
+// checkValidators returns the weak ETag and the Last-Modified time of a page's check response.
+// They change whenever the response can: with the page's stats, metadata, and article signals, the user's own
+// rating, and, once the page has ratings, the prior its adjusted score uses.
+func checkValidators(normalizedURL string, check *models.PageCheck, priors models.ScorePriors) (string, time.Time) {
+	hash := sha256.New()
+	lastModified := check.UpdatedAt
+	fmt.Fprintf(hash, "%s|%d|%d|%d", normalizedURL, check.PageID, check.Stats.TotalRatings, check.UpdatedAt.UnixNano())
+	if ratedAt := check.UserRating.UpdatedAt; ratedAt != nil {
+		fmt.Fprintf(hash, "|%d", ratedAt.UnixNano())
+		lastModified = later(lastModified, *ratedAt)
+	}
+	if check.Stats.TotalRatings > 0 {
+		fmt.Fprintf(hash, "|%d|%g", priors.Weight, priors.For(url.Domain(normalizedURL)))
+		lastModified = later(lastModified, priors.UpdatedAt)
+	}
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`, lastModified
+}
+
+// later returns the later of two times.
+func later(a time.Time, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// newCheckPageResponse builds the check response for one page.
+func newCheckPageResponse(check *models.PageCheck, classification article.Classification, priors models.ScorePriors, normalizedURL string) CheckPageResponse {
+	return CheckPageResponse{
+		CanRate:       classification.IsArticle,
+		CanRateReason: classification.Reason,
+		Stats:         newPageStatsResponse(check.Stats, priors, normalizedURL),
+		Metadata:      newPageMetadataResponse(check.Metadata),
 		UserRating: UserRatingResponse{
-			HasRated: userRating.HasRated,
-			Score:    userRating.Score,
-			Comment:  userRating.Comment,
+			HasRated: check.UserRating.HasRated,
+			Score:    check.UserRating.Score,
+			Comment:  check.UserRating.Comment,
 		},
 	}
+}
+
+// BatchCheckRequest represents the request body for the batch check endpoint.
+type BatchCheckRequest struct {
+	URLs []string `json:"urls"`
+}
+
+// BatchCheckResponse has one result per requested URL, in the same order.
+type BatchCheckResponse struct {
+	Results []BatchCheckResult `json:"results"`
+}
+
+// BatchCheckResult is the check result for one URL of a batch.
+// If the URL couldn't be normalized, Error is set and the check fields are left out.
+type BatchCheckResult struct {
+	URL   string `json:"url"` // As sent by the client, so it can match results to its tabs
+	Error string `json:"error,omitempty"`
+	*CheckPageResponse
+}
+
+// CheckBatch handles POST /api/v1/pages/check:batch.
+// It's Check for up to maxBatchCheckURLs URLs at once, like when the browser restores a session with many tabs.
+// All pages are looked up in a single query. A URL that can't be normalized gets an error result
+// and doesn't fail the rest of the batch.
+func (h *PagesHandler) CheckBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req BatchCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.URLs) == 0 {
+		Error(w, http.StatusBadRequest, "Missing urls")
+		return
+	}
+	if len(req.URLs) > maxBatchCheckURLs {
+		Error(w, http.StatusBadRequest, fmt.Sprintf("At most %d URLs can be checked at once", maxBatchCheckURLs))
+		return
+	}
+
+	ctx := r.Context()
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		Error(w, http.StatusUnauthorized, "Missing user ID")
+		return
+	}
+
+	results := make([]BatchCheckResult, len(req.URLs))
+	normalizedURLs := make([]string, len(req.URLs))
+	urlHashes := make([]string, 0, len(req.URLs))
+	for i, rawURL := range req.URLs {
+		results[i].URL = rawURL
+		// Not even cached redirects, since a batch can have hundreds of URLs
+		normalizedURL, err := url.Normalize(rawURL)
+		if err != nil {
+			results[i].Error = "Invalid URL"
+			continue
+		}
+		normalizedURLs[i] = normalizedURL
+		urlHashes = append(urlHashes, utils.HashURL(normalizedURL))
+	}
+
+	checks := map[string]*models.PageCheck{}
+	if len(urlHashes) > 0 {
+		var err error
+		checks, err = h.pagesRepo.GetPageChecks(ctx, urlHashes, userID)
+		if err == nil {
+			err = h.getLegacyPageChecks(ctx, normalizedURLs, userID, checks)
+		}
+		if err != nil {
+			Error(w, http.StatusInternalServerError, "Failed to fetch page checks")
+			return
+		}
+	}
+
+	priors := h.priors.Current()
+	for i, normalizedURL := range normalizedURLs {
+		if results[i].Error != "" {
+			continue
+		}
+		check, ok := checks[utils.HashURL(normalizedURL)]
+		if !ok {
+			// Nobody has rated this page yet
+			check = &models.PageCheck{Stats: models.NewPageStats([models.MaxScore]int{}), Metadata: &models.PageMetadata{}, Signals: &models.ArticleSignals{}, UserRating: &models.UserRating{}}
+		}
+		response := newCheckPageResponse(check, h.classifier.Classify(normalizedURL, check.Signals), priors, normalizedURL)
+		results[i].CheckPageResponse = &response
+	}
+
+	JSONResponse(w, http.StatusOK, BatchCheckResponse{Results: results})
+}
+
+// getLegacyPageChecks adds the checks of the pages stored under the lowercased form of URLs that checks has no page
+// for, see aliasLegacyPage. Empty URLs are skipped.
+func (h *PagesHandler) getLegacyPageChecks(ctx context.Context, normalizedURLs []string, userID string, checks map[string]*models.PageCheck) error {
+	var missing []string
+	for _, normalizedURL := range normalizedURLs {
+		if _, ok := checks[utils.HashURL(normalizedURL)]; !ok && normalizedURL != "" {
+			missing = append(missing, normalizedURL)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	aliased, err := h.pagesRepo.AliasLegacyPages(ctx, missing)
+	if err != nil || len(aliased) == 0 {
+		return err
+	}
+	legacyChecks, err := h.pagesRepo.GetPageChecks(ctx, aliased, userID)
+	if err != nil {
+		return err
+	}
+	maps.Copy(checks, legacyChecks)
+	return nil
+}
+
+// Top handles GET /api/v1/pages/top?limit=....
+// It returns the best-rated pages, ranked by adjusted score so that a single 10/10 doesn't beat hundreds of 9s.
+func (h *PagesHandler) Top(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	limit := defaultTopPagesLimit
+	if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed < 1 || parsed > maxTopPagesLimit {
+			Error(w, http.StatusBadRequest, "limit must be a number between 1 and "+strconv.Itoa(maxTopPagesLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	pages, err := h.pagesRepo.ListTopPages(r.Context(), h.priors.Current(), limit)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to fetch top pages")
+		return
+	}
+
+	response := TopPagesResponse{Pages: make([]TopPageResponse, 0, len(pages))}
+	for _, page := range pages {
+		response.Pages = append(response.Pages, TopPageResponse{
+			URL:           page.NormalizedURL,
+			TotalRatings:  page.TotalRatings,
+			AverageScore:  page.AverageScore,
+			AdjustedScore: page.AdjustedScore,
+		})
+	}
 
 	JSONResponse(w, http.StatusOK, response)
 }
+
+// UpsertPageRequest represents the request body for the page upsert endpoint.
+type UpsertPageRequest struct {
+	URL      string              `json:"url"`
+	Metadata PageMetadataRequest `json:"metadata"`
+}
+
+// UpsertPageResponse is the page as stored after the upsert.
+type UpsertPageResponse struct {
+	URL      string               `json:"url"` // Normalized
+	Metadata PageMetadataResponse `json:"metadata"`
+}
+
+// Upsert handles PUT /api/v1/pages.
+// It records a page with the metadata the extension extracted from it, without rating it.
+// Metadata is first-write-wins per field until it's older than the refresh age, see SavePageMetadata.
+// Like ratings, it only takes articles: other pages get a 422 with the reason as its code, and no page.
+func (h *PagesHandler) Upsert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req UpsertPageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Normalize the URL
+	normalizedURL, err := url.NormalizeResolving(r.Context(), req.URL, h.resolver)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "Invalid URL")
+		return
+	}
+
+	ctx := r.Context()
+
+	// Classify before creating the page, so rejected pages don't get one
+	classification, fetched, err := classifyPage(ctx, h.pagesRepo, h.classifier, h.fetcher, normalizedURL)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to classify page")
+		return
+	}
+	if !classification.IsArticle {
+		ErrorWithCode(w, http.StatusUnprocessableEntity, classification.Reason, "Only articles can be saved")
+		return
+	}
+
+	pageID, err := h.pagesRepo.GetOrCreatePage(ctx, normalizedURL)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to create or get page")
+		return
+	}
+
+	// Marking the page fetched keeps the background fetcher from fetching it again
+	if fetched != nil {
+		if err := h.pagesRepo.SaveFetchedPage(ctx, pageID, fetched); err != nil {
+			log.Printf("Error: %v", err)
+		}
+	}
+
+	metadata, err := h.pagesRepo.SavePageMetadata(ctx, pageID, req.Metadata.toModel(time.Now()), h.metadataRefreshAfter)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to save page metadata")
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, UpsertPageResponse{URL: normalizedURL, Metadata: newPageMetadataResponse(metadata)})
+}
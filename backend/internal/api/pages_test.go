@@ -2,24 +2,49 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/vdavid/web-annotator/backend/internal/article"
 	"github.com/vdavid/web-annotator/backend/internal/middleware"
 	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/scoring"
+	"github.com/vdavid/web-annotator/backend/internal/url"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
 )
 
 // mockPagesRepository is a mock implementation of PagesRepositoryInterface for testing.
 type mockPagesRepository struct {
-	getPageStatsFunc  func(ctx context.Context, urlHash string) (*models.PageStats, error)
-	getUserRatingFunc func(ctx context.Context, urlHash string, userID string) (*models.UserRating, error)
+	getOrCreatePageFunc func(ctx context.Context, normalizedURL string) (int64, error)
+	aliasLegacyFunc     func(ctx context.Context, normalizedURLs []string) ([]string, error)
+	getPageStatsFunc    func(ctx context.Context, urlHash string) (*models.PageStats, error)
+	getUserRatingFunc   func(ctx context.Context, urlHash string, userID string) (*models.UserRating, error)
+	getPageCheckFunc    func(ctx context.Context, urlHash string, userID string) (*models.PageCheck, error)
+	getPageChecksFunc   func(ctx context.Context, urlHashes []string, userID string) (map[string]*models.PageCheck, error)
+	listTopPagesFunc    func(ctx context.Context, priors models.ScorePriors, limit int) ([]models.TopPage, error)
+	saveMetadataFunc    func(ctx context.Context, pageID int64, metadata *models.PageMetadata, refreshAfter time.Duration) (*models.PageMetadata, error)
 }
 
-func (m *mockPagesRepository) GetOrCreatePage(context.Context, string) (int64, error) {
+func (m *mockPagesRepository) GetOrCreatePage(ctx context.Context, normalizedURL string) (int64, error) {
+	if m.getOrCreatePageFunc != nil {
+		return m.getOrCreatePageFunc(ctx, normalizedURL)
+	}
 	return 0, nil
 }
 
+func (m *mockPagesRepository) AliasLegacyPages(ctx context.Context, normalizedURLs []string) ([]string, error) {
+	if m.aliasLegacyFunc != nil {
+		return m.aliasLegacyFunc(ctx, normalizedURLs)
+	}
+	return nil, nil
+}
+
 func (m *mockPagesRepository) GetPageStats(ctx context.Context, urlHash string) (*models.PageStats, error) {
 	if m.getPageStatsFunc != nil {
 		return m.getPageStatsFunc(ctx, urlHash)
@@ -27,6 +52,10 @@ func (m *mockPagesRepository) GetPageStats(ctx context.Context, urlHash string)
 	return nil, nil
 }
 
+func (m *mockPagesRepository) GetPageURLHashes(context.Context, int64) ([]string, error) {
+	return nil, nil
+}
+
 func (m *mockPagesRepository) GetUserRating(ctx context.Context, urlHash string, userID string) (*models.UserRating, error) {
 	if m.getUserRatingFunc != nil {
 		return m.getUserRatingFunc(ctx, urlHash, userID)
@@ -34,14 +63,71 @@ func (m *mockPagesRepository) GetUserRating(ctx context.Context, urlHash string,
 	return nil, nil
 }
 
+func (m *mockPagesRepository) GetUserRatings(context.Context, []int64, string) (map[int64]*models.UserRating, error) {
+	return nil, nil
+}
+
+func (m *mockPagesRepository) GetPageCheck(ctx context.Context, urlHash string, userID string) (*models.PageCheck, error) {
+	if m.getPageCheckFunc != nil {
+		return m.getPageCheckFunc(ctx, urlHash, userID)
+	}
+	return nil, nil
+}
+
+func (m *mockPagesRepository) GetPageChecks(ctx context.Context, urlHashes []string, userID string) (map[string]*models.PageCheck, error) {
+	if m.getPageChecksFunc != nil {
+		return m.getPageChecksFunc(ctx, urlHashes, userID)
+	}
+	return nil, nil
+}
+
+func (m *mockPagesRepository) SavePageMetadata(ctx context.Context, pageID int64, metadata *models.PageMetadata, refreshAfter time.Duration) (*models.PageMetadata, error) {
+	if m.saveMetadataFunc != nil {
+		return m.saveMetadataFunc(ctx, pageID, metadata, refreshAfter)
+	}
+	return metadata, nil
+}
+
+func (m *mockPagesRepository) GetArticleSignals(context.Context, string) (*models.ArticleSignals, error) {
+	return &models.ArticleSignals{}, nil
+}
+
+func (m *mockPagesRepository) SaveFetchedPage(context.Context, int64, *models.FetchedPage) error {
+	return nil
+}
+
+func (m *mockPagesRepository) ListTopPages(ctx context.Context, priors models.ScorePriors, limit int) ([]models.TopPage, error) {
+	if m.listTopPagesFunc != nil {
+		return m.listTopPagesFunc(ctx, priors, limit)
+	}
+	return nil, nil
+}
+
+// testMetadataRefreshAfter is the metadata refresh age handlers get in tests.
+const testMetadataRefreshAfter = 30 * 24 * time.Hour
+
+// newTestPriors returns priors that never refresh: a global prior of 5.5 with a weight of 10.
+func newTestPriors() *scoring.Priors {
+	return scoring.NewPriors(nil, scoring.Options{Weight: 10, Fallback: 5.5})
+}
+
+// newTestClassifier returns the classifier handlers get in tests, which denies everything on excluded.example.
+func newTestClassifier() *article.Classifier {
+	return article.NewClassifier(article.Rules{DenyDomains: []string{"excluded.example"}})
+}
+
 func TestPagesHandler_Check(t *testing.T) {
 	tests := []struct {
-		name           string
-		url            string
-		userID         string
-		mockStats      *models.PageStats
-		mockUserRating *models.UserRating
-		expectedStatus int
+		name             string
+		url              string
+		userID           string
+		mockStats        *models.PageStats
+		mockUserRating   *models.UserRating
+		mockSignals      *models.ArticleSignals
+		expectedStatus   int
+		expectedAdjusted float64
+		expectedCanRate  bool
+		expectedReason   string
 	}{
 		{
 			name:   "successful check with existing rating",
@@ -56,7 +142,10 @@ func TestPagesHandler_Check(t *testing.T) {
 				Score:    intPtr(9),
 				Comment:  stringPtr("Great article!"),
 			},
-			expectedStatus: http.StatusOK,
+			expectedStatus:   http.StatusOK,
+			expectedAdjusted: 7.0, // (10 * 5.5 + 10 * 8.5) / 20
+			expectedCanRate:  true,
+			expectedReason:   article.ReasonUnverified,
 		},
 		{
 			name:   "successful check without user rating",
@@ -71,7 +160,53 @@ func TestPagesHandler_Check(t *testing.T) {
 				Score:    nil,
 				Comment:  nil,
 			},
-			expectedStatus: http.StatusOK,
+			expectedStatus:   http.StatusOK,
+			expectedAdjusted: 91.0 / 15, // (10 * 5.5 + 5 * 7.2) / 15
+			expectedCanRate:  true,
+			expectedReason:   article.ReasonUnverified,
+		},
+		{
+			name:             "polarized page",
+			url:              "https://example.com/article",
+			userID:           "test-user-id",
+			mockStats:        models.NewPageStats([models.MaxScore]int{0: 3, 9: 3}),
+			mockUserRating:   &models.UserRating{HasRated: false},
+			expectedStatus:   http.StatusOK,
+			expectedAdjusted: 5.5,
+			expectedCanRate:  true,
+			expectedReason:   article.ReasonUnverified,
+		},
+		{
+			name:            "fetched article",
+			url:             "https://example.com/article",
+			userID:          "test-user-id",
+			mockStats:       models.NewPageStats([models.MaxScore]int{}),
+			mockUserRating:  &models.UserRating{HasRated: false},
+			mockSignals:     &models.ArticleSignals{OGType: stringPtr("article"), Fetched: true},
+			expectedStatus:  http.StatusOK,
+			expectedCanRate: true,
+			expectedReason:  article.ReasonOGType,
+		},
+		{
+			name:            "fetched page that isn't an article",
+			url:             "https://example.com/about",
+			userID:          "test-user-id",
+			mockStats:       models.NewPageStats([models.MaxScore]int{}),
+			mockUserRating:  &models.UserRating{HasRated: false},
+			mockSignals:     &models.ArticleSignals{OGType: stringPtr("website"), JSONLDTypes: []string{"Organization"}, Fetched: true},
+			expectedStatus:  http.StatusOK,
+			expectedCanRate: false,
+			expectedReason:  article.ReasonNotArticle,
+		},
+		{
+			name:            "denied domain",
+			url:             "https://www.excluded.example/2025/10/deep-post",
+			userID:          "test-user-id",
+			mockStats:       models.NewPageStats([models.MaxScore]int{}),
+			mockUserRating:  &models.UserRating{HasRated: false},
+			expectedStatus:  http.StatusOK,
+			expectedCanRate: false,
+			expectedReason:  article.ReasonDomainDenied,
 		},
 		{
 			name:           "missing url parameter",
@@ -90,16 +225,13 @@ func TestPagesHandler_Check(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := &mockPagesRepository{
-				getPageStatsFunc: func(ctx context.Context, urlHash string) (*models.PageStats, error) {
-					return tt.mockStats, nil
-				},
-				getUserRatingFunc: func(ctx context.Context, urlHash string, userID string) (*models.UserRating, error) {
-					return tt.mockUserRating, nil
+				getPageCheckFunc: func(ctx context.Context, urlHash string, userID string) (*models.PageCheck, error) {
+					return &models.PageCheck{Stats: tt.mockStats, Signals: tt.mockSignals, UserRating: tt.mockUserRating}, nil
 				},
 			}
 
-			handler := NewPagesHandler(mockRepo)
-			handlerFunc := middleware.AuthMiddleware(http.HandlerFunc(handler.Check))
+			handler := NewPagesHandler(mockRepo, newTestPriors(), nil, newTestClassifier(), nil, nil, nil, testMetadataRefreshAfter)
+			handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.Check))
 
 			req := httptest.NewRequest(http.MethodGet, "/api/v1/pages/check?url="+tt.url, nil)
 			if tt.userID != "" {
@@ -110,7 +242,265 @@ func TestPagesHandler_Check(t *testing.T) {
 			handlerFunc.ServeHTTP(rr, req)
 
 			if rr.Code != tt.expectedStatus {
-				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+
+			var response CheckPageResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Stats.Histogram) != models.MaxScore {
+				t.Errorf("Expected %d histogram buckets, got %d", models.MaxScore, len(response.Stats.Histogram))
+			}
+			if response.Stats.Median != tt.mockStats.Median || response.Stats.StdDev != tt.mockStats.StdDev {
+				t.Errorf("Expected median %v and std dev %v, got %v and %v",
+					tt.mockStats.Median, tt.mockStats.StdDev, response.Stats.Median, response.Stats.StdDev)
+			}
+			if math.Abs(response.Stats.AdjustedScore-tt.expectedAdjusted) > 1e-9 {
+				t.Errorf("Expected adjusted score %v, got %v", tt.expectedAdjusted, response.Stats.AdjustedScore)
+			}
+			if response.CanRate != tt.expectedCanRate || response.CanRateReason != tt.expectedReason {
+				t.Errorf("Expected can_rate %v (%s), got %v (%s)", tt.expectedCanRate, tt.expectedReason, response.CanRate, response.CanRateReason)
+			}
+		})
+	}
+}
+
+func TestPagesHandler_CheckFindsLegacyPage(t *testing.T) {
+	pageURL := "https://example.com/Article"
+	var aliased []string
+	mockRepo := &mockPagesRepository{
+		getPageCheckFunc: func(ctx context.Context, urlHash string, userID string) (*models.PageCheck, error) {
+			if !slices.Contains(aliased, urlHash) {
+				return &models.PageCheck{Stats: models.NewPageStats([models.MaxScore]int{}), UserRating: &models.UserRating{}}, nil
+			}
+			return &models.PageCheck{
+				PageID:     1,
+				Stats:      models.NewPageStats([models.MaxScore]int{6: 1}),
+				UserRating: &models.UserRating{HasRated: true, Score: intPtr(7)},
+			}, nil
+		},
+		aliasLegacyFunc: func(ctx context.Context, normalizedURLs []string) ([]string, error) {
+			if !slices.Equal(normalizedURLs, []string{pageURL}) {
+				t.Errorf("Expected to alias %s only, got %v", pageURL, normalizedURLs)
+			}
+			aliased = []string{utils.HashURL(pageURL)}
+			return aliased, nil
+		},
+	}
+
+	handler := NewPagesHandler(mockRepo, newTestPriors(), nil, newTestClassifier(), nil, nil, nil, testMetadataRefreshAfter)
+	handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.Check))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/pages/check?url="+pageURL, nil)
+	req.Header.Set("X-User-ID", "test-user-id")
+	rr := httptest.NewRecorder()
+	handlerFunc.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var response CheckPageResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Stats.TotalRatings != 1 || !response.UserRating.HasRated {
+		t.Errorf("Expected the page stored under the lowercased URL, got %+v and %+v", response.Stats, response.UserRating)
+	}
+}
+
+func TestPagesHandler_CheckBatch(t *testing.T) {
+	ratedURL := "https://example.com/rated"
+	ratedHash := utils.HashURL(ratedURL)
+
+	tests := []struct {
+		name            string
+		body            string
+		expectedStatus  int
+		expectedResults []BatchCheckResult
+		expectRepoCall  bool
+	}{
+		{
+			name:           "mixed results keep request order",
+			body:           `{"urls": ["https://www.example.com/rated/?utm_source=x", "not-a-url", "https://example.com/new"]}`,
+			expectedStatus: http.StatusOK,
+			expectedResults: []BatchCheckResult{
+				{URL: "https://www.example.com/rated/?utm_source=x", CheckPageResponse: &CheckPageResponse{
+					CanRate:    true,
+					Stats:      PageStatsResponse{TotalRatings: 2},
+					UserRating: UserRatingResponse{HasRated: true},
+				}},
+				{URL: "not-a-url", Error: "Invalid URL"},
+				{URL: "https://example.com/new", CheckPageResponse: &CheckPageResponse{CanRate: true}},
+			},
+			expectRepoCall: true,
+		},
+		{
+			name:            "all invalid skips the database",
+			body:            `{"urls": ["nope"]}`,
+			expectedStatus:  http.StatusOK,
+			expectedResults: []BatchCheckResult{{URL: "nope", Error: "Invalid URL"}},
+			expectRepoCall:  false,
+		},
+		{
+			name:           "empty list",
+			body:           `{"urls": []}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "too many urls",
+			body:           `{"urls": [` + strings.Repeat(`"https://example.com/a",`, maxBatchCheckURLs) + `"https://example.com/b"]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid body",
+			body:           `{"urls": "https://example.com"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoCalled := false
+			mockRepo := &mockPagesRepository{
+				getPageChecksFunc: func(ctx context.Context, urlHashes []string, userID string) (map[string]*models.PageCheck, error) {
+					repoCalled = true
+					if userID != "test-user-id" {
+						t.Errorf("Expected the caller's user ID, got %q", userID)
+					}
+					return map[string]*models.PageCheck{
+						ratedHash: {
+							Stats:      models.NewPageStats([models.MaxScore]int{7: 2}),
+							UserRating: &models.UserRating{HasRated: true, Score: intPtr(8)},
+						},
+					}, nil
+				},
+			}
+
+			handler := NewPagesHandler(mockRepo, newTestPriors(), nil, newTestClassifier(), nil, nil, nil, testMetadataRefreshAfter)
+			handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.CheckBatch))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/pages/check:batch", strings.NewReader(tt.body))
+			req.Header.Set("X-User-ID", "test-user-id")
+
+			rr := httptest.NewRecorder()
+			handlerFunc.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if repoCalled != tt.expectRepoCall {
+				t.Errorf("Expected repository call: %v, got %v", tt.expectRepoCall, repoCalled)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+
+			var response BatchCheckResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Results) != len(tt.expectedResults) {
+				t.Fatalf("Expected %d results, got %d", len(tt.expectedResults), len(response.Results))
+			}
+			for i, want := range tt.expectedResults {
+				got := response.Results[i]
+				if got.URL != want.URL || got.Error != want.Error {
+					t.Errorf("Result %d: expected url %q and error %q, got %q and %q", i, want.URL, want.Error, got.URL, got.Error)
+				}
+				if (got.CheckPageResponse == nil) != (want.CheckPageResponse == nil) {
+					t.Fatalf("Result %d: expected check fields: %v", i, want.CheckPageResponse != nil)
+				}
+				if want.CheckPageResponse == nil {
+					continue
+				}
+				if got.Stats.TotalRatings != want.Stats.TotalRatings || got.UserRating.HasRated != want.UserRating.HasRated {
+					t.Errorf("Result %d: expected %d ratings and has_rated %v, got %d and %v", i,
+						want.Stats.TotalRatings, want.UserRating.HasRated, got.Stats.TotalRatings, got.UserRating.HasRated)
+				}
+			}
+		})
+	}
+}
+
+func TestPagesHandler_Top(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedLimit  int
+	}{
+		{
+			name:           "default limit",
+			query:          "",
+			expectedStatus: http.StatusOK,
+			expectedLimit:  defaultTopPagesLimit,
+		},
+		{
+			name:           "custom limit",
+			query:          "?limit=5",
+			expectedStatus: http.StatusOK,
+			expectedLimit:  5,
+		},
+		{
+			name:           "limit too high",
+			query:          "?limit=1000",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "limit not a number",
+			query:          "?limit=many",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotLimit int
+			var gotPriors models.ScorePriors
+			mockRepo := &mockPagesRepository{
+				listTopPagesFunc: func(ctx context.Context, priors models.ScorePriors, limit int) ([]models.TopPage, error) {
+					gotLimit = limit
+					gotPriors = priors
+					return []models.TopPage{
+						{NormalizedURL: "https://example.com/popular", TotalRatings: 200, AverageScore: 9.2, AdjustedScore: 9.04},
+						{NormalizedURL: "https://example.com/lucky", TotalRatings: 1, AverageScore: 10, AdjustedScore: 5.91},
+					}, nil
+				},
+			}
+
+			handler := NewPagesHandler(mockRepo, newTestPriors(), nil, newTestClassifier(), nil, nil, nil, testMetadataRefreshAfter)
+			handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.Top))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/pages/top"+tt.query, nil)
+			req.Header.Set("X-User-ID", "test-user-id")
+
+			rr := httptest.NewRecorder()
+			handlerFunc.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+
+			if gotLimit != tt.expectedLimit {
+				t.Errorf("Expected limit %d, got %d", tt.expectedLimit, gotLimit)
+			}
+			if gotPriors.Weight != 10 || gotPriors.Global != 5.5 {
+				t.Errorf("Expected the current priors to be passed on, got %+v", gotPriors)
+			}
+
+			var response TopPagesResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Pages) != 2 || response.Pages[0].URL != "https://example.com/popular" {
+				t.Errorf("Expected the repository's order to be kept, got %+v", response.Pages)
 			}
 		})
 	}
@@ -124,3 +514,233 @@ func intPtr(i int) *int {
 func stringPtr(s string) *string {
 	return &s
 }
+
+func TestPagesHandler_Check_Conditional(t *testing.T) {
+	updatedAt := time.Date(2026, 10, 1, 12, 0, 0, 500, time.UTC)
+	pageCheck := &models.PageCheck{PageID: 1, UpdatedAt: updatedAt, Stats: models.NewPageStats([models.MaxScore]int{7: 3}), UserRating: &models.UserRating{}}
+	queries := 0
+	mockRepo := &mockPagesRepository{
+		getPageCheckFunc: func(ctx context.Context, urlHash string, userID string) (*models.PageCheck, error) {
+			queries++
+			return pageCheck, nil
+		},
+	}
+	priors := newTestPriors()
+	handler := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(NewPagesHandler(mockRepo, priors, nil, newTestClassifier(), nil, nil, nil, testMetadataRefreshAfter).Check))
+
+	check := func(header string, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/pages/check?url=https://example.com/article", nil)
+		req.Header.Set("X-User-ID", "test-user-id")
+		if value != "" {
+			req.Header.Set(header, value)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := check("", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("Expected 200 with a weak ETag, got %d and %q", first.Code, etag)
+	}
+	if cacheControl := first.Header().Get("Cache-Control"); cacheControl != "private, no-cache" {
+		t.Errorf("Expected private, no-cache, got %q", cacheControl)
+	}
+	lastModified := first.Header().Get("Last-Modified")
+	if want := priors.Current().UpdatedAt.UTC().Format(http.TimeFormat); lastModified != want {
+		t.Errorf("Expected Last-Modified to be the later priors change, %q, got %q", want, lastModified)
+	}
+
+	queries = 0
+	for _, conditional := range []struct{ header, value string }{{"If-None-Match", etag}, {"If-Modified-Since", lastModified}} {
+		unchanged := check(conditional.header, conditional.value)
+		if unchanged.Code != http.StatusNotModified || unchanged.Body.Len() != 0 {
+			t.Errorf("%s: expected an empty 304, got %d with %d bytes", conditional.header, unchanged.Code, unchanged.Body.Len())
+		}
+		if unchanged.Header().Get("ETag") != etag {
+			t.Errorf("%s: expected the 304 to repeat the ETag", conditional.header)
+		}
+	}
+	if queries != 2 {
+		t.Errorf("Expected one query per conditional check, got %d for 2 checks", queries)
+	}
+
+	// The user's own rating is part of the validators
+	ratedAt := time.Now().Add(time.Hour)
+	pageCheck = &models.PageCheck{PageID: 1, UpdatedAt: ratedAt, Stats: models.NewPageStats([models.MaxScore]int{7: 4}), UserRating: &models.UserRating{HasRated: true, Score: intPtr(8), UpdatedAt: &ratedAt}}
+	for _, conditional := range []struct{ header, value string }{{"If-None-Match", etag}, {"If-Modified-Since", lastModified}} {
+		changed := check(conditional.header, conditional.value)
+		if changed.Code != http.StatusOK || changed.Header().Get("ETag") == etag {
+			t.Errorf("%s: expected 200 with a new ETag after the user rated, got %d and %q", conditional.header, changed.Code, changed.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestPagesHandler_Upsert(t *testing.T) {
+	websiteType := "website"
+	tests := []struct {
+		name           string
+		requestBody    string
+		fetcher        *mockFetcher // Nil means fetching is off
+		expectedStatus int
+		expectedCode   string
+		expectedURL    string
+	}{
+		{
+			name:           "with metadata",
+			requestBody:    `{"url": "https://www.example.com/article/?utm_source=x", "metadata": {"title": "An article", "site_name": "Example", "language": "en"}}`,
+			expectedStatus: http.StatusOK,
+			expectedURL:    "https://example.com/article",
+		},
+		{
+			name:           "without metadata",
+			requestBody:    `{"url": "https://example.com/article"}`,
+			expectedStatus: http.StatusOK,
+			expectedURL:    "https://example.com/article",
+		},
+		{
+			name:           "unverified page that turns out to be an article",
+			requestBody:    `{"url": "https://example.com/p/post"}`,
+			fetcher:        &mockFetcher{page: &models.FetchedPage{JSONLDTypes: []string{"NewsArticle"}}},
+			expectedStatus: http.StatusOK,
+			expectedURL:    "https://example.com/p/post",
+		},
+		{
+			name:           "denied domain",
+			requestBody:    `{"url": "https://excluded.example/2025/10/deep-post"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   article.ReasonDomainDenied,
+		},
+		{
+			name:           "unverified page that turns out not to be an article",
+			requestBody:    `{"url": "https://example.com/pricing"}`,
+			fetcher:        &mockFetcher{page: &models.FetchedPage{OGType: &websiteType}},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   article.ReasonNotArticle,
+		},
+		{
+			name:           "invalid url",
+			requestBody:    `{"url": "not a url"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid body",
+			requestBody:    `not json`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRefreshAfter time.Duration
+			creates := 0
+			mockRepo := &mockPagesRepository{
+				getOrCreatePageFunc: func(ctx context.Context, normalizedURL string) (int64, error) {
+					creates++
+					return 1, nil
+				},
+				saveMetadataFunc: func(ctx context.Context, pageID int64, metadata *models.PageMetadata, refreshAfter time.Duration) (*models.PageMetadata, error) {
+					gotRefreshAfter = refreshAfter
+					return metadata, nil
+				},
+			}
+			var fetcher article.PageFetcher
+			if tt.fetcher != nil {
+				fetcher = tt.fetcher
+			}
+			handler := NewPagesHandler(mockRepo, newTestPriors(), nil, newTestClassifier(), fetcher, nil, nil, testMetadataRefreshAfter)
+
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/pages", strings.NewReader(tt.requestBody))
+			rr := httptest.NewRecorder()
+			handler.Upsert(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if rr.Code != http.StatusOK {
+				if creates != 0 {
+					t.Errorf("Expected no page for a rejected request, got %d creates", creates)
+				}
+				var response ErrorResponse
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatal(err)
+				}
+				if tt.expectedCode != "" && response.Code != tt.expectedCode {
+					t.Errorf("Expected code %q, got %q", tt.expectedCode, response.Code)
+				}
+				return
+			}
+			if gotRefreshAfter != testMetadataRefreshAfter {
+				t.Errorf("Expected the configured refresh age, got %s", gotRefreshAfter)
+			}
+			var response UpsertPageResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if response.URL != tt.expectedURL {
+				t.Errorf("Expected URL %q, got %q", tt.expectedURL, response.URL)
+			}
+		})
+	}
+}
+
+// recordingResolver resolves links from a map, or says it doesn't know them, and records what it was asked.
+type recordingResolver struct {
+	redirects map[string]string
+	asked     []string
+}
+
+func (r *recordingResolver) Resolve(_ context.Context, link string) (string, error) {
+	r.asked = append(r.asked, link)
+	if target, ok := r.redirects[link]; ok {
+		return target, nil
+	}
+	return "", url.ErrNotResolved
+}
+
+func TestPagesHandler_ResolvesRedirectsOnlyOnWrites(t *testing.T) {
+	const link = "https://t.co/AbC123"
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		handler        func(h *PagesHandler) http.HandlerFunc
+		expectResolve  bool
+		expectCacheHit bool
+	}{
+		{"check uses the cache", http.MethodGet, "/api/v1/pages/check?url=" + link, "", func(h *PagesHandler) http.HandlerFunc { return h.Check }, false, true},
+		{"batch check resolves nothing", http.MethodPost, "/api/v1/pages/check:batch", `{"urls": ["` + link + `"]}`, func(h *PagesHandler) http.HandlerFunc { return h.CheckBatch }, false, false},
+		{"upsert resolves", http.MethodPut, "/api/v1/pages", `{"url": "` + link + `"}`, func(h *PagesHandler) http.HandlerFunc { return h.Upsert }, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &recordingResolver{redirects: map[string]string{link: "https://example.com/article"}}
+			cachedResolver := &recordingResolver{redirects: map[string]string{link: "https://example.com/article"}}
+			mockRepo := &mockPagesRepository{
+				getPageCheckFunc: func(ctx context.Context, urlHash string, userID string) (*models.PageCheck, error) {
+					return &models.PageCheck{Stats: models.NewPageStats([models.MaxScore]int{}), Signals: &models.ArticleSignals{}, UserRating: &models.UserRating{}}, nil
+				},
+			}
+			handler := NewPagesHandler(mockRepo, newTestPriors(), nil, newTestClassifier(), nil, resolver, cachedResolver, testMetadataRefreshAfter)
+			handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(tt.handler(handler))
+
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("X-User-ID", "test-user-id")
+			rr := httptest.NewRecorder()
+			handlerFunc.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+			if resolved := len(resolver.asked) > 0; resolved != tt.expectResolve {
+				t.Errorf("Expected the resolver to be asked: %v, got %v", tt.expectResolve, resolver.asked)
+			}
+			if cached := len(cachedResolver.asked) > 0; cached != tt.expectCacheHit {
+				t.Errorf("Expected the cached resolver to be asked: %v, got %v", tt.expectCacheHit, cachedResolver.asked)
+			}
+		})
+	}
+}
@@ -1,35 +1,79 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"slices"
+	"time"
 
+	"github.com/vdavid/web-annotator/backend/internal/article"
+	"github.com/vdavid/web-annotator/backend/internal/live"
 	"github.com/vdavid/web-annotator/backend/internal/middleware"
+	"github.com/vdavid/web-annotator/backend/internal/models"
 	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/scoring"
 	"github.com/vdavid/web-annotator/backend/internal/url"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
 )
 
 // RatingsHandler handles rating-related API endpoints.
 type RatingsHandler struct {
-	pagesRepo   repository.PagesRepositoryInterface
-	ratingsRepo repository.RatingsRepositoryInterface
-	usersRepo   repository.UsersRepositoryInterface
+	pagesRepo      repository.PagesRepositoryInterface
+	ratingsRepo    repository.RatingsRepositoryInterface
+	usersRepo      repository.UsersRepositoryInterface
+	scoreRange     ScoreRange
+	priors         *scoring.Priors
+	checkCache     *repository.PageCheckCache // Nil if caching is off
+	hub            *live.Hub
+	classifier     *article.Classifier
+	fetcher        article.PageFetcher // Nil if fetching is off
+	resolver       url.Resolver        // Nil if resolving redirects is off
+	cachedResolver url.Resolver        // Same, but never sends a request
+
+	metadataRefreshAfter time.Duration
+}
+
+// ScoreRange is the inclusive range of scores users may submit.
+type ScoreRange struct {
+	Min int
+	Max int
 }
 
+// DefaultScoreRange is the 1–10 range the database allows.
+var DefaultScoreRange = ScoreRange{Min: 1, Max: 10}
+
 // NewRatingsHandler creates a new ratings handler.
-func NewRatingsHandler(pagesRepo repository.PagesRepositoryInterface, ratingsRepo repository.RatingsRepositoryInterface, usersRepo repository.UsersRepositoryInterface) *RatingsHandler {
+// Submit uses fetcher to classify pages nobody has fetched yet. It may be nil, which leaves them unverified.
+// Link wrappers' redirects are followed with resolver when changing a rating, and only with cachedResolver when
+// reading the history. Both may be nil to only unwrap links offline.
+func NewRatingsHandler(pagesRepo repository.PagesRepositoryInterface, ratingsRepo repository.RatingsRepositoryInterface, usersRepo repository.UsersRepositoryInterface, scoreRange ScoreRange, priors *scoring.Priors, checkCache *repository.PageCheckCache, hub *live.Hub, classifier *article.Classifier, fetcher article.PageFetcher, resolver url.Resolver, cachedResolver url.Resolver, metadataRefreshAfter time.Duration) *RatingsHandler {
 	return &RatingsHandler{
-		pagesRepo:   pagesRepo,
-		ratingsRepo: ratingsRepo,
-		usersRepo:   usersRepo,
+		pagesRepo:      pagesRepo,
+		ratingsRepo:    ratingsRepo,
+		usersRepo:      usersRepo,
+		scoreRange:     scoreRange,
+		priors:         priors,
+		checkCache:     checkCache,
+		hub:            hub,
+		classifier:     classifier,
+		fetcher:        fetcher,
+		resolver:       resolver,
+		cachedResolver: cachedResolver,
+
+		metadataRefreshAfter: metadataRefreshAfter,
 	}
 }
 
 // SubmitRatingRequest represents the request body for submitting a rating.
 type SubmitRatingRequest struct {
-	URL     string  `json:"url"`
-	Score   int     `json:"score"`
-	Comment *string `json:"comment,omitempty"`
+	URL      string               `json:"url"`
+	Score    int                  `json:"score"`
+	Comment  *string              `json:"comment,omitempty"`
+	Metadata *PageMetadataRequest `json:"metadata,omitempty"` // What the extension extracted from the page, if anything
 }
 
 // SubmitRatingResponse represents the response after submitting a rating.
@@ -37,8 +81,26 @@ type SubmitRatingResponse struct {
 	Stats PageStatsResponse `json:"stats"`
 }
 
+// DeleteRatingResponse represents the response after deleting a rating.
+type DeleteRatingResponse struct {
+	Stats PageStatsResponse `json:"stats"`
+}
+
+// RatingHistoryResponse represents the response for the rating history endpoint.
+type RatingHistoryResponse struct {
+	Revisions []RatingRevisionResponse `json:"revisions"`
+}
+
+// RatingRevisionResponse is one change to the user's rating.
+type RatingRevisionResponse struct {
+	Action    string    `json:"action"`
+	Score     *int      `json:"score,omitempty"`
+	Comment   *string   `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // Submit handles POST /api/v1/ratings.
-// It creates or updates a user's rating for a page.
+// It creates or updates a user's rating for a page. Pages that aren't articles get a 422 with the reason as its code.
 func (h *RatingsHandler) Submit(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		Error(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -52,13 +114,13 @@ func (h *RatingsHandler) Submit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate score
-	if req.Score < 1 || req.Score > 10 {
-		Error(w, http.StatusBadRequest, "Score must be between 1 and 10")
+	if req.Score < h.scoreRange.Min || req.Score > h.scoreRange.Max {
+		Error(w, http.StatusBadRequest, fmt.Sprintf("Score must be between %d and %d", h.scoreRange.Min, h.scoreRange.Max))
 		return
 	}
 
 	// Normalize the URL
-	normalizedURL, err := url.Normalize(req.URL)
+	normalizedURL, err := url.NormalizeResolving(r.Context(), req.URL, h.resolver)
 	if err != nil {
 		Error(w, http.StatusBadRequest, "Invalid URL")
 		return
@@ -79,6 +141,17 @@ func (h *RatingsHandler) Submit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only articles can be rated. Classify before creating the page, so rejected pages don't get one.
+	classification, fetched, err := classifyPage(ctx, h.pagesRepo, h.classifier, h.fetcher, normalizedURL)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to classify page")
+		return
+	}
+	if !classification.IsArticle {
+		ErrorWithCode(w, http.StatusUnprocessableEntity, classification.Reason, "Only articles can be rated")
+		return
+	}
+
 	// Ensure page exists and get its ID
 	pageID, err := h.pagesRepo.GetOrCreatePage(ctx, normalizedURL)
 	if err != nil {
@@ -86,6 +159,22 @@ func (h *RatingsHandler) Submit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Marking the page fetched keeps the background fetcher from fetching it again
+	if fetched != nil {
+		if err := h.pagesRepo.SaveFetchedPage(ctx, pageID, fetched); err != nil {
+			log.Printf("Error: %v", err)
+		}
+	}
+
+	// Metadata is a nice-to-have, so failing to save it doesn't fail the rating
+	if req.Metadata != nil {
+		if metadata := req.Metadata.toModel(time.Now()); !metadata.IsEmpty() {
+			if _, err := h.pagesRepo.SavePageMetadata(ctx, pageID, metadata, h.metadataRefreshAfter); err != nil {
+				log.Printf("Error: failed to save page metadata: %v", err)
+			}
+		}
+	}
+
 	// Upsert the rating
 	if err := h.ratingsRepo.UpsertRating(ctx, pageID, userID, req.Score, req.Comment); err != nil {
 		Error(w, http.StatusInternalServerError, "Failed to save rating")
@@ -99,11 +188,175 @@ func (h *RatingsHandler) Submit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.announce(ctx, pageID, utils.HashURL(normalizedURL), stats)
+
 	response := SubmitRatingResponse{
-		Stats: PageStatsResponse{
-			TotalRatings: stats.TotalRatings,
-			AverageScore: stats.AverageScore,
-		},
+		Stats: newPageStatsResponse(stats, h.priors.Current(), normalizedURL),
+	}
+
+	JSONResponse(w, http.StatusOK, response)
+}
+
+// classifyPage decides whether the page is an article. If we haven't fetched the page yet and its URL doesn't tell,
+// it fetches the page right away with fetcher rather than trusting the client, and returns what it fetched for the
+// caller to save. A nil fetcher leaves such pages unverified.
+// Pages the background fetcher gave up on aren't fetched again: their classification is as good as it gets.
+func classifyPage(ctx context.Context, pagesRepo repository.PagesRepositoryInterface, classifier *article.Classifier, fetcher article.PageFetcher, normalizedURL string) (article.Classification, *models.FetchedPage, error) {
+	signals, err := pagesRepo.GetArticleSignals(ctx, utils.HashURL(normalizedURL))
+	if err != nil {
+		return article.Classification{}, nil, err
+	}
+	classification := classifier.Classify(normalizedURL, signals)
+	if classification.Reason != article.ReasonUnverified || fetcher == nil || (signals != nil && signals.Abandoned) {
+		return classification, nil, nil
+	}
+
+	fetched, err := fetcher.Fetch(ctx, normalizedURL)
+	if err != nil {
+		// The background fetcher retries it. Until then, the page stays unverified.
+		log.Printf("Error: failed to fetch %s to classify it: %v", normalizedURL, err)
+		return classification, nil, nil
+	}
+	return classifier.Classify(normalizedURL, fetched.Signals()), fetched, nil
+}
+
+// Delete handles DELETE /api/v1/ratings?url=....
+// It removes the current user's rating for a page and returns the recomputed statistics.
+func (h *RatingsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	rawURL := r.URL.Query().Get("url")
+	if rawURL == "" {
+		Error(w, http.StatusBadRequest, "Missing url query parameter")
+		return
+	}
+
+	// Normalize the URL
+	normalizedURL, err := url.NormalizeResolving(r.Context(), rawURL, h.resolver)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "Invalid URL")
+		return
+	}
+
+	ctx := r.Context()
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		Error(w, http.StatusUnauthorized, "Missing user ID")
+		return
+	}
+
+	// Delete the rating
+	pageID, err := h.ratingsRepo.DeleteRating(ctx, utils.HashURL(normalizedURL), userID)
+	if errors.Is(err, repository.ErrRatingNotFound) {
+		// The page may be from before normalizing kept the case of paths and query values
+		if found, aliasErr := aliasLegacyPage(ctx, h.pagesRepo, normalizedURL); aliasErr != nil {
+			err = aliasErr
+		} else if found {
+			pageID, err = h.ratingsRepo.DeleteRating(ctx, utils.HashURL(normalizedURL), userID)
+		}
+	}
+	if errors.Is(err, repository.ErrRatingNotFound) {
+		Error(w, http.StatusNotFound, "You haven't rated this page")
+		return
+	}
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to delete rating")
+		return
+	}
+
+	// Get updated statistics
+	stats, err := h.ratingsRepo.GetPageStatsAfterRating(ctx, pageID)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to fetch updated statistics")
+		return
+	}
+
+	h.announce(ctx, pageID, utils.HashURL(normalizedURL), stats)
+
+	response := DeleteRatingResponse{
+		Stats: newPageStatsResponse(stats, h.priors.Current(), normalizedURL),
+	}
+
+	JSONResponse(w, http.StatusOK, response)
+}
+
+// announce drops the page's cached check, so the next read sees the new stats right away, and pushes the stats to
+// everyone streaming them. Both go under every URL that leads to the page, since clients may know it by an alias.
+// The rating is already saved, so failures here only get logged. If the aliases can't be loaded, at least the
+// requested URL gets the update.
+func (h *RatingsHandler) announce(ctx context.Context, pageID int64, urlHash string, stats *models.PageStats) {
+	urlHashes, err := h.pagesRepo.GetPageURLHashes(ctx, pageID)
+	if err != nil {
+		log.Printf("Error: %v", err)
+	}
+	if !slices.Contains(urlHashes, urlHash) {
+		urlHashes = append(urlHashes, urlHash)
+	}
+
+	for _, hash := range urlHashes {
+		h.checkCache.Delete(hash)
+		if err := h.hub.Publish(ctx, hash, stats); err != nil {
+			log.Printf("Error: failed to publish page stats: %v", err)
+		}
+	}
+}
+
+// History handles GET /api/v1/ratings/history?url=....
+// It returns every change the current user made to their rating for a page, newest first.
+func (h *RatingsHandler) History(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	rawURL := r.URL.Query().Get("url")
+	if rawURL == "" {
+		Error(w, http.StatusBadRequest, "Missing url query parameter")
+		return
+	}
+
+	// Normalize the URL
+	normalizedURL, err := url.NormalizeResolving(r.Context(), rawURL, h.cachedResolver)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "Invalid URL")
+		return
+	}
+
+	ctx := r.Context()
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		Error(w, http.StatusUnauthorized, "Missing user ID")
+		return
+	}
+
+	revisions, err := h.ratingsRepo.GetRatingHistory(ctx, utils.HashURL(normalizedURL), userID)
+	if err == nil && len(revisions) == 0 {
+		// The page may be from before normalizing kept the case of paths and query values
+		var found bool
+		if found, err = aliasLegacyPage(ctx, h.pagesRepo, normalizedURL); found {
+			revisions, err = h.ratingsRepo.GetRatingHistory(ctx, utils.HashURL(normalizedURL), userID)
+		}
+	}
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to fetch rating history")
+		return
+	}
+
+	response := RatingHistoryResponse{Revisions: make([]RatingRevisionResponse, 0, len(revisions))}
+	for _, revision := range revisions {
+		response.Revisions = append(response.Revisions, RatingRevisionResponse{
+			Action:    revision.Action,
+			Score:     revision.Score,
+			Comment:   revision.Comment,
+			CreatedAt: revision.CreatedAt,
+		})
 	}
 
 	JSONResponse(w, http.StatusOK, response)
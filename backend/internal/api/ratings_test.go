@@ -4,17 +4,30 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/vdavid/web-annotator/backend/internal/article"
+	"github.com/vdavid/web-annotator/backend/internal/cache"
+	"github.com/vdavid/web-annotator/backend/internal/live"
 	"github.com/vdavid/web-annotator/backend/internal/middleware"
 	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
 )
 
 // mockPagesRepositoryForRatings is a mock for ratings handler tests.
 type mockPagesRepositoryForRatings struct {
 	getOrCreatePageFunc func(ctx context.Context, normalizedURL string) (int64, error)
+	saveMetadataFunc    func(ctx context.Context, pageID int64, metadata *models.PageMetadata, refreshAfter time.Duration) (*models.PageMetadata, error)
+	signals             *models.ArticleSignals // What GetArticleSignals returns. Nil means a page that doesn't exist yet.
+	savedFetch          *models.FetchedPage
+	urlHashes           []string // What GetPageURLHashes returns
 }
 
 func (m *mockPagesRepositoryForRatings) GetOrCreatePage(ctx context.Context, normalizedURL string) (int64, error) {
@@ -28,13 +41,55 @@ func (m *mockPagesRepositoryForRatings) GetPageStats(context.Context, string) (*
 	return nil, nil
 }
 
+func (m *mockPagesRepositoryForRatings) GetPageURLHashes(context.Context, int64) ([]string, error) {
+	return m.urlHashes, nil
+}
+
 func (m *mockPagesRepositoryForRatings) GetUserRating(context.Context, string, string) (*models.UserRating, error) {
 	return nil, nil
 }
 
+func (m *mockPagesRepositoryForRatings) AliasLegacyPages(context.Context, []string) ([]string, error) {
+	return nil, nil
+}
+
+func (m *mockPagesRepositoryForRatings) GetUserRatings(context.Context, []int64, string) (map[int64]*models.UserRating, error) {
+	return nil, nil
+}
+
+func (m *mockPagesRepositoryForRatings) GetPageCheck(context.Context, string, string) (*models.PageCheck, error) {
+	return nil, nil
+}
+
+func (m *mockPagesRepositoryForRatings) GetPageChecks(context.Context, []string, string) (map[string]*models.PageCheck, error) {
+	return nil, nil
+}
+
+func (m *mockPagesRepositoryForRatings) SavePageMetadata(ctx context.Context, pageID int64, metadata *models.PageMetadata, refreshAfter time.Duration) (*models.PageMetadata, error) {
+	if m.saveMetadataFunc != nil {
+		return m.saveMetadataFunc(ctx, pageID, metadata, refreshAfter)
+	}
+	return metadata, nil
+}
+
+func (m *mockPagesRepositoryForRatings) GetArticleSignals(context.Context, string) (*models.ArticleSignals, error) {
+	return m.signals, nil
+}
+
+func (m *mockPagesRepositoryForRatings) SaveFetchedPage(_ context.Context, _ int64, page *models.FetchedPage) error {
+	m.savedFetch = page
+	return nil
+}
+
+func (m *mockPagesRepositoryForRatings) ListTopPages(context.Context, models.ScorePriors, int) ([]models.TopPage, error) {
+	return nil, nil
+}
+
 // mockRatingsRepository is a mock implementation for ratings tests.
 type mockRatingsRepository struct {
 	upsertRatingFunc            func(ctx context.Context, pageID int64, userID string, score int, comment *string) error
+	deleteRatingFunc            func(ctx context.Context, urlHash string, userID string) (int64, error)
+	getRatingHistoryFunc        func(ctx context.Context, urlHash string, userID string) ([]models.RatingRevision, error)
 	getPageStatsAfterRatingFunc func(ctx context.Context, pageID int64) (*models.PageStats, error)
 }
 
@@ -45,6 +100,20 @@ func (m *mockRatingsRepository) UpsertRating(ctx context.Context, pageID int64,
 	return nil
 }
 
+func (m *mockRatingsRepository) DeleteRating(ctx context.Context, urlHash string, userID string) (int64, error) {
+	if m.deleteRatingFunc != nil {
+		return m.deleteRatingFunc(ctx, urlHash, userID)
+	}
+	return 0, nil
+}
+
+func (m *mockRatingsRepository) GetRatingHistory(ctx context.Context, urlHash string, userID string) ([]models.RatingRevision, error) {
+	if m.getRatingHistoryFunc != nil {
+		return m.getRatingHistoryFunc(ctx, urlHash, userID)
+	}
+	return nil, nil
+}
+
 func (m *mockRatingsRepository) GetPageStatsAfterRating(ctx context.Context, pageID int64) (*models.PageStats, error) {
 	if m.getPageStatsAfterRatingFunc != nil {
 		return m.getPageStatsAfterRatingFunc(ctx, pageID)
@@ -72,6 +141,8 @@ func TestRatingsHandler_Submit(t *testing.T) {
 		expectedStatus int
 		mockPageID     int64
 		mockStats      *models.PageStats
+		saveErr        error
+		expectedSaves  int
 	}{
 		{
 			name: "successful rating submission",
@@ -102,6 +173,45 @@ func TestRatingsHandler_Submit(t *testing.T) {
 				AverageScore: 9.0,
 			},
 		},
+		{
+			name: "with metadata",
+			requestBody: SubmitRatingRequest{
+				URL:      "https://example.com/article",
+				Score:    7,
+				Metadata: &PageMetadataRequest{Title: "An article", Author: "Jane Doe"},
+			},
+			userID:         "test-user-id",
+			expectedStatus: http.StatusOK,
+			mockPageID:     1,
+			mockStats:      models.NewPageStats([models.MaxScore]int{6: 1}),
+			expectedSaves:  1,
+		},
+		{
+			name: "failing to save metadata still saves the rating",
+			requestBody: SubmitRatingRequest{
+				URL:      "https://example.com/article",
+				Score:    7,
+				Metadata: &PageMetadataRequest{Title: "An article"},
+			},
+			userID:         "test-user-id",
+			expectedStatus: http.StatusOK,
+			mockPageID:     1,
+			mockStats:      models.NewPageStats([models.MaxScore]int{6: 1}),
+			saveErr:        errors.New("database is down"),
+			expectedSaves:  1,
+		},
+		{
+			name: "empty metadata isn't saved",
+			requestBody: SubmitRatingRequest{
+				URL:      "https://example.com/article",
+				Score:    7,
+				Metadata: &PageMetadataRequest{Title: "  "},
+			},
+			userID:         "test-user-id",
+			expectedStatus: http.StatusOK,
+			mockPageID:     1,
+			mockStats:      models.NewPageStats([models.MaxScore]int{6: 1}),
+		},
 		{
 			name: "invalid score too low",
 			requestBody: SubmitRatingRequest{
@@ -142,10 +252,15 @@ func TestRatingsHandler_Submit(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			saves := 0
 			mockPagesRepo := &mockPagesRepositoryForRatings{
 				getOrCreatePageFunc: func(ctx context.Context, normalizedURL string) (int64, error) {
 					return tt.mockPageID, nil
 				},
+				saveMetadataFunc: func(ctx context.Context, pageID int64, metadata *models.PageMetadata, refreshAfter time.Duration) (*models.PageMetadata, error) {
+					saves++
+					return metadata, tt.saveErr
+				},
 			}
 
 			mockRatingsRepo := &mockRatingsRepository{
@@ -163,8 +278,8 @@ func TestRatingsHandler_Submit(t *testing.T) {
 				},
 			}
 
-			handler := NewRatingsHandler(mockPagesRepo, mockRatingsRepo, mockUsersRepo)
-			handlerFunc := middleware.AuthMiddleware(http.HandlerFunc(handler.Submit))
+			handler := NewRatingsHandler(mockPagesRepo, mockRatingsRepo, mockUsersRepo, DefaultScoreRange, newTestPriors(), nil, nil, newTestClassifier(), nil, nil, nil, testMetadataRefreshAfter)
+			handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.Submit))
 
 			body, _ := json.Marshal(tt.requestBody)
 			req := httptest.NewRequest(http.MethodPost, "/api/v1/ratings", bytes.NewReader(body))
@@ -179,6 +294,407 @@ func TestRatingsHandler_Submit(t *testing.T) {
 			if rr.Code != tt.expectedStatus {
 				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
 			}
+			if saves != tt.expectedSaves {
+				t.Errorf("Expected %d metadata saves, got %d", tt.expectedSaves, saves)
+			}
+		})
+	}
+}
+
+// mockFetcher stands in for the page fetcher, returning the same page or error for every URL.
+type mockFetcher struct {
+	page    *models.FetchedPage
+	err     error
+	fetched []string
+}
+
+func (m *mockFetcher) Fetch(_ context.Context, pageURL string) (*models.FetchedPage, error) {
+	m.fetched = append(m.fetched, pageURL)
+	return m.page, m.err
+}
+
+func TestRatingsHandler_SubmitOnlyRatesArticles(t *testing.T) {
+	websiteType := "website"
+	tests := []struct {
+		name           string
+		url            string
+		signals        *models.ArticleSignals
+		fetcher        *mockFetcher // Nil means fetching is off
+		expectedStatus int
+		expectedCode   string
+		expectFetch    bool
+	}{
+		{
+			name:           "denied domain",
+			url:            "https://excluded.example/2025/10/deep-post",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   article.ReasonDomainDenied,
+		},
+		{
+			name:           "fetched page that isn't an article",
+			url:            "https://example.com/about",
+			signals:        &models.ArticleSignals{OGType: &websiteType, Fetched: true},
+			fetcher:        &mockFetcher{},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   article.ReasonNotArticle,
+		},
+		{
+			name:           "deep URL needs no fetch",
+			url:            "https://example.com/2025/10/deep-post",
+			fetcher:        &mockFetcher{},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unverified page that turns out to be an article",
+			url:            "https://example.com/p/post",
+			fetcher:        &mockFetcher{page: &models.FetchedPage{JSONLDTypes: []string{"NewsArticle"}}},
+			expectedStatus: http.StatusOK,
+			expectFetch:    true,
+		},
+		{
+			name:           "unverified page that turns out not to be an article",
+			url:            "https://example.com/pricing",
+			fetcher:        &mockFetcher{page: &models.FetchedPage{OGType: &websiteType}},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   article.ReasonNotArticle,
+			expectFetch:    true,
+		},
+		{
+			name:           "unverified page that can't be fetched stays unverified",
+			url:            "https://example.com/p/post",
+			fetcher:        &mockFetcher{err: errors.New("HTTP 403")},
+			expectedStatus: http.StatusOK,
+			expectFetch:    true,
+		},
+		{
+			name:           "page the fetcher gave up on isn't fetched again",
+			url:            "https://example.com/p/post",
+			signals:        &models.ArticleSignals{Abandoned: true},
+			fetcher:        &mockFetcher{},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unverified page with fetching off",
+			url:            "https://example.com/p/post",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upserts, creates := 0, 0
+			mockPagesRepo := &mockPagesRepositoryForRatings{
+				signals: tt.signals,
+				getOrCreatePageFunc: func(ctx context.Context, normalizedURL string) (int64, error) {
+					creates++
+					return 1, nil
+				},
+			}
+			mockRatingsRepo := &mockRatingsRepository{
+				upsertRatingFunc: func(ctx context.Context, pageID int64, userID string, score int, comment *string) error {
+					upserts++
+					return nil
+				},
+				getPageStatsAfterRatingFunc: func(ctx context.Context, pageID int64) (*models.PageStats, error) {
+					return models.NewPageStats([models.MaxScore]int{7: 1}), nil
+				},
+			}
+			var fetcher article.PageFetcher
+			if tt.fetcher != nil {
+				fetcher = tt.fetcher
+			}
+
+			handler := NewRatingsHandler(mockPagesRepo, mockRatingsRepo, &mockUsersRepository{}, DefaultScoreRange, newTestPriors(), nil, nil, newTestClassifier(), fetcher, nil, nil, testMetadataRefreshAfter)
+			handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.Submit))
+
+			body, _ := json.Marshal(SubmitRatingRequest{URL: tt.url, Score: 8})
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/ratings", bytes.NewReader(body))
+			req.Header.Set("X-User-ID", "test-user-id")
+			rr := httptest.NewRecorder()
+			handlerFunc.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if rr.Code == http.StatusOK {
+				if upserts != 1 {
+					t.Errorf("Expected the rating to be saved, got %d upserts", upserts)
+				}
+			} else {
+				var response ErrorResponse
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatal(err)
+				}
+				if response.Code != tt.expectedCode || upserts != 0 {
+					t.Errorf("Expected code %q and no upserts, got %q and %d", tt.expectedCode, response.Code, upserts)
+				}
+				if creates != 0 {
+					t.Errorf("Expected no page for a rejected rating, got %d created", creates)
+				}
+			}
+
+			fetched := tt.fetcher != nil && len(tt.fetcher.fetched) > 0
+			if fetched != tt.expectFetch {
+				t.Errorf("Expected a fetch: %v, got %v", tt.expectFetch, fetched)
+			}
+			expectSave := tt.expectFetch && tt.fetcher.err == nil && rr.Code == http.StatusOK
+			if saved := mockPagesRepo.savedFetch != nil; saved != expectSave {
+				t.Errorf("Expected the fetched page to be saved only after a successful fetch of an article, saved: %v", saved)
+			}
+		})
+	}
+}
+
+func TestRatingsHandler_Delete(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		userID         string
+		deleteErr      error
+		mockStats      *models.PageStats
+		expectedStatus int
+		expectedTotal  int
+	}{
+		{
+			name:   "successful deletion",
+			url:    "https://example.com/article?utm_source=newsletter",
+			userID: "test-user-id",
+			mockStats: &models.PageStats{
+				TotalRatings: 2,
+				AverageScore: 7.5,
+			},
+			expectedStatus: http.StatusOK,
+			expectedTotal:  2,
+		},
+		{
+			name:   "last rating deleted",
+			url:    "https://example.com/article",
+			userID: "test-user-id",
+			mockStats: &models.PageStats{
+				TotalRatings: 0,
+				AverageScore: 0,
+			},
+			expectedStatus: http.StatusOK,
+			expectedTotal:  0,
+		},
+		{
+			name:           "not rated",
+			url:            "https://example.com/article",
+			userID:         "test-user-id",
+			deleteErr:      repository.ErrRatingNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "missing url parameter",
+			url:            "",
+			userID:         "test-user-id",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid url",
+			url:            "not-a-url",
+			userID:         "test-user-id",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing user ID",
+			url:            "https://example.com/article",
+			userID:         "",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deletedHash string
+			mockRatingsRepo := &mockRatingsRepository{
+				deleteRatingFunc: func(ctx context.Context, urlHash string, userID string) (int64, error) {
+					deletedHash = urlHash
+					return 1, tt.deleteErr
+				},
+				getPageStatsAfterRatingFunc: func(ctx context.Context, pageID int64) (*models.PageStats, error) {
+					return tt.mockStats, nil
+				},
+			}
+
+			handler := NewRatingsHandler(&mockPagesRepositoryForRatings{}, mockRatingsRepo, &mockUsersRepository{}, DefaultScoreRange, newTestPriors(), nil, nil, newTestClassifier(), nil, nil, nil, testMetadataRefreshAfter)
+			handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.Delete))
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/ratings?url="+url.QueryEscape(tt.url), nil)
+			if tt.userID != "" {
+				req.Header.Set("X-User-ID", tt.userID)
+			}
+
+			rr := httptest.NewRecorder()
+			handlerFunc.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+
+			// The tracking parameter must not change which page gets deleted
+			if deletedHash != utils.HashURL("https://example.com/article") {
+				t.Errorf("Expected the normalized URL's hash, got %s", deletedHash)
+			}
+			var response DeleteRatingResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Stats.TotalRatings != tt.expectedTotal {
+				t.Errorf("Expected %d total ratings, got %d", tt.expectedTotal, response.Stats.TotalRatings)
+			}
+		})
+	}
+}
+
+func TestRatingsHandler_History(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name              string
+		url               string
+		userID            string
+		mockRevisions     []models.RatingRevision
+		expectedStatus    int
+		expectedRevisions int
+	}{
+		{
+			name:   "edited rating",
+			url:    "https://example.com/article",
+			userID: "test-user-id",
+			mockRevisions: []models.RatingRevision{
+				{Action: models.RevisionUpdated, Score: intPtr(4), Comment: stringPtr("Changed my mind"), CreatedAt: now},
+				{Action: models.RevisionCreated, Score: intPtr(9), CreatedAt: now.Add(-time.Hour)},
+			},
+			expectedStatus:    http.StatusOK,
+			expectedRevisions: 2,
+		},
+		{
+			name:              "never rated",
+			url:               "https://example.com/article",
+			userID:            "test-user-id",
+			mockRevisions:     nil,
+			expectedStatus:    http.StatusOK,
+			expectedRevisions: 0,
+		},
+		{
+			name:           "missing url parameter",
+			url:            "",
+			userID:         "test-user-id",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid url",
+			url:            "not-a-url",
+			userID:         "test-user-id",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing user ID",
+			url:            "https://example.com/article",
+			userID:         "",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRatingsRepo := &mockRatingsRepository{
+				getRatingHistoryFunc: func(ctx context.Context, urlHash string, userID string) ([]models.RatingRevision, error) {
+					return tt.mockRevisions, nil
+				},
+			}
+
+			handler := NewRatingsHandler(&mockPagesRepositoryForRatings{}, mockRatingsRepo, &mockUsersRepository{}, DefaultScoreRange, newTestPriors(), nil, nil, newTestClassifier(), nil, nil, nil, testMetadataRefreshAfter)
+			handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.History))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/ratings/history?url="+url.QueryEscape(tt.url), nil)
+			if tt.userID != "" {
+				req.Header.Set("X-User-ID", tt.userID)
+			}
+
+			rr := httptest.NewRecorder()
+			handlerFunc.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+
+			var response RatingHistoryResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Revisions) != tt.expectedRevisions {
+				t.Errorf("Expected %d revisions, got %d", tt.expectedRevisions, len(response.Revisions))
+			}
+		})
+	}
+}
+
+func TestRatingsHandler_InvalidatesCachedChecks(t *testing.T) {
+	checkCache := cache.NewLRU[string, models.PageCheck](10, time.Minute)
+	urlHash := utils.HashURL("https://example.com/article")
+	stale := models.PageCheck{PageID: 1, Stats: &models.PageStats{TotalRatings: 1, AverageScore: 5}}
+
+	fresh := models.NewPageStats([models.MaxScore]int{4: 1, 8: 1})
+	mockRatingsRepo := &mockRatingsRepository{
+		getPageStatsAfterRatingFunc: func(ctx context.Context, pageID int64) (*models.PageStats, error) {
+			return fresh, nil
+		},
+		deleteRatingFunc: func(ctx context.Context, urlHash string, userID string) (int64, error) {
+			return 1, nil
+		},
+	}
+	// The page's own URL is another one, the requests use an alias of it
+	canonicalHash := utils.HashURL("https://example.com/canonical")
+	mockPagesRepo := &mockPagesRepositoryForRatings{urlHashes: []string{canonicalHash, urlHash}}
+	hub := live.NewHub(nil)
+	handler := NewRatingsHandler(mockPagesRepo, mockRatingsRepo, &mockUsersRepository{}, DefaultScoreRange, newTestPriors(), checkCache, hub, newTestClassifier(), nil, nil, nil, testMetadataRefreshAfter)
+	auth := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)
+
+	requests := []struct {
+		name    string
+		handler http.HandlerFunc
+		request *http.Request
+	}{
+		{"submit", handler.Submit, httptest.NewRequest(http.MethodPost, "/api/v1/ratings",
+			strings.NewReader(`{"url": "https://www.example.com/article/", "score": 9}`))},
+		{"delete", handler.Delete, httptest.NewRequest(http.MethodDelete,
+			"/api/v1/ratings?url="+url.QueryEscape("https://example.com/article?utm_source=x"), nil)},
+	}
+
+	for _, tt := range requests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkCache.Set(urlHash, stale)
+			checkCache.Set(canonicalHash, stale)
+			updates, unsubscribe := hub.Subscribe(canonicalHash)
+			defer unsubscribe()
+			tt.request.Header.Set("X-User-ID", "test-user-id")
+
+			rr := httptest.NewRecorder()
+			auth(tt.handler).ServeHTTP(rr, tt.request)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+
+			for _, hash := range []string{urlHash, canonicalHash} {
+				if cached, ok := checkCache.Get(hash); ok {
+					t.Errorf("Expected the cached check under %s to be dropped, got %+v", hash, cached)
+				}
+			}
+			select {
+			case update := <-updates:
+				if update.TotalRatings != fresh.TotalRatings {
+					t.Errorf("Expected the fresh stats on the canonical URL's stream, got %+v", update)
+				}
+			default:
+				t.Error("Expected an update on the canonical URL's stream")
+			}
 		})
 	}
 }
@@ -3,6 +3,8 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
+	"time"
 )
 
 // JSONResponse writes a JSON response with the given status code.
@@ -17,9 +19,65 @@ func JSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
 // ErrorResponse represents an error response.
 type ErrorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code,omitempty"` // Machine-readable detail for errors clients handle, like "not_article"
 }
 
 // Error writes an error response as JSON.
 func Error(w http.ResponseWriter, statusCode int, message string) {
 	JSONResponse(w, statusCode, ErrorResponse{Error: message})
 }
+
+// ErrorWithCode writes an error response with a machine-readable code as JSON.
+func ErrorWithCode(w http.ResponseWriter, statusCode int, code string, message string) {
+	JSONResponse(w, statusCode, ErrorResponse{Error: message, Code: code})
+}
+
+// NotModified sets a response's validators and reports whether the client already has that response.
+// If it does, NotModified has written an empty 304 and the caller is done. Otherwise the caller writes the response.
+// Build the validators from something cheaper than the response, so a 304 saves the work too.
+// If-None-Match wins over If-Modified-Since, as RFC 9110 requires. A zero lastModified leaves Last-Modified out.
+// The response is private, as it depends on who's asking. no-cache makes clients revalidate on every use:
+// it means a user never sees their own rating go stale.
+func NotModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Add("Vary", "Authorization, X-User-ID")
+
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) && !notModifiedSince(r, lastModified) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// notModifiedSince reports whether a request without If-None-Match has an If-Modified-Since at or after lastModified.
+func notModifiedSince(r *http.Request, lastModified time.Time) bool {
+	if lastModified.IsZero() || r.Header.Get("If-None-Match") != "" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	// The header only has whole seconds
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag.
+// It uses weak comparison, as RFC 9110 requires for If-None-Match, so W/ prefixes are ignored.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
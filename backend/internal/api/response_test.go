@@ -0,0 +1,27 @@
+package api
+
+import "testing"
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		etag        string
+		want        bool
+	}{
+		{"no header", "", `W/"abc"`, false},
+		{"same weak tag", `W/"abc"`, `W/"abc"`, true},
+		{"strong tag matches weakly", `"abc"`, `W/"abc"`, true},
+		{"one of many", `"xyz", W/"abc"`, `W/"abc"`, true},
+		{"different tag", `W/"xyz"`, `W/"abc"`, false},
+		{"any", `*`, `W/"abc"`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagMatches(tt.ifNoneMatch, tt.etag); got != tt.want {
+				t.Errorf("etagMatches(%q, %q) = %v, want %v", tt.ifNoneMatch, tt.etag, got, tt.want)
+			}
+		})
+	}
+}
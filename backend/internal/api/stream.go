@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/url"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+// streamHeartbeatInterval is how often an idle stream gets a comment line, so proxies don't close it.
+const streamHeartbeatInterval = 25 * time.Second
+
+// Stream handles GET /api/v1/pages/stream?url=....
+// It's a Server-Sent Events stream of the page's stats: a "stats" event with the current stats right away,
+// then another one each time someone rates the page or removes their rating. Each event's data is a PageStatsResponse.
+func (h *PagesHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	rawURL := r.URL.Query().Get("url")
+	if rawURL == "" {
+		Error(w, http.StatusBadRequest, "Missing url query parameter")
+		return
+	}
+
+	// Normalize the URL
+	normalizedURL, err := url.NormalizeResolving(r.Context(), rawURL, h.cachedResolver)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "Invalid URL")
+		return
+	}
+
+	urlHash := utils.HashURL(normalizedURL)
+	ctx := r.Context()
+
+	// Subscribe before reading the current stats, so no change can slip in between
+	updates, unsubscribe := h.hub.Subscribe(urlHash)
+	defer unsubscribe()
+
+	stats, err := h.pagesRepo.GetPageStats(ctx, urlHash)
+	if err == nil && stats.TotalRatings == 0 {
+		// The page may be from before normalizing kept the case of paths and query values
+		var found bool
+		if found, err = aliasLegacyPage(ctx, h.pagesRepo, normalizedURL); found {
+			stats, err = h.pagesRepo.GetPageStats(ctx, urlHash)
+		}
+	}
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to fetch page statistics")
+		return
+	}
+
+	// The server's write timeout is meant for regular requests, not for streams that stay open
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		Error(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Tells nginx not to buffer the stream
+	w.WriteHeader(http.StatusOK)
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		if err := h.writeStatsEvent(w, stats, normalizedURL); err != nil {
+			return
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+
+		stats = nil
+		for stats == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				if err := controller.Flush(); err != nil {
+					return
+				}
+			case update, open := <-updates:
+				if !open {
+					return // The server is shutting down
+				}
+				stats = &update
+			}
+		}
+	}
+}
+
+// writeStatsEvent writes one "stats" event.
+func (h *PagesHandler) writeStatsEvent(w http.ResponseWriter, stats *models.PageStats, normalizedURL string) error {
+	data, err := json.Marshal(newPageStatsResponse(stats, h.priors.Current(), normalizedURL))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: stats\ndata: %s\n\n", data)
+	return err
+}
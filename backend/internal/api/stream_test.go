@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/vdavid/web-annotator/backend/internal/live"
+	"github.com/vdavid/web-annotator/backend/internal/middleware"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+func TestPagesHandler_Stream(t *testing.T) {
+	mockRepo := &mockPagesRepository{
+		getPageStatsFunc: func(ctx context.Context, urlHash string) (*models.PageStats, error) {
+			return models.NewPageStats([models.MaxScore]int{7: 1}), nil
+		},
+	}
+	hub := live.NewHub(nil)
+	handler := NewPagesHandler(mockRepo, newTestPriors(), hub, newTestClassifier(), nil, nil, nil, testMetadataRefreshAfter)
+	server := httptest.NewServer(middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.Stream)))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		server.URL+"?url="+url.QueryEscape("https://www.example.com/article/"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-User-ID", "test-user-id")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Expected an event stream, got %q", contentType)
+	}
+
+	events := bufio.NewReader(resp.Body)
+	if stats := readStatsEvent(t, events); stats.TotalRatings != 1 || stats.AverageScore != 8 {
+		t.Errorf("Expected the current stats first, got %+v", stats)
+	}
+
+	// Someone else rates the page
+	fresh := models.NewPageStats([models.MaxScore]int{7: 1, 9: 1})
+	if err := hub.Publish(ctx, utils.HashURL("https://example.com/article"), fresh); err != nil {
+		t.Fatal(err)
+	}
+	if stats := readStatsEvent(t, events); stats.TotalRatings != 2 || stats.AverageScore != 9 {
+		t.Errorf("Expected the fresh stats, got %+v", stats)
+	}
+
+	// Shutting down ends the stream
+	hub.Close()
+	if _, err := events.ReadString('\n'); err == nil {
+		t.Error("Expected the stream to end")
+	}
+}
+
+func TestPagesHandler_Stream_InvalidURL(t *testing.T) {
+	handler := NewPagesHandler(&mockPagesRepository{}, newTestPriors(), live.NewHub(nil), newTestClassifier(), nil, nil, nil, testMetadataRefreshAfter)
+
+	for _, rawURL := range []string{"", "not a url"} {
+		rr := httptest.NewRecorder()
+		handler.Stream(rr, httptest.NewRequest(http.MethodGet, "/api/v1/pages/stream?url="+url.QueryEscape(rawURL), nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status %d, got %d", rawURL, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+// readStatsEvent reads the next "stats" event from the stream.
+func readStatsEvent(t *testing.T, events *bufio.Reader) PageStatsResponse {
+	t.Helper()
+
+	var event, data string
+	for {
+		line, err := events.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && data != "":
+			if event != "stats" {
+				t.Fatalf("Expected a stats event, got %q", event)
+			}
+			var stats PageStatsResponse
+			if err := json.Unmarshal([]byte(data), &stats); err != nil {
+				t.Fatalf("Failed to decode event data: %v", err)
+			}
+			return stats
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
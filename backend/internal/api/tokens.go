@@ -0,0 +1,199 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/middleware"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+// maxTokenNameLength matches the api_tokens.name column.
+const maxTokenNameLength = 255
+
+// TokensHandler handles personal API token endpoints.
+type TokensHandler struct {
+	tokensRepo repository.TokensRepositoryInterface
+	usersRepo  repository.UsersRepositoryInterface
+}
+
+// NewTokensHandler creates a new tokens handler.
+func NewTokensHandler(tokensRepo repository.TokensRepositoryInterface, usersRepo repository.UsersRepositoryInterface) *TokensHandler {
+	return &TokensHandler{tokensRepo: tokensRepo, usersRepo: usersRepo}
+}
+
+// CreateTokenRequest represents the request body for creating a token.
+type CreateTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// APITokenResponse describes a token without revealing it.
+type APITokenResponse struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CreateTokenResponse is returned once, on creation. It's the only time the full token is shown.
+type CreateTokenResponse struct {
+	Token string `json:"token"`
+	APITokenResponse
+}
+
+// ListTokensResponse represents the response for listing tokens.
+type ListTokensResponse struct {
+	Tokens []APITokenResponse `json:"tokens"`
+}
+
+// Create handles POST /api/v1/tokens.
+// It creates a token for the current user and returns it in full, once.
+func (h *TokensHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req CreateTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxTokenNameLength {
+		Error(w, http.StatusBadRequest, "Name must be between 1 and 255 characters")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		Error(w, http.StatusBadRequest, "Scopes must list at least one of: "+strings.Join(middleware.ValidScopes, ", "))
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(middleware.ValidScopes, scope) {
+			Error(w, http.StatusBadRequest, "Unknown scope "+strconv.Quote(scope)+", use one of: "+strings.Join(middleware.ValidScopes, ", "))
+			return
+		}
+	}
+	slices.Sort(req.Scopes)
+	req.Scopes = slices.Compact(req.Scopes)
+
+	ctx := r.Context()
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		Error(w, http.StatusUnauthorized, "Missing user ID")
+		return
+	}
+
+	// Ensure user exists, since tokens reference it
+	if err := h.usersRepo.GetOrCreateUser(ctx, userID); err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to create or get user")
+		return
+	}
+
+	raw, err := utils.GenerateAPIToken()
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	token, err := h.tokensRepo.CreateToken(ctx, userID, req.Name, utils.APITokenDisplayPrefix(raw), utils.HashAPIToken(raw), req.Scopes)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to save token")
+		return
+	}
+
+	JSONResponse(w, http.StatusCreated, CreateTokenResponse{
+		Token:            raw,
+		APITokenResponse: toAPITokenResponse(token),
+	})
+}
+
+// List handles GET /api/v1/tokens.
+// It returns the current user's active tokens, without the secret part.
+func (h *TokensHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	ctx := r.Context()
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		Error(w, http.StatusUnauthorized, "Missing user ID")
+		return
+	}
+
+	tokens, err := h.tokensRepo.ListTokens(ctx, userID)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to fetch tokens")
+		return
+	}
+
+	response := ListTokensResponse{Tokens: make([]APITokenResponse, 0, len(tokens))}
+	for i := range tokens {
+		response.Tokens = append(response.Tokens, toAPITokenResponse(&tokens[i]))
+	}
+
+	JSONResponse(w, http.StatusOK, response)
+}
+
+// Revoke handles DELETE /api/v1/tokens?id=....
+// It revokes one of the current user's tokens. Requests using it fail right away.
+func (h *TokensHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tokenID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || tokenID < 1 {
+		Error(w, http.StatusBadRequest, "Missing or invalid id query parameter")
+		return
+	}
+
+	ctx := r.Context()
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		Error(w, http.StatusUnauthorized, "Missing user ID")
+		return
+	}
+
+	if err := h.tokensRepo.RevokeToken(ctx, userID, tokenID); err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			Error(w, http.StatusNotFound, "Token not found")
+			return
+		}
+		Error(w, http.StatusInternalServerError, "Failed to revoke token")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func toAPITokenResponse(token *models.APIToken) APITokenResponse {
+	return APITokenResponse{
+		ID:         token.ID,
+		Name:       token.Name,
+		Prefix:     token.TokenPrefix,
+		Scopes:     token.Scopes,
+		CreatedAt:  token.CreatedAt,
+		LastUsedAt: token.LastUsedAt,
+	}
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/middleware"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+)
+
+// mockTokensRepository is a mock implementation of TokensRepositoryInterface for testing.
+type mockTokensRepository struct {
+	createTokenFunc func(ctx context.Context, userID string, name string, tokenPrefix string, tokenHash string, scopes []string) (*models.APIToken, error)
+	listTokensFunc  func(ctx context.Context, userID string) ([]models.APIToken, error)
+	revokeTokenFunc func(ctx context.Context, userID string, tokenID int64) error
+}
+
+func (m *mockTokensRepository) CreateToken(ctx context.Context, userID string, name string, tokenPrefix string, tokenHash string, scopes []string) (*models.APIToken, error) {
+	if m.createTokenFunc != nil {
+		return m.createTokenFunc(ctx, userID, name, tokenPrefix, tokenHash, scopes)
+	}
+	return nil, nil
+}
+
+func (m *mockTokensRepository) ListTokens(ctx context.Context, userID string) ([]models.APIToken, error) {
+	if m.listTokensFunc != nil {
+		return m.listTokensFunc(ctx, userID)
+	}
+	return nil, nil
+}
+
+func (m *mockTokensRepository) RevokeToken(ctx context.Context, userID string, tokenID int64) error {
+	if m.revokeTokenFunc != nil {
+		return m.revokeTokenFunc(ctx, userID, tokenID)
+	}
+	return nil
+}
+
+func (m *mockTokensRepository) UseToken(context.Context, string) (*models.APIToken, error) {
+	return nil, nil
+}
+
+func TestTokensHandler_Create(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    string
+		expectedStatus int
+	}{
+		{
+			name:           "successful creation",
+			requestBody:    `{"name": "Obsidian export", "scopes": ["ratings:read"]}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "both scopes",
+			requestBody:    `{"name": "Bulk rater", "scopes": ["ratings:write", "ratings:read"]}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "missing name",
+			requestBody:    `{"name": "  ", "scopes": ["ratings:read"]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing scopes",
+			requestBody:    `{"name": "Script"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown scope",
+			requestBody:    `{"name": "Script", "scopes": ["admin"]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid body",
+			requestBody:    `not json`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var storedHash string
+			mockTokensRepo := &mockTokensRepository{
+				createTokenFunc: func(ctx context.Context, userID string, name string, tokenPrefix string, tokenHash string, scopes []string) (*models.APIToken, error) {
+					storedHash = tokenHash
+					return &models.APIToken{ID: 1, UserID: userID, Name: name, TokenPrefix: tokenPrefix, TokenHash: tokenHash, Scopes: scopes, CreatedAt: time.Now()}, nil
+				},
+			}
+
+			handler := NewTokensHandler(mockTokensRepo, &mockUsersRepository{})
+			handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.Create))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/tokens", strings.NewReader(tt.requestBody))
+			req.Header.Set("X-User-ID", "test-user-id")
+
+			rr := httptest.NewRecorder()
+			handlerFunc.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if rr.Code != http.StatusCreated {
+				return
+			}
+
+			var response CreateTokenResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(response.Token, "wa_") || !strings.HasPrefix(response.Token, response.Prefix) {
+				t.Errorf("Unexpected token %q with prefix %q", response.Token, response.Prefix)
+			}
+			if storedHash == "" || strings.Contains(storedHash, response.Token) {
+				t.Errorf("Expected only a hash of the token to be stored, got %q", storedHash)
+			}
+		})
+	}
+}
+
+func TestTokensHandler_List(t *testing.T) {
+	lastUsed := time.Now()
+	mockTokensRepo := &mockTokensRepository{
+		listTokensFunc: func(ctx context.Context, userID string) ([]models.APIToken, error) {
+			return []models.APIToken{
+				{ID: 2, Name: "Script", TokenPrefix: "wa_abcdefgh", TokenHash: "secret-hash", Scopes: []string{"ratings:read"}, LastUsedAt: &lastUsed},
+				{ID: 1, Name: "Old", TokenPrefix: "wa_12345678", TokenHash: "secret-hash", Scopes: []string{"ratings:write"}},
+			}, nil
+		},
+	}
+
+	handler := NewTokensHandler(mockTokensRepo, &mockUsersRepository{})
+	handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.List))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tokens", nil)
+	req.Header.Set("X-User-ID", "test-user-id")
+	rr := httptest.NewRecorder()
+	handlerFunc.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "secret-hash") {
+		t.Error("Expected token hashes to stay out of the response")
+	}
+	var response ListTokensResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Tokens) != 2 {
+		t.Errorf("Expected 2 tokens, got %d", len(response.Tokens))
+	}
+}
+
+func TestTokensHandler_Revoke(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		revokeErr      error
+		expectedStatus int
+	}{
+		{
+			name:           "successful revocation",
+			query:          "?id=1",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "unknown token",
+			query:          "?id=99",
+			revokeErr:      repository.ErrTokenNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "missing id",
+			query:          "",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid id",
+			query:          "?id=abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTokensRepo := &mockTokensRepository{
+				revokeTokenFunc: func(ctx context.Context, userID string, tokenID int64) error {
+					return tt.revokeErr
+				},
+			}
+
+			handler := NewTokensHandler(mockTokensRepo, &mockUsersRepository{})
+			handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.Revoke))
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/tokens"+tt.query, nil)
+			req.Header.Set("X-User-ID", "test-user-id")
+			rr := httptest.NewRecorder()
+			handlerFunc.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/article"
+	"github.com/vdavid/web-annotator/backend/internal/middleware"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+const (
+	maxWebhookURLLength     = 2048
+	minWebhookSecretLength  = 16
+	maxWebhookSecretLength  = 255 // Matches the webhook_subscriptions.secret column
+	defaultDeadLettersLimit = 50
+	maxDeadLettersLimit     = 200
+)
+
+// WebhooksHandler handles webhook subscription endpoints.
+type WebhooksHandler struct {
+	webhooksRepo repository.WebhooksRepositoryInterface
+	usersRepo    repository.UsersRepositoryInterface
+}
+
+// NewWebhooksHandler creates a new webhooks handler.
+func NewWebhooksHandler(webhooksRepo repository.WebhooksRepositoryInterface, usersRepo repository.UsersRepositoryInterface) *WebhooksHandler {
+	return &WebhooksHandler{webhooksRepo: webhooksRepo, usersRepo: usersRepo}
+}
+
+// CreateWebhookRequest represents the request body for creating a webhook subscription.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"` // Optional, we generate one if it's empty
+	Events []string `json:"events"`
+}
+
+// WebhookResponse describes a webhook subscription without its secret.
+type WebhookResponse struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateWebhookResponse is returned once, on creation. It's the only time the secret is shown.
+type CreateWebhookResponse struct {
+	Secret string `json:"secret"`
+	WebhookResponse
+}
+
+// ListWebhooksResponse represents the response for listing webhook subscriptions.
+type ListWebhooksResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+// DeadLettersResponse represents the response for the dead-letter view.
+type DeadLettersResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+}
+
+// WebhookDeliveryResponse describes one delivery and how it went.
+type WebhookDeliveryResponse struct {
+	ID        int64           `json:"id"`
+	WebhookID int64           `json:"webhook_id"`
+	URL       string          `json:"url"`
+	Event     string          `json:"event"`
+	Data      json.RawMessage `json:"data"`
+	Attempts  int             `json:"attempts"`
+	LastError *string         `json:"last_error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Create handles POST /api/v1/webhooks.
+// It subscribes a URL to some of the current user's rating events and returns the signing secret, once.
+func (h *WebhooksHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.URL = strings.TrimSpace(req.URL)
+	if parsed, err := url.Parse(req.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || len(req.URL) > maxWebhookURLLength {
+		Error(w, http.StatusBadRequest, "URL must be an http or https URL of at most 2048 characters")
+		return
+	} else if !article.IsPublicHost(parsed.Hostname()) {
+		// Only a first check, the worker also checks the address it connects to, after DNS
+		Error(w, http.StatusBadRequest, "URL must point to a public host")
+		return
+	}
+	if req.Secret != "" && (len(req.Secret) < minWebhookSecretLength || len(req.Secret) > maxWebhookSecretLength) {
+		Error(w, http.StatusBadRequest, "Secret must be between 16 and 255 characters, or left out to get a generated one")
+		return
+	}
+	if len(req.Events) == 0 {
+		Error(w, http.StatusBadRequest, "Events must list at least one of: "+strings.Join(models.WebhookEventTypes, ", "))
+		return
+	}
+	for _, event := range req.Events {
+		if !slices.Contains(models.WebhookEventTypes, event) {
+			Error(w, http.StatusBadRequest, "Unknown event "+strconv.Quote(event)+", use one of: "+strings.Join(models.WebhookEventTypes, ", "))
+			return
+		}
+	}
+	slices.Sort(req.Events)
+	req.Events = slices.Compact(req.Events)
+
+	ctx := r.Context()
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		Error(w, http.StatusUnauthorized, "Missing user ID")
+		return
+	}
+
+	// Ensure user exists, since subscriptions reference it
+	if err := h.usersRepo.GetOrCreateUser(ctx, userID); err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to create or get user")
+		return
+	}
+
+	if req.Secret == "" {
+		secret, err := utils.GenerateWebhookSecret()
+		if err != nil {
+			Error(w, http.StatusInternalServerError, "Failed to generate secret")
+			return
+		}
+		req.Secret = secret
+	}
+
+	subscription, err := h.webhooksRepo.CreateSubscription(ctx, userID, req.URL, req.Secret, req.Events)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to save webhook")
+		return
+	}
+
+	JSONResponse(w, http.StatusCreated, CreateWebhookResponse{
+		Secret:          subscription.Secret,
+		WebhookResponse: toWebhookResponse(subscription),
+	})
+}
+
+// List handles GET /api/v1/webhooks.
+// It returns the current user's webhook subscriptions, without their secrets.
+func (h *WebhooksHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	ctx := r.Context()
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		Error(w, http.StatusUnauthorized, "Missing user ID")
+		return
+	}
+
+	subscriptions, err := h.webhooksRepo.ListSubscriptions(ctx, userID)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to fetch webhooks")
+		return
+	}
+
+	response := ListWebhooksResponse{Webhooks: make([]WebhookResponse, 0, len(subscriptions))}
+	for i := range subscriptions {
+		response.Webhooks = append(response.Webhooks, toWebhookResponse(&subscriptions[i]))
+	}
+
+	JSONResponse(w, http.StatusOK, response)
+}
+
+// Delete handles DELETE /api/v1/webhooks?id=....
+// It deletes one of the current user's webhook subscriptions. Events not delivered yet are dropped.
+func (h *WebhooksHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	subscriptionID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || subscriptionID < 1 {
+		Error(w, http.StatusBadRequest, "Missing or invalid id query parameter")
+		return
+	}
+
+	ctx := r.Context()
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		Error(w, http.StatusUnauthorized, "Missing user ID")
+		return
+	}
+
+	if err := h.webhooksRepo.DeleteSubscription(ctx, userID, subscriptionID); err != nil {
+		if errors.Is(err, repository.ErrWebhookNotFound) {
+			Error(w, http.StatusNotFound, "Webhook not found")
+			return
+		}
+		Error(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeadLetters handles GET /api/v1/webhooks/dead-letters?limit=....
+// It returns the current user's deliveries that failed every attempt, newest first.
+func (h *WebhooksHandler) DeadLetters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	limit := defaultDeadLettersLimit
+	if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed < 1 || parsed > maxDeadLettersLimit {
+			Error(w, http.StatusBadRequest, "limit must be a number between 1 and "+strconv.Itoa(maxDeadLettersLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	ctx := r.Context()
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		Error(w, http.StatusUnauthorized, "Missing user ID")
+		return
+	}
+
+	deliveries, err := h.webhooksRepo.ListDeadDeliveries(ctx, userID, limit)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to fetch dead letters")
+		return
+	}
+
+	response := DeadLettersResponse{Deliveries: make([]WebhookDeliveryResponse, 0, len(deliveries))}
+	for _, delivery := range deliveries {
+		response.Deliveries = append(response.Deliveries, WebhookDeliveryResponse{
+			ID:        delivery.ID,
+			WebhookID: delivery.SubscriptionID,
+			URL:       delivery.URL,
+			Event:     delivery.EventType,
+			Data:      delivery.Payload,
+			Attempts:  delivery.Attempts,
+			LastError: delivery.LastError,
+			CreatedAt: delivery.CreatedAt,
+		})
+	}
+
+	JSONResponse(w, http.StatusOK, response)
+}
+
+func toWebhookResponse(subscription *models.WebhookSubscription) WebhookResponse {
+	return WebhookResponse{
+		ID:        subscription.ID,
+		URL:       subscription.URL,
+		Events:    subscription.Events,
+		CreatedAt: subscription.CreatedAt,
+	}
+}
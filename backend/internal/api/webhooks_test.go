@@ -0,0 +1,311 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/middleware"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+)
+
+// mockWebhooksRepository is a mock implementation of WebhooksRepositoryInterface for testing.
+type mockWebhooksRepository struct {
+	createSubscriptionFunc func(ctx context.Context, userID string, url string, secret string, events []string) (*models.WebhookSubscription, error)
+	listSubscriptionsFunc  func(ctx context.Context, userID string) ([]models.WebhookSubscription, error)
+	deleteSubscriptionFunc func(ctx context.Context, userID string, subscriptionID int64) error
+	listDeadDeliveriesFunc func(ctx context.Context, userID string, limit int) ([]models.WebhookDelivery, error)
+}
+
+func (m *mockWebhooksRepository) CreateSubscription(ctx context.Context, userID string, url string, secret string, events []string) (*models.WebhookSubscription, error) {
+	if m.createSubscriptionFunc != nil {
+		return m.createSubscriptionFunc(ctx, userID, url, secret, events)
+	}
+	return nil, nil
+}
+
+func (m *mockWebhooksRepository) ListSubscriptions(ctx context.Context, userID string) ([]models.WebhookSubscription, error) {
+	if m.listSubscriptionsFunc != nil {
+		return m.listSubscriptionsFunc(ctx, userID)
+	}
+	return nil, nil
+}
+
+func (m *mockWebhooksRepository) DeleteSubscription(ctx context.Context, userID string, subscriptionID int64) error {
+	if m.deleteSubscriptionFunc != nil {
+		return m.deleteSubscriptionFunc(ctx, userID, subscriptionID)
+	}
+	return nil
+}
+
+func (m *mockWebhooksRepository) ListDeadDeliveries(ctx context.Context, userID string, limit int) ([]models.WebhookDelivery, error) {
+	if m.listDeadDeliveriesFunc != nil {
+		return m.listDeadDeliveriesFunc(ctx, userID, limit)
+	}
+	return nil, nil
+}
+
+func TestWebhooksHandler_Create(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    string
+		expectedStatus int
+		expectedSecret string // Empty means a generated one
+		expectedEvents []string
+	}{
+		{
+			name:           "generated secret",
+			requestBody:    `{"url": "https://chat.example.com/hooks/123", "events": ["rating.created"]}`,
+			expectedStatus: http.StatusCreated,
+			expectedEvents: []string{"rating.created"},
+		},
+		{
+			name:           "own secret and duplicate events",
+			requestBody:    `{"url": "http://reading-list.example.org/ingest", "secret": "0123456789abcdef", "events": ["rating.updated", "rating.created", "rating.updated"]}`,
+			expectedStatus: http.StatusCreated,
+			expectedSecret: "0123456789abcdef",
+			expectedEvents: []string{"rating.created", "rating.updated"},
+		},
+		{
+			name:           "not http",
+			requestBody:    `{"url": "ftp://example.com/hook", "events": ["rating.created"]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "relative url",
+			requestBody:    `{"url": "/hook", "events": ["rating.created"]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "private address",
+			requestBody:    `{"url": "http://192.168.1.10:8080/hook", "events": ["rating.created"]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "internal host name",
+			requestBody:    `{"url": "http://grafana.internal/hook", "events": ["rating.created"]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "short secret",
+			requestBody:    `{"url": "https://example.com/hook", "secret": "hunter2", "events": ["rating.created"]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing events",
+			requestBody:    `{"url": "https://example.com/hook"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown event",
+			requestBody:    `{"url": "https://example.com/hook", "events": ["page.created"]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid body",
+			requestBody:    `not json`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var storedEvents []string
+			mockWebhooksRepo := &mockWebhooksRepository{
+				createSubscriptionFunc: func(ctx context.Context, userID string, url string, secret string, events []string) (*models.WebhookSubscription, error) {
+					storedEvents = events
+					return &models.WebhookSubscription{ID: 1, UserID: userID, URL: url, Secret: secret, Events: events, CreatedAt: time.Now()}, nil
+				},
+			}
+
+			handler := NewWebhooksHandler(mockWebhooksRepo, &mockUsersRepository{})
+			handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.Create))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(tt.requestBody))
+			req.Header.Set("X-User-ID", "test-user-id")
+
+			rr := httptest.NewRecorder()
+			handlerFunc.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if rr.Code != http.StatusCreated {
+				return
+			}
+
+			var response CreateWebhookResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if tt.expectedSecret == "" && !strings.HasPrefix(response.Secret, "whsec_") {
+				t.Errorf("Expected a generated secret, got %q", response.Secret)
+			}
+			if tt.expectedSecret != "" && response.Secret != tt.expectedSecret {
+				t.Errorf("Expected secret %q, got %q", tt.expectedSecret, response.Secret)
+			}
+			if strings.Join(storedEvents, ",") != strings.Join(tt.expectedEvents, ",") {
+				t.Errorf("Expected events %v, got %v", tt.expectedEvents, storedEvents)
+			}
+		})
+	}
+}
+
+func TestWebhooksHandler_List(t *testing.T) {
+	mockWebhooksRepo := &mockWebhooksRepository{
+		listSubscriptionsFunc: func(ctx context.Context, userID string) ([]models.WebhookSubscription, error) {
+			return []models.WebhookSubscription{
+				{ID: 2, URL: "https://chat.example.com/hooks/123", Secret: "whsec_topsecret", Events: []string{"rating.created"}},
+				{ID: 1, URL: "https://example.com/hook", Secret: "whsec_topsecret", Events: models.WebhookEventTypes},
+			}, nil
+		},
+	}
+
+	handler := NewWebhooksHandler(mockWebhooksRepo, &mockUsersRepository{})
+	handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.List))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/webhooks", nil)
+	req.Header.Set("X-User-ID", "test-user-id")
+	rr := httptest.NewRecorder()
+	handlerFunc.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "topsecret") {
+		t.Error("Expected secrets to stay out of the response")
+	}
+	var response ListWebhooksResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Webhooks) != 2 {
+		t.Errorf("Expected 2 webhooks, got %d", len(response.Webhooks))
+	}
+}
+
+func TestWebhooksHandler_Delete(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		deleteErr      error
+		expectedStatus int
+	}{
+		{
+			name:           "successful deletion",
+			query:          "?id=1",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "unknown webhook",
+			query:          "?id=99",
+			deleteErr:      repository.ErrWebhookNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "missing id",
+			query:          "",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWebhooksRepo := &mockWebhooksRepository{
+				deleteSubscriptionFunc: func(ctx context.Context, userID string, subscriptionID int64) error {
+					return tt.deleteErr
+				},
+			}
+
+			handler := NewWebhooksHandler(mockWebhooksRepo, &mockUsersRepository{})
+			handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.Delete))
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/webhooks"+tt.query, nil)
+			req.Header.Set("X-User-ID", "test-user-id")
+			rr := httptest.NewRecorder()
+			handlerFunc.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestWebhooksHandler_DeadLetters(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedLimit  int
+	}{
+		{
+			name:           "default limit",
+			expectedStatus: http.StatusOK,
+			expectedLimit:  defaultDeadLettersLimit,
+		},
+		{
+			name:           "custom limit",
+			query:          "?limit=5",
+			expectedStatus: http.StatusOK,
+			expectedLimit:  5,
+		},
+		{
+			name:           "limit too high",
+			query:          "?limit=1000",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := "HTTP 503"
+			var gotLimit int
+			mockWebhooksRepo := &mockWebhooksRepository{
+				listDeadDeliveriesFunc: func(ctx context.Context, userID string, limit int) ([]models.WebhookDelivery, error) {
+					gotLimit = limit
+					return []models.WebhookDelivery{{
+						ID:             3,
+						SubscriptionID: 1,
+						URL:            "https://example.com/hook",
+						EventType:      models.WebhookEventRatingDeleted,
+						Payload:        json.RawMessage(`{"url": "https://example.com/article", "score": null}`),
+						Status:         models.WebhookDeliveryDead,
+						Attempts:       10,
+						LastError:      &reason,
+					}}, nil
+				},
+			}
+
+			handler := NewWebhooksHandler(mockWebhooksRepo, &mockUsersRepository{})
+			handlerFunc := middleware.AuthMiddleware(middleware.HeaderAuthenticator{}, nil)(http.HandlerFunc(handler.DeadLetters))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/webhooks/dead-letters"+tt.query, nil)
+			req.Header.Set("X-User-ID", "test-user-id")
+			rr := httptest.NewRecorder()
+			handlerFunc.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+			if gotLimit != tt.expectedLimit {
+				t.Errorf("Expected limit %d, got %d", tt.expectedLimit, gotLimit)
+			}
+			var response DeadLettersResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Deliveries) != 1 || *response.Deliveries[0].LastError != reason {
+				t.Errorf("Unexpected deliveries: %+v", response.Deliveries)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package article
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+// Reasons for a classification. Clients get them as is, so they can explain why a page can or can't be rated.
+const (
+	ReasonDomainAllowed = "domain_allowed"  // A domain rule says everything on the page's domain is an article
+	ReasonDomainDenied  = "domain_denied"   // A domain rule says nothing on the page's domain is an article
+	ReasonOGType        = "og_type_article" // The page has og:type=article
+	ReasonJSONLD        = "jsonld_article"  // The page has a JSON-LD Article or NewsArticle
+	ReasonURLDepth      = "url_depth"       // The URL path is more than two segments deep, like /2025/10/my-post
+	ReasonUnverified    = "unverified"      // We haven't managed to fetch the page, and its URL alone doesn't tell
+	ReasonNotArticle    = "not_article"     // We fetched the page, and nothing says it's an article
+)
+
+// Rules configure a Classifier.
+type Rules struct {
+	AllowDomains []string // Everything on these domains and their subdomains is an article
+	DenyDomains  []string // Nothing on these domains and their subdomains is an article
+	// RequireVerified makes pages we haven't fetched yet non-articles, unless their URL or a domain rule says otherwise
+	RequireVerified bool
+}
+
+// Classification is whether a page is an article, and why.
+type Classification struct {
+	IsArticle bool
+	Reason    string
+}
+
+// Classifier decides whether a page is an article, with the same signals as the extension's article detector:
+// og:type, JSON-LD types, and URL path depth. Any one of them is enough. Domain rules override them.
+type Classifier struct {
+	allow []string
+	deny  []string
+
+	requireVerified bool
+}
+
+// NewClassifier creates a classifier with the given rules.
+func NewClassifier(rules Rules) *Classifier {
+	return &Classifier{
+		allow:           cleanDomains(rules.AllowDomains),
+		deny:            cleanDomains(rules.DenyDomains),
+		requireVerified: rules.RequireVerified,
+	}
+}
+
+// Classify decides whether the page at a normalized URL is an article. signals may be nil for a page we don't know.
+func (c *Classifier) Classify(normalizedURL string, signals *models.ArticleSignals) Classification {
+	if signals == nil {
+		signals = &models.ArticleSignals{}
+	}
+	parsed, err := url.Parse(normalizedURL)
+	if err != nil {
+		return Classification{IsArticle: false, Reason: ReasonNotArticle}
+	}
+
+	// The most specific rule wins, so a denied domain can have an allowed subdomain and vice versa
+	host := strings.ToLower(parsed.Hostname())
+	allowed, denied := longestMatch(host, c.allow), longestMatch(host, c.deny)
+	if denied > 0 && denied >= allowed {
+		return Classification{IsArticle: false, Reason: ReasonDomainDenied}
+	}
+	if allowed > 0 {
+		return Classification{IsArticle: true, Reason: ReasonDomainAllowed}
+	}
+
+	switch {
+	case signals.OGType != nil && *signals.OGType == "article":
+		return Classification{IsArticle: true, Reason: ReasonOGType}
+	case slices.ContainsFunc(signals.JSONLDTypes, IsArticleType):
+		return Classification{IsArticle: true, Reason: ReasonJSONLD}
+	case pathDepth(parsed.Path) > 2:
+		return Classification{IsArticle: true, Reason: ReasonURLDepth}
+	case !signals.Fetched:
+		return Classification{IsArticle: !c.requireVerified, Reason: ReasonUnverified}
+	default:
+		return Classification{IsArticle: false, Reason: ReasonNotArticle}
+	}
+}
+
+// pathDepth counts the non-empty segments of a URL path.
+func pathDepth(path string) int {
+	depth := 0
+	for segment := range strings.SplitSeq(path, "/") {
+		if segment != "" {
+			depth++
+		}
+	}
+	return depth
+}
+
+// longestMatch returns the length of the longest domain that host is or is a subdomain of, or 0 if there's none.
+func longestMatch(host string, domains []string) int {
+	longest := 0
+	for _, domain := range domains {
+		if (host == domain || strings.HasSuffix(host, "."+domain)) && len(domain) > longest {
+			longest = len(domain)
+		}
+	}
+	return longest
+}
+
+// cleanDomains lowercases domains and drops the "www." prefix, which normalized URLs don't have.
+func cleanDomains(domains []string) []string {
+	cleaned := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+		if domain != "" {
+			cleaned = append(cleaned, domain)
+		}
+	}
+	return cleaned
+}
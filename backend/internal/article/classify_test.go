@@ -0,0 +1,52 @@
+package article
+
+import (
+	"testing"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+func TestClassifier_Classify(t *testing.T) {
+	articleType, websiteType := "article", "website"
+	rules := Rules{
+		AllowDomains: []string{"blog.example", "news.medium.com"},
+		DenyDomains:  []string{"WWW.Video.example", "medium.com"},
+	}
+	unfetched := &models.ArticleSignals{}
+	fetchedNothing := &models.ArticleSignals{Fetched: true}
+
+	tests := []struct {
+		name            string
+		rules           Rules
+		url             string
+		signals         *models.ArticleSignals
+		expected        Classification
+		requireVerified bool
+	}{
+		{"og:type", rules, "https://example.com/post", &models.ArticleSignals{OGType: &articleType, Fetched: true}, Classification{true, ReasonOGType}, false},
+		{"JSON-LD", rules, "https://example.com/post", &models.ArticleSignals{JSONLDTypes: []string{"WebPage", "NewsArticle"}, Fetched: true}, Classification{true, ReasonJSONLD}, false},
+		{"other JSON-LD", rules, "https://example.com/post", &models.ArticleSignals{OGType: &websiteType, JSONLDTypes: []string{"Organization"}, Fetched: true}, Classification{false, ReasonNotArticle}, false},
+		{"deep URL", rules, "https://example.com/2025/10/my-post", unfetched, Classification{true, ReasonURLDepth}, false},
+		{"deep URL with a trailing slash and a query", rules, "https://example.com/a/b/c/?page=2", fetchedNothing, Classification{true, ReasonURLDepth}, false},
+		{"two segments aren't deep", rules, "https://example.com/blog/post", fetchedNothing, Classification{false, ReasonNotArticle}, false},
+		{"unknown page", rules, "https://example.com/post", nil, Classification{true, ReasonUnverified}, false},
+		{"unfetched page", rules, "https://example.com/post", unfetched, Classification{true, ReasonUnverified}, false},
+		{"unfetched page when verification is required", rules, "https://example.com/post", unfetched, Classification{false, ReasonUnverified}, true},
+		{"allowed domain", rules, "https://blog.example/about", fetchedNothing, Classification{true, ReasonDomainAllowed}, false},
+		{"allowed subdomain", rules, "https://jane.blog.example", fetchedNothing, Classification{true, ReasonDomainAllowed}, false},
+		{"denied domain beats signals", rules, "https://video.example/2025/10/clip", &models.ArticleSignals{OGType: &articleType, Fetched: true}, Classification{false, ReasonDomainDenied}, false},
+		{"allowed subdomain of a denied domain", rules, "https://news.medium.com/story", fetchedNothing, Classification{true, ReasonDomainAllowed}, false},
+		{"denied parent domain", rules, "https://medium.com/@jane/story", &models.ArticleSignals{OGType: &articleType, Fetched: true}, Classification{false, ReasonDomainDenied}, false},
+		{"lookalike domain isn't covered", rules, "https://notmedium.com/story", unfetched, Classification{true, ReasonUnverified}, false},
+		{"port doesn't matter", rules, "https://video.example:8443/a/b/c", unfetched, Classification{false, ReasonDomainDenied}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rules.RequireVerified = tt.requireVerified
+			if got := NewClassifier(tt.rules).Classify(tt.url, tt.signals); got != tt.expected {
+				t.Errorf("Classify(%s) = %+v, want %+v", tt.url, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+// Package article fetches pages and reads what they say about the article on them: Open Graph tags, JSON-LD, and
+// the canonical link.
+package article
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxTypes caps how many JSON-LD types we keep per page. Real pages have a handful.
+const maxTypes = 20
+
+// extraction collects every candidate value while walking the document. The winners are picked at the end,
+// since the sources can come in any order.
+type extraction struct {
+	htmlLang      string
+	documentTitle string
+	meta          map[string]string // First value of each meta property or name, lowercased key
+	canonical     string
+	jsonLDTypes   []string
+	jsonLDArticle map[string]any // The first Article-like JSON-LD item
+}
+
+// Extract reads article metadata, the og:type, the JSON-LD types, and the canonical URL from an HTML document.
+// pageURL is where the document came from, used to resolve a relative canonical link.
+// It's lenient: anything it can't make sense of is left unknown.
+func Extract(r io.Reader, pageURL *url.URL, now time.Time) (*models.FetchedPage, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &extraction{meta: make(map[string]string)}
+	e.walk(doc)
+	return e.result(pageURL, now), nil
+}
+
+// walk visits every element below n.
+func (e *extraction) walk(n *html.Node) {
+	if n.Type == html.ElementNode {
+		switch n.DataAtom {
+		case atom.Html:
+			if e.htmlLang == "" {
+				e.htmlLang = attr(n, "lang")
+			}
+		case atom.Title:
+			if e.documentTitle == "" {
+				e.documentTitle = text(n)
+			}
+		case atom.Meta:
+			e.readMeta(n)
+		case atom.Link:
+			if e.canonical == "" && slices.Contains(strings.Fields(strings.ToLower(attr(n, "rel"))), "canonical") {
+				e.canonical = attr(n, "href")
+			}
+		case atom.Script:
+			if strings.EqualFold(strings.TrimSpace(attr(n, "type")), "application/ld+json") {
+				e.readJSONLD(text(n))
+			}
+			return
+		case atom.Svg, atom.Template:
+			return
+		}
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		e.walk(child)
+	}
+}
+
+// readMeta records a meta tag's content. Open Graph uses "property", but plenty of pages put it in "name".
+func (e *extraction) readMeta(n *html.Node) {
+	content := strings.TrimSpace(attr(n, "content"))
+	if content == "" {
+		return
+	}
+	for _, key := range []string{attr(n, "property"), attr(n, "name")} {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if _, ok := e.meta[key]; key != "" && !ok {
+			e.meta[key] = content
+		}
+	}
+}
+
+// readJSONLD records the types of every item in a JSON-LD script, and keeps the first Article-like one.
+// Broken JSON is common and skipped.
+func (e *extraction) readJSONLD(source string) {
+	var data any
+	if err := json.Unmarshal([]byte(source), &data); err != nil {
+		return
+	}
+	for _, item := range jsonLDItems(data) {
+		types := jsonLDTypes(item)
+		for _, t := range types {
+			if len(e.jsonLDTypes) < maxTypes && !slices.Contains(e.jsonLDTypes, t) {
+				e.jsonLDTypes = append(e.jsonLDTypes, t)
+			}
+		}
+		if e.jsonLDArticle == nil && slices.ContainsFunc(types, IsArticleType) {
+			e.jsonLDArticle = item
+		}
+	}
+}
+
+// result picks the best value for each field.
+func (e *extraction) result(pageURL *url.URL, now time.Time) *models.FetchedPage {
+	article := e.jsonLDArticle
+	page := &models.FetchedPage{
+		Metadata: models.PageMetadata{
+			Title:    CleanText(firstNonEmpty(e.meta["og:title"], e.documentTitle), MaxTitleLength),
+			SiteName: CleanText(firstNonEmpty(e.meta["og:site_name"], jsonLDName(article["publisher"])), MaxNameLength),
+			Author:   CleanText(firstNonEmpty(e.metaAuthor(), jsonLDName(article["author"])), MaxNameLength),
+			PublishedAt: ParsePublishedTime(
+				firstNonEmpty(e.meta["article:published_time"], jsonLDString(article["datePublished"])), now),
+			Language: CleanLanguage(firstNonEmpty(e.htmlLang, jsonLDString(article["inLanguage"]), e.meta["og:locale"])),
+			Headline: CleanText(jsonLDString(article["headline"]), MaxTitleLength),
+		},
+		JSONLDTypes: e.jsonLDTypes,
+	}
+	if ogType := strings.ToLower(strings.TrimSpace(e.meta["og:type"])); ogType != "" {
+		page.OGType = CleanText(ogType, MaxNameLength)
+	}
+	if e.canonical != "" && pageURL != nil {
+		if canonical, err := pageURL.Parse(strings.TrimSpace(e.canonical)); err == nil && (canonical.Scheme == "http" || canonical.Scheme == "https") {
+			canonical.Fragment = ""
+			value := canonical.String()
+			page.CanonicalURL = &value
+		}
+	}
+	return page
+}
+
+// metaAuthor returns the author from meta tags. article:author is often a profile URL rather than a name,
+// which isn't what we want.
+func (e *extraction) metaAuthor() string {
+	for _, key := range []string{"author", "article:author"} {
+		value := e.meta[key]
+		if value != "" && !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+			return value
+		}
+	}
+	return ""
+}
+
+// IsArticleType reports whether a JSON-LD type is one the extension treats as an article.
+func IsArticleType(t string) bool {
+	return t == "Article" || t == "NewsArticle"
+}
+
+// jsonLDItems flattens a JSON-LD document into its items: a single object, an array of them, or an @graph.
+func jsonLDItems(data any) []map[string]any {
+	var items []map[string]any
+	switch value := data.(type) {
+	case []any:
+		for _, item := range value {
+			items = append(items, jsonLDItems(item)...)
+		}
+	case map[string]any:
+		if graph, ok := value["@graph"]; ok {
+			items = append(items, jsonLDItems(graph)...)
+		}
+		if _, ok := value["@type"]; ok {
+			items = append(items, value)
+		}
+	}
+	return items
+}
+
+// jsonLDTypes returns an item's @type, which can be a string or an array of strings.
+func jsonLDTypes(item map[string]any) []string {
+	switch value := item["@type"].(type) {
+	case string:
+		return []string{value}
+	case []any:
+		var types []string
+		for _, t := range value {
+			if s, ok := t.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// jsonLDName returns the name of a JSON-LD person or organization, which can be a plain string, an object with a
+// name, or an array of either. For an array, it's the first name.
+func jsonLDName(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]any:
+		return jsonLDString(v["name"])
+	case []any:
+		for _, item := range v {
+			if name := jsonLDName(item); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// jsonLDString returns a JSON-LD value if it's a string, or "".
+func jsonLDString(value any) string {
+	s, _ := value.(string)
+	return s
+}
+
+// attr returns an attribute's value, or "" if it's missing.
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// text returns the text inside an element.
+func text(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.TextNode {
+			b.WriteString(child.Data)
+		}
+	}
+	return b.String()
+}
+
+// firstNonEmpty returns the first value that isn't blank.
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
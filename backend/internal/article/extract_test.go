@@ -0,0 +1,144 @@
+package article
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+// newsArticlePage is a typical news article: Open Graph tags, a JSON-LD NewsArticle, and a relative canonical link.
+const newsArticlePage = `<!DOCTYPE html>
+<html lang="en-GB">
+<head>
+	<title>Fallback title | The Daily</title>
+	<meta property="og:title" content="  Rivers are
+		getting warmer ">
+	<meta property="og:site_name" content="The Daily">
+	<meta property="og:type" content="Article">
+	<meta property="article:author" content="https://thedaily.example/authors/jane">
+	<meta property="article:published_time" content="2025-05-30T09:15:00+02:00">
+	<link rel="canonical" href="/science/2025/rivers#top">
+	<script type="application/ld+json">
+	[
+		{"@type": "BreadcrumbList", "itemListElement": []},
+		{
+			"@context": "https://schema.org",
+			"@type": "NewsArticle",
+			"headline": "Rivers are getting warmer, study finds",
+			"author": [{"@type": "Person", "name": "Jane Doe"}, {"@type": "Person", "name": "John Roe"}],
+			"publisher": {"@type": "Organization", "name": "Daily Media"},
+			"datePublished": "2025-05-29"
+		}
+	]
+	</script>
+</head>
+<body><p>Text</p></body>
+</html>`
+
+// blogPostPage uses an @graph, a name meta tag for the author, and no Open Graph title.
+const blogPostPage = `<html>
+<head>
+	<title>My post</title>
+	<meta name="author" content="Sam Smith">
+	<meta name="og:locale" content="pt_BR">
+	<script type="application/ld+json">{"@context": "https://schema.org", "@graph": [
+		{"@type": ["WebPage", "ItemPage"]},
+		{"@type": ["Article", "BlogPosting"], "headline": "My post", "inLanguage": "pt-BR", "author": "Sam"}
+	]}</script>
+	<script type="application/ld+json">{not json</script>
+</head>
+</html>`
+
+func TestExtract(t *testing.T) {
+	text := func(s string) *string { return &s }
+	date := func(year int, month time.Month, day, hour, minute int) *time.Time {
+		d := time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+		return &d
+	}
+
+	tests := []struct {
+		name     string
+		page     string
+		expected *models.FetchedPage
+	}{
+		{
+			name: "news article",
+			page: newsArticlePage,
+			expected: &models.FetchedPage{
+				Metadata: models.PageMetadata{
+					Title:       text("Rivers are getting warmer"),
+					SiteName:    text("The Daily"),
+					Author:      text("Jane Doe"),
+					PublishedAt: date(2025, 5, 30, 7, 15),
+					Language:    text("en-GB"),
+					Headline:    text("Rivers are getting warmer, study finds"),
+				},
+				OGType:       text("article"),
+				JSONLDTypes:  []string{"BreadcrumbList", "NewsArticle"},
+				CanonicalURL: text("https://thedaily.example/science/2025/rivers"),
+			},
+		},
+		{
+			name: "blog post with a graph",
+			page: blogPostPage,
+			expected: &models.FetchedPage{
+				Metadata: models.PageMetadata{
+					Title:    text("My post"),
+					Author:   text("Sam Smith"),
+					Language: text("pt-BR"),
+					Headline: text("My post"),
+				},
+				JSONLDTypes: []string{"WebPage", "ItemPage", "Article", "BlogPosting"},
+			},
+		},
+		{
+			name:     "no metadata",
+			page:     `<p>Just text`,
+			expected: &models.FetchedPage{},
+		},
+		{
+			name: "canonical with an unsafe scheme",
+			page: `<link rel="alternate canonical" href="javascript:alert(1)"><meta property="og:type" content="website">`,
+			expected: &models.FetchedPage{
+				OGType: text("website"),
+			},
+		},
+	}
+
+	pageURL, _ := url.Parse("https://thedaily.example/science/2025/rivers?ref=home")
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := Extract(strings.NewReader(tt.page), pageURL, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(page, tt.expected) {
+				t.Errorf("Extract() = %s, want %s", describe(page), describe(tt.expected))
+			}
+		})
+	}
+}
+
+// describe prints a fetched page with its pointers followed, for readable test failures.
+func describe(page *models.FetchedPage) string {
+	value := func(s *string) string {
+		if s == nil {
+			return "<nil>"
+		}
+		return "\"" + *s + "\""
+	}
+	published := "<nil>"
+	if page.Metadata.PublishedAt != nil {
+		published = page.Metadata.PublishedAt.Format(time.RFC3339)
+	}
+	m := page.Metadata
+	return "{title: " + value(m.Title) + ", site: " + value(m.SiteName) + ", author: " + value(m.Author) +
+		", published: " + published + ", language: " + value(m.Language) + ", headline: " + value(m.Headline) +
+		", og:type: " + value(page.OGType) + ", types: " + strings.Join(page.JSONLDTypes, ",") +
+		", canonical: " + value(page.CanonicalURL) + "}"
+}
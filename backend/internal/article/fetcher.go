@@ -0,0 +1,199 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"golang.org/x/net/html/charset"
+)
+
+// maxRedirects is how many redirects a fetch follows. Plenty for http to https to www and back.
+const maxRedirects = 5
+
+// userAgent identifies us to the sites we fetch.
+const userAgent = "WebAnnotatorBot/1 (+https://github.com/vdavid/web-annotator)"
+
+// ErrBlockedAddress is returned when a URL resolves to an address we must not fetch from, like a private network.
+var ErrBlockedAddress = errors.New("address is not public")
+
+// blockedPrefixes are ranges that pass netip's checks but still aren't the public internet, or that can tunnel to
+// addresses that aren't.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "This network"
+	netip.MustParsePrefix("100.64.0.0/10"),   // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // Documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // Documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // Documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // Reserved
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // Local-use NAT64
+	netip.MustParsePrefix("2001::/32"),       // Teredo
+	netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4
+}
+
+// IsPublicAddress reports whether an address is on the public internet, so fetching from it can't reach
+// our own network: not loopback, private, link-local, multicast, or otherwise special.
+func IsPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// reservedHostSuffixes are names that only mean something on a local network.
+var reservedHostSuffixes = []string{".localhost", ".local", ".internal", ".home.arpa", ".lan"}
+
+// IsPublicHost reports whether a URL's host can be on the public internet, without resolving it: an IP address must
+// be public, and a name must have a dot and not be on a local-only domain like localhost. Any name can still
+// resolve to any address, so connections need NewSafeTransport too. This just turns obvious mistakes away early.
+func IsPublicHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return IsPublicAddress(addr)
+	}
+	if !strings.Contains(host, ".") {
+		return false
+	}
+	for _, suffix := range reservedHostSuffixes {
+		if strings.HasSuffix("."+host, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// FetcherOptions configures a Fetcher.
+type FetcherOptions struct {
+	Timeout  time.Duration // For the whole fetch, redirects and body included
+	MaxBytes int64         // Bytes of the body we read. The rest of a bigger page is ignored.
+}
+
+// Fetcher downloads pages and extracts their metadata.
+// It only connects to public addresses, checked after DNS resolution on every connection, so neither a URL nor
+// a redirect nor a DNS record can point it into our own network.
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+	now      func() time.Time
+}
+
+// NewFetcher creates a fetcher.
+func NewFetcher(options FetcherOptions) *Fetcher {
+	return newFetcher(options, IsPublicAddress)
+}
+
+// newFetcher creates a fetcher that may only connect to addresses allow accepts. Tests use it to reach httptest servers.
+func newFetcher(options FetcherOptions, allow func(netip.Addr) bool) *Fetcher {
+	return &Fetcher{
+		client: &http.Client{
+			Transport: NewSafeTransport(options.Timeout, allow),
+			Timeout:   options.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+				}
+				return nil
+			},
+		},
+		maxBytes: options.MaxBytes,
+		now:      time.Now,
+	}
+}
+
+// NewSafeTransport creates a transport that may only connect to addresses allow accepts, which is IsPublicAddress
+// everywhere but in tests. Use it for every request to a URL a user gave us.
+func NewSafeTransport(timeout time.Duration, allow func(netip.Addr) bool) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		// Control runs after DNS resolution, right before connecting, so it sees the address we actually connect to
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+			}
+			if !allow(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	return &http.Transport{
+		Proxy:                  nil, // A proxy would connect on our behalf, past the address check
+		DialContext:            dialer.DialContext,
+		TLSHandshakeTimeout:    timeout,
+		ResponseHeaderTimeout:  timeout,
+		MaxResponseHeaderBytes: 64 << 10,
+		MaxIdleConns:           10,
+		IdleConnTimeout:        30 * time.Second,
+	}
+}
+
+// Fetch downloads a page and extracts its metadata. A page that isn't HTML, like a PDF, gives an empty result
+// rather than an error, since there's nothing to retry.
+func (f *Fetcher) Fetch(ctx context.Context, pageURL string) (*models.FetchedPage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page URL: %w", err)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", req.URL.Scheme)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if !isHTML(contentType) {
+		return &models.FetchedPage{}, nil
+	}
+
+	// A cut-off page still parses fine, and the head, where the metadata lives, comes first
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.maxBytes), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode page: %w", err)
+	}
+	page, err := Extract(body, resp.Request.URL, f.now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page: %w", err)
+	}
+	return page, nil
+}
+
+// isHTML reports whether a Content-Type is HTML. A missing one counts, since some servers leave it out.
+func isHTML(contentType string) bool {
+	if strings.TrimSpace(contentType) == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
@@ -0,0 +1,207 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestFetcher creates a fetcher that may reach httptest servers on loopback.
+func newTestFetcher(maxBytes int64) *Fetcher {
+	fetcher := newFetcher(FetcherOptions{Timeout: 5 * time.Second, MaxBytes: maxBytes}, func(addr netip.Addr) bool { return addr.IsLoopback() })
+	fetcher.now = func() time.Time { return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC) }
+	return fetcher
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != userAgent {
+			t.Errorf("Unexpected User-Agent: %q", r.Header.Get("User-Agent"))
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(newsArticlePage))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/article", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/latin1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		_, _ = w.Write([]byte("<title>Caf\xe9</title>"))
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<title>Huge</title>" + strings.Repeat("<p>filler</p>", 10000) + `<meta property="og:type" content="article">`))
+	})
+	mux.HandleFunc("/paper.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		_, _ = w.Write([]byte("%PDF-1.7"))
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Gone", http.StatusGone)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fetcher := newTestFetcher(64 << 10)
+	ctx := context.Background()
+
+	t.Run("article", func(t *testing.T) {
+		page, err := fetcher.Fetch(ctx, server.URL+"/article")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.OGType == nil || *page.OGType != "article" || page.Metadata.Headline == nil {
+			t.Errorf("Unexpected page: %s", describe(page))
+		}
+		if page.CanonicalURL == nil || *page.CanonicalURL != server.URL+"/science/2025/rivers" {
+			t.Errorf("Expected the canonical URL resolved against the server, got %s", describe(page))
+		}
+	})
+
+	t.Run("follows redirects", func(t *testing.T) {
+		page, err := fetcher.Fetch(ctx, server.URL+"/moved")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Metadata.Title == nil || *page.Metadata.Title != "Rivers are getting warmer" {
+			t.Errorf("Unexpected page: %s", describe(page))
+		}
+	})
+
+	t.Run("decodes the charset", func(t *testing.T) {
+		page, err := fetcher.Fetch(ctx, server.URL+"/latin1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Metadata.Title == nil || *page.Metadata.Title != "Café" {
+			t.Errorf("Unexpected page: %s", describe(page))
+		}
+	})
+
+	t.Run("reads only the start of a huge page", func(t *testing.T) {
+		page, err := fetcher.Fetch(ctx, server.URL+"/huge")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Metadata.Title == nil || page.OGType != nil {
+			t.Errorf("Expected the title but not the og:type past the cap, got %s", describe(page))
+		}
+	})
+
+	t.Run("skips pages that aren't HTML", func(t *testing.T) {
+		page, err := fetcher.Fetch(ctx, server.URL+"/paper.pdf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !page.Metadata.IsEmpty() || page.OGType != nil {
+			t.Errorf("Expected an empty page, got %s", describe(page))
+		}
+	})
+
+	for _, path := range []string{"/gone", "/loop"} {
+		t.Run("fails for "+path, func(t *testing.T) {
+			if _, err := fetcher.Fetch(ctx, server.URL+path); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestFetcher_BlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("The fetcher reached a loopback server")
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(FetcherOptions{Timeout: 5 * time.Second, MaxBytes: 64 << 10})
+	for _, pageURL := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		if _, err := fetcher.Fetch(context.Background(), pageURL); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Fetch(%s) error = %v, want ErrBlockedAddress", pageURL, err)
+		}
+	}
+}
+
+func TestFetcher_ChecksEveryRedirect(t *testing.T) {
+	// The redirector stands in for a public site, and [::1] for the private address it points to
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://[::1]/admin", http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	fetcher := newFetcher(FetcherOptions{Timeout: 5 * time.Second, MaxBytes: 64 << 10}, func(addr netip.Addr) bool {
+		return addr == netip.MustParseAddr("127.0.0.1")
+	})
+	if _, err := fetcher.Fetch(context.Background(), redirector.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Fetch() error = %v, want ErrBlockedAddress", err)
+	}
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		address  string
+		expected bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false}, // Cloud metadata
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"fc00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+		{"2002:a00:1::", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if got := IsPublicAddress(netip.MustParseAddr(tt.address)); got != tt.expected {
+				t.Errorf("IsPublicAddress(%s) = %v, want %v", tt.address, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsPublicHost(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected bool
+	}{
+		{"hooks.example.com", true},
+		{"Hooks.Example.COM.", true},
+		{"93.184.215.14", true},
+		{"127.0.0.1", false},
+		{"[::1]", false},
+		{"169.254.169.254", false},
+		{"localhost", false},
+		{"api.localhost", false},
+		{"postgres", false}, // A service on our own network
+		{"printer.local", false},
+		{"metadata.google.internal", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := IsPublicHost(tt.host); got != tt.expected {
+				t.Errorf("IsPublicHost(%s) = %v, want %v", tt.host, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/url"
+)
+
+// ErrNoRedirect is returned when a link doesn't redirect anywhere.
+var ErrNoRedirect = errors.New("link doesn't redirect")
+
+// redirectPruneInterval is how often RedirectResolver.Run deletes old redirects.
+const redirectPruneInterval = time.Hour
+
+// RedirectStore caches where links redirect to, so each is only requested once.
+type RedirectStore interface {
+	GetRedirect(ctx context.Context, link string) (*string, error)
+	SaveRedirect(ctx context.Context, link string, target string) error
+	DeleteRedirects(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+// RedirectResolver follows link redirects, like t.co's, one hop at a time. It's a url.Resolver.
+// It connects the same way the Fetcher does, only to public addresses.
+type RedirectResolver struct {
+	store  RedirectStore
+	client *http.Client
+}
+
+// NewRedirectResolver creates a resolver that caches in store and gives up on a link after timeout.
+func NewRedirectResolver(store RedirectStore, timeout time.Duration) *RedirectResolver {
+	return newRedirectResolver(store, timeout, IsPublicAddress)
+}
+
+// newRedirectResolver creates a resolver that may only connect to addresses allow accepts.
+// Tests use it to reach httptest servers.
+func newRedirectResolver(store RedirectStore, timeout time.Duration, allow func(netip.Addr) bool) *RedirectResolver {
+	return &RedirectResolver{
+		store: store,
+		client: &http.Client{
+			Transport: NewSafeTransport(timeout, allow),
+			Timeout:   timeout,
+			// We want the redirect itself, not where it leads
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// Cached returns a resolver that only answers from the store, see CachedResolver.
+func (r *RedirectResolver) Cached() *CachedResolver {
+	return &CachedResolver{store: r.store}
+}
+
+// Run deletes redirects resolved more than ttl ago right away and then every hour, until ctx is done.
+// Writes resolve those links again when they come up, while reads see their wrappers' own pages until then.
+func (r *RedirectResolver) Run(ctx context.Context, ttl time.Duration) {
+	ticker := time.NewTicker(redirectPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := r.store.DeleteRedirects(ctx, ttl); err != nil && ctx.Err() == nil {
+			log.Printf("Error: %v", err)
+		} else if deleted > 0 {
+			log.Printf("Deleted %d old redirect(s)", deleted)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Resolve returns where a link redirects to. It asks the store first, and saves what it finds there.
+func (r *RedirectResolver) Resolve(ctx context.Context, link string) (string, error) {
+	cached, err := r.store.GetRedirect(ctx, link)
+	if err != nil {
+		return "", err
+	}
+	if cached != nil {
+		return *cached, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid link: %w", err)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q", req.URL.Scheme)
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	location, err := resp.Location()
+	if errors.Is(err, http.ErrNoLocation) || resp.StatusCode < 300 || resp.StatusCode > 399 {
+		return "", fmt.Errorf("%w: HTTP %d", ErrNoRedirect, resp.StatusCode)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect: %w", err)
+	}
+	if location.Scheme != "http" && location.Scheme != "https" {
+		return "", fmt.Errorf("redirect to unsupported scheme %q", location.Scheme)
+	}
+
+	target := location.String()
+	// The link resolved fine, it just won't be cached
+	if err := r.store.SaveRedirect(ctx, link, target); err != nil {
+		log.Printf("Error: %v", err)
+	}
+	return target, nil
+}
+
+// CachedResolver resolves links only from what a RedirectResolver already saved, and never sends a request.
+// Read endpoints use it, so looking something up can't make the server wait on, or send requests to, other hosts.
+// It's a url.Resolver.
+type CachedResolver struct {
+	store RedirectStore
+}
+
+// Resolve returns where a link redirects to if the store knows, or url.ErrNotResolved if it doesn't.
+func (r *CachedResolver) Resolve(ctx context.Context, link string) (string, error) {
+	cached, err := r.store.GetRedirect(ctx, link)
+	if err != nil {
+		return "", err
+	}
+	if cached == nil {
+		return "", url.ErrNotResolved
+	}
+	return *cached, nil
+}
@@ -0,0 +1,152 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/url"
+)
+
+// mockRedirectStore is an in-memory RedirectStore.
+type mockRedirectStore struct {
+	redirects map[string]string
+	saveErr   error
+	pruned    []time.Duration
+}
+
+func (m *mockRedirectStore) GetRedirect(_ context.Context, link string) (*string, error) {
+	if target, ok := m.redirects[link]; ok {
+		return &target, nil
+	}
+	return nil, nil
+}
+
+func (m *mockRedirectStore) SaveRedirect(_ context.Context, link string, target string) error {
+	if m.saveErr != nil {
+		return m.saveErr
+	}
+	m.redirects[link] = target
+	return nil
+}
+
+func (m *mockRedirectStore) DeleteRedirects(_ context.Context, olderThan time.Duration) (int64, error) {
+	m.pruned = append(m.pruned, olderThan)
+	return 0, nil
+}
+
+func TestRedirectResolver_Resolve(t *testing.T) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/short", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("User-Agent") != userAgent {
+			t.Errorf("Unexpected User-Agent: %q", r.Header.Get("User-Agent"))
+		}
+		http.Redirect(w, r, "https://example.com/article?utm_source=x", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/relative", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/short", http.StatusFound)
+	})
+	mux.HandleFunc("/script", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "javascript:alert(1)")
+		w.WriteHeader(http.StatusFound)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<title>Not a redirect</title>"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	store := &mockRedirectStore{redirects: map[string]string{}}
+	resolver := newRedirectResolver(store, 5*time.Second, func(addr netip.Addr) bool { return addr.IsLoopback() })
+	ctx := context.Background()
+
+	t.Run("redirect", func(t *testing.T) {
+		for range 2 {
+			target, err := resolver.Resolve(ctx, server.URL+"/short")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if target != "https://example.com/article?utm_source=x" {
+				t.Errorf("Resolve() = %q", target)
+			}
+		}
+		if requests != 1 {
+			t.Errorf("Expected the second resolve to come from the store, got %d requests", requests)
+		}
+		if store.redirects[server.URL+"/short"] == "" {
+			t.Error("Expected the redirect to be stored")
+		}
+	})
+
+	t.Run("only one hop", func(t *testing.T) {
+		target, err := resolver.Resolve(ctx, server.URL+"/relative")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target != server.URL+"/short" {
+			t.Errorf("Resolve() = %q, want the first hop", target)
+		}
+	})
+
+	t.Run("not a redirect", func(t *testing.T) {
+		if _, err := resolver.Resolve(ctx, server.URL+"/page"); !errors.Is(err, ErrNoRedirect) {
+			t.Errorf("Resolve() error = %v, want ErrNoRedirect", err)
+		}
+	})
+
+	t.Run("unsupported scheme", func(t *testing.T) {
+		if _, err := resolver.Resolve(ctx, server.URL+"/script"); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+
+	t.Run("cached only", func(t *testing.T) {
+		before := requests
+		cached := resolver.Cached()
+		if target, err := cached.Resolve(ctx, server.URL+"/short"); err != nil || target != "https://example.com/article?utm_source=x" {
+			t.Errorf("Resolve() = %q, %v, want the stored redirect", target, err)
+		}
+		if _, err := cached.Resolve(ctx, server.URL+"/unknown"); !errors.Is(err, url.ErrNotResolved) {
+			t.Errorf("Resolve() error = %v, want ErrNotResolved", err)
+		}
+		if requests != before {
+			t.Error("Expected no requests from the cached resolver")
+		}
+	})
+
+	t.Run("store fails", func(t *testing.T) {
+		failing := newRedirectResolver(&mockRedirectStore{redirects: map[string]string{}, saveErr: errors.New("db down")}, 5*time.Second, func(addr netip.Addr) bool { return addr.IsLoopback() })
+		if _, err := failing.Resolve(ctx, server.URL+"/short"); err != nil {
+			t.Errorf("Expected a failed save not to fail the resolve, got %v", err)
+		}
+	})
+}
+
+func TestRedirectResolver_BlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("The resolver reached a loopback server")
+	}))
+	defer server.Close()
+
+	resolver := NewRedirectResolver(&mockRedirectStore{redirects: map[string]string{}}, 5*time.Second)
+	if _, err := resolver.Resolve(context.Background(), server.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Resolve() error = %v, want ErrBlockedAddress", err)
+	}
+}
+
+func TestRedirectResolver_RunPrunesOldRedirects(t *testing.T) {
+	store := &mockRedirectStore{redirects: map[string]string{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	NewRedirectResolver(store, time.Second).Run(ctx, 24*time.Hour)
+
+	if len(store.pruned) != 1 || store.pruned[0] != 24*time.Hour {
+		t.Errorf("Expected one prune with the TTL, got %v", store.pruned)
+	}
+}
@@ -0,0 +1,63 @@
+package article
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Length caps for metadata text, in characters. Longer values are cut, not rejected, since they come from pages
+// nobody here controls.
+const (
+	MaxTitleLength = 500 // Titles and headlines
+	MaxNameLength  = 255 // Site and author names
+)
+
+// languageTagPattern loosely matches BCP 47 language tags like "en", "pt-BR", or "zh-Hant-TW".
+var languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+// publishedTimeLayouts are the formats we accept for published times. Pages use all of these in
+// article:published_time and JSON-LD datePublished.
+var publishedTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// CleanText collapses whitespace and cuts the text to maxLength characters. It returns nil for empty text.
+func CleanText(text string, maxLength int) *string {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return nil
+	}
+	if utf8.RuneCountInString(text) > maxLength {
+		text = strings.TrimSpace(string([]rune(text)[:maxLength]))
+	}
+	return &text
+}
+
+// CleanLanguage returns the language tag if it looks like BCP 47, or nil. Underscores, as in og:locale's "en_US",
+// become hyphens.
+func CleanLanguage(tag string) *string {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	if !languageTagPattern.MatchString(tag) {
+		return nil
+	}
+	return &tag
+}
+
+// ParsePublishedTime parses a published time in any of the formats pages use, and returns it in UTC.
+// Times without a zone are taken as UTC. It returns nil for unparseable times and for times more than a day after now,
+// which are bogus.
+func ParsePublishedTime(value string, now time.Time) *time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range publishedTimeLayouts {
+		parsed, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		if parsed.After(now.Add(24 * time.Hour)) {
+			return nil
+		}
+		parsed = parsed.UTC()
+		return &parsed
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package article
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+// batchSize is how many pages the worker claims at once.
+const batchSize = 10
+
+// maxReasonLength caps the failure reason we store, since it can contain an arbitrary error message.
+const maxReasonLength = 500
+
+// Store is where the worker finds pages to fetch and records what it found.
+type Store interface {
+	ClaimPagesToFetch(ctx context.Context, limit int, lease time.Duration) ([]models.PageToFetch, error)
+	SaveFetchedPage(ctx context.Context, pageID int64, page *models.FetchedPage) error
+	MarkFetchFailed(ctx context.Context, pageID int64, reason string, retryIn time.Duration) error
+	MarkFetchAbandoned(ctx context.Context, pageID int64, reason string) error
+}
+
+// PageFetcher downloads a page and extracts its metadata. *Fetcher is one.
+type PageFetcher interface {
+	Fetch(ctx context.Context, pageURL string) (*models.FetchedPage, error)
+}
+
+// WorkerOptions configures the worker.
+type WorkerOptions struct {
+	Timeout     time.Duration // Per fetch, to size the claim lease
+	MaxAttempts int           // After this many failed attempts, we give up on a page
+	RetryDelay  time.Duration // Wait after the first failed attempt, doubled after each further one
+}
+
+// Worker fetches new pages in the background and stores what they say about themselves.
+// Several workers, even on different servers, can run at once.
+type Worker struct {
+	store   Store
+	fetcher PageFetcher
+	options WorkerOptions
+}
+
+// NewWorker creates a worker.
+func NewWorker(store Store, fetcher PageFetcher, options WorkerOptions) *Worker {
+	return &Worker{store: store, fetcher: fetcher, options: options}
+}
+
+// Run fetches due pages right away and then at every interval, until ctx is done.
+// Errors are logged, and the pages involved are retried later.
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while there's a backlog, rather than fetching one batch per interval
+		for {
+			claimed, err := w.FetchDue(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Error: %v", err)
+			}
+			if err != nil || claimed < batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FetchDue fetches one batch of due pages and records how each went. It returns how many it claimed.
+func (w *Worker) FetchDue(ctx context.Context) (int, error) {
+	// Fetching the batch one by one can take up to batchSize timeouts, so the lease must outlast that
+	lease := w.options.Timeout*batchSize + time.Minute
+	pages, err := w.store.ClaimPagesToFetch(ctx, batchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, page := range pages {
+		fetched, err := w.fetcher.Fetch(ctx, page.NormalizedURL)
+		if err != nil {
+			if ctx.Err() != nil {
+				return len(pages), ctx.Err() // The lease runs out and someone retries it, no need to count a failure
+			}
+			reason := err.Error()
+			if len(reason) > maxReasonLength {
+				reason = reason[:maxReasonLength]
+			}
+			if page.FetchAttempts >= w.options.MaxAttempts {
+				err = w.store.MarkFetchAbandoned(ctx, page.ID, reason)
+			} else {
+				err = w.store.MarkFetchFailed(ctx, page.ID, reason, w.retryDelay(page.FetchAttempts))
+			}
+		} else {
+			err = w.store.SaveFetchedPage(ctx, page.ID, fetched)
+		}
+		if err != nil {
+			return len(pages), err
+		}
+	}
+
+	return len(pages), nil
+}
+
+// retryDelay returns how long to wait after the given number of failed attempts. It doubles up to a day.
+func (w *Worker) retryDelay(attempts int) time.Duration {
+	delay := w.options.RetryDelay
+	for i := 1; i < attempts && delay < 24*time.Hour; i++ {
+		delay *= 2
+	}
+	return min(delay, 24*time.Hour)
+}
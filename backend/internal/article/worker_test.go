@@ -0,0 +1,121 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+// mockStore is an in-memory fetch queue that hands out its pages once and records what happened to them.
+type mockStore struct {
+	pending   []models.PageToFetch
+	claimErr  error
+	fetched   map[int64]*models.FetchedPage
+	failed    map[int64]time.Duration
+	abandoned map[int64]string
+}
+
+func newMockStore(pending ...models.PageToFetch) *mockStore {
+	return &mockStore{
+		pending:   pending,
+		fetched:   map[int64]*models.FetchedPage{},
+		failed:    map[int64]time.Duration{},
+		abandoned: map[int64]string{},
+	}
+}
+
+func (m *mockStore) ClaimPagesToFetch(ctx context.Context, limit int, lease time.Duration) ([]models.PageToFetch, error) {
+	if m.claimErr != nil {
+		return nil, m.claimErr
+	}
+	claimed := m.pending[:min(limit, len(m.pending))]
+	m.pending = m.pending[len(claimed):]
+	for i := range claimed {
+		claimed[i].FetchAttempts++
+	}
+	return claimed, nil
+}
+
+func (m *mockStore) SaveFetchedPage(ctx context.Context, pageID int64, page *models.FetchedPage) error {
+	m.fetched[pageID] = page
+	return nil
+}
+
+func (m *mockStore) MarkFetchFailed(ctx context.Context, pageID int64, reason string, retryIn time.Duration) error {
+	m.failed[pageID] = retryIn
+	return nil
+}
+
+func (m *mockStore) MarkFetchAbandoned(ctx context.Context, pageID int64, reason string) error {
+	m.abandoned[pageID] = reason
+	return nil
+}
+
+func testWorkerOptions() WorkerOptions {
+	return WorkerOptions{Timeout: time.Second, MaxAttempts: 3, RetryDelay: time.Minute}
+}
+
+func TestWorker_FetchDue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/article" {
+			http.Error(w, "Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(newsArticlePage))
+	}))
+	defer server.Close()
+
+	// Attempts as stored before the claim, which counts one more
+	store := newMockStore(
+		models.PageToFetch{ID: 1, NormalizedURL: server.URL + "/article"},
+		models.PageToFetch{ID: 2, NormalizedURL: server.URL + "/down"},
+		models.PageToFetch{ID: 3, NormalizedURL: server.URL + "/down", FetchAttempts: 1},
+		models.PageToFetch{ID: 4, NormalizedURL: server.URL + "/down", FetchAttempts: 2},
+	)
+	worker := NewWorker(store, newTestFetcher(64<<10), testWorkerOptions())
+
+	claimed, err := worker.FetchDue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed != 4 {
+		t.Errorf("Expected 4 pages claimed, got %d", claimed)
+	}
+	if page := store.fetched[1]; page == nil || page.OGType == nil || *page.OGType != "article" {
+		t.Errorf("Expected page 1 to be saved as an article, got %+v", store.fetched)
+	}
+	expectedRetries := map[int64]time.Duration{2: time.Minute, 3: 2 * time.Minute}
+	for id, expected := range expectedRetries {
+		if got := store.failed[id]; got != expected {
+			t.Errorf("Page %d: expected a retry in %s, got %s", id, expected, got)
+		}
+	}
+	if reason, ok := store.abandoned[4]; !ok || reason != "HTTP 503" {
+		t.Errorf("Expected page 4 to be abandoned with HTTP 503, got %q (abandoned: %v)", reason, ok)
+	}
+}
+
+func TestWorker_ClaimError(t *testing.T) {
+	store := newMockStore()
+	store.claimErr = errors.New("database is down")
+	if _, err := NewWorker(store, newTestFetcher(1024), testWorkerOptions()).FetchDue(context.Background()); err == nil {
+		t.Error("Expected the claim error")
+	}
+}
+
+func TestWorker_RetryDelay(t *testing.T) {
+	worker := NewWorker(newMockStore(), nil, WorkerOptions{RetryDelay: 10 * time.Hour})
+	expected := []time.Duration{10 * time.Hour, 20 * time.Hour, 24 * time.Hour, 24 * time.Hour}
+	for i, want := range expected {
+		attempts := i + 1
+		if got := worker.retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewLRU[string, int](2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Expected a miss on an empty cache")
+	}
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %d, %v, want 1, true", v, ok)
+	}
+
+	// "b" is now the least recently used, so it makes room for "c"
+	c.Set("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if v, ok := c.Get("c"); !ok || v != 3 {
+		t.Errorf("Get(c) = %d, %v, want 3, true", v, ok)
+	}
+
+	// Overwriting keeps the size and updates the value
+	c.Set("a", 10)
+	if v, _ := c.Get("a"); v != 10 {
+		t.Errorf("Get(a) = %d, want 10", v)
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected a to be deleted")
+	}
+
+	// Entries expire after the TTL
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("c"); ok {
+		t.Error("Expected c to be expired")
+	}
+
+	stats := c.Stats()
+	want := Stats{Hits: 3, Misses: 4, Evictions: 1, Size: 0}
+	if stats != want {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+}
+
+func TestLRU_Nil(t *testing.T) {
+	var c *LRU[string, int]
+
+	c.Set("a", 1)
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected a nil cache to always miss")
+	}
+	if stats := c.Stats(); stats != (Stats{}) {
+		t.Errorf("Expected zero stats, got %+v", stats)
+	}
+}
@@ -0,0 +1,526 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds every setting the backend needs, loaded once at startup.
+// Each field can come from (in increasing priority) the defaults below, an optional
+// YAML or TOML config file, a .env file, and the process environment.
+type Config struct {
+	Port     int            `env:"PORT" yaml:"port" toml:"port"`
+	Database DatabaseConfig `yaml:"database" toml:"database"`
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	CORS     CORSConfig     `yaml:"cors" toml:"cors"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	Ratings  RatingsConfig  `yaml:"ratings" toml:"ratings"`
+	Cache    CacheConfig    `yaml:"cache" toml:"cache"`
+	Stream   StreamConfig   `yaml:"stream" toml:"stream"`
+	Webhooks WebhooksConfig `yaml:"webhooks" toml:"webhooks"`
+	Pages    PagesConfig    `yaml:"pages" toml:"pages"`
+	Fetch    FetchConfig    `yaml:"fetch" toml:"fetch"`
+	Articles ArticlesConfig `yaml:"articles" toml:"articles"`
+}
+
+// DatabaseConfig describes how to reach Postgres.
+// Either URL or all the individual connection fields (Host, Port, User, Password, Name) must be set.
+type DatabaseConfig struct {
+	URL            string        `env:"DATABASE_URL" yaml:"url" toml:"url" secret:"true"`
+	Host           string        `env:"DB_HOST" yaml:"host" toml:"host"`
+	Port           int           `env:"DB_PORT" yaml:"port" toml:"port"`
+	User           string        `env:"DB_USER" yaml:"user" toml:"user"`
+	Password       string        `env:"DB_PASSWORD" yaml:"password" toml:"password" secret:"true"`
+	Name           string        `env:"DB_NAME" yaml:"name" toml:"name"`
+	SSLMode        string        `env:"DB_SSLMODE" yaml:"sslmode" toml:"sslmode"` // Empty means "use what's in URL, or 'disable' for individual fields"
+	MaxConns       int           `env:"DB_MAX_CONNS" yaml:"max_conns" toml:"max_conns"`
+	MinConns       int           `env:"DB_MIN_CONNS" yaml:"min_conns" toml:"min_conns"`
+	ConnectTimeout time.Duration `env:"DB_CONNECT_TIMEOUT" yaml:"connect_timeout" toml:"connect_timeout"`
+	AutoMigrate    bool          `env:"DB_AUTO_MIGRATE" yaml:"auto_migrate" toml:"auto_migrate"` // Apply pending migrations on server start
+}
+
+// ServerConfig holds the HTTP server timeouts.
+type ServerConfig struct {
+	ReadHeaderTimeout time.Duration `env:"SERVER_READ_HEADER_TIMEOUT" yaml:"read_header_timeout" toml:"read_header_timeout"`
+	ReadTimeout       time.Duration `env:"SERVER_READ_TIMEOUT" yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout      time.Duration `env:"SERVER_WRITE_TIMEOUT" yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout       time.Duration `env:"SERVER_IDLE_TIMEOUT" yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `env:"SERVER_SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout" toml:"shutdown_timeout"` // How long to wait for in-flight requests on stop
+}
+
+// CORSConfig controls which origins may call the API from a browser.
+type CORSConfig struct {
+	AllowedOrigins []string `env:"CORS_ALLOWED_ORIGINS" yaml:"allowed_origins" toml:"allowed_origins"` // "*" allows any origin
+}
+
+// AuthConfig selects and configures how requests are authenticated.
+type AuthConfig struct {
+	Mode        string        `env:"AUTH_MODE" yaml:"mode" toml:"mode"`                                 // AuthModeJWT, AuthModeProxy, or AuthModeHeader
+	JWTSecret   string        `env:"AUTH_JWT_SECRET" yaml:"jwt_secret" toml:"jwt_secret" secret:"true"` // Enables HS256 tokens
+	JWKSFile    string        `env:"AUTH_JWT_JWKS_FILE" yaml:"jwks_file" toml:"jwks_file"`              // Enables RS256 tokens with keys from this file
+	JWKSURL     string        `env:"AUTH_JWT_JWKS_URL" yaml:"jwks_url" toml:"jwks_url"`                 // Enables RS256 tokens with keys fetched from this URL
+	JWTIssuer   string        `env:"AUTH_JWT_ISSUER" yaml:"jwt_issuer" toml:"jwt_issuer"`               // Empty accepts any issuer
+	JWTAudience string        `env:"AUTH_JWT_AUDIENCE" yaml:"jwt_audience" toml:"jwt_audience"`
+	JWTLeeway   time.Duration `env:"AUTH_JWT_LEEWAY" yaml:"jwt_leeway" toml:"jwt_leeway"` // Allowed clock skew
+
+	ProxyTrustedCIDRs  []string `env:"AUTH_PROXY_TRUSTED_CIDRS" yaml:"proxy_trusted_cidrs" toml:"proxy_trusted_cidrs"`    // Source addresses allowed to send Remote-* headers
+	ProxyAllowedGroups []string `env:"AUTH_PROXY_ALLOWED_GROUPS" yaml:"proxy_allowed_groups" toml:"proxy_allowed_groups"` // Empty allows any group
+}
+
+const (
+	// AuthModeJWT accepts signed bearer tokens.
+	AuthModeJWT = "jwt"
+	// AuthModeProxy trusts Remote-User and friends from a forward-auth proxy like Authelia, but only from trusted addresses.
+	AuthModeProxy = "proxy"
+	// AuthModeHeader trusts the X-User-ID header. Development only: anyone can act as anyone.
+	AuthModeHeader = "header"
+)
+
+// RatingsConfig holds the rules for rating submissions and for ranking pages by their ratings.
+type RatingsConfig struct {
+	MinScore int `env:"RATINGS_MIN_SCORE" yaml:"min_score" toml:"min_score"`
+	MaxScore int `env:"RATINGS_MAX_SCORE" yaml:"max_score" toml:"max_score"`
+
+	// The adjusted score is a Bayesian average: each page starts with PriorWeight imaginary ratings at the prior score.
+	PriorWeight          int           `env:"RATINGS_PRIOR_WEIGHT" yaml:"prior_weight" toml:"prior_weight"`                               // 0 makes the adjusted score the plain average
+	PriorPerDomain       bool          `env:"RATINGS_PRIOR_PER_DOMAIN" yaml:"prior_per_domain" toml:"prior_per_domain"`                   // Use each domain's average as the prior instead of the global one
+	PriorRefreshInterval time.Duration `env:"RATINGS_PRIOR_REFRESH_INTERVAL" yaml:"prior_refresh_interval" toml:"prior_refresh_interval"` // How often to recompute the priors
+}
+
+// CacheConfig controls the in-process cache of page checks, which holds everything but each user's own rating.
+// With more than one server instance, a rating on one instance reaches the others only after TTL. New metadata and
+// article signals reach every instance only after TTL.
+type CacheConfig struct {
+	Enabled bool          `env:"PAGE_STATS_CACHE_ENABLED" yaml:"enabled" toml:"enabled"`
+	Size    int           `env:"PAGE_STATS_CACHE_SIZE" yaml:"size" toml:"size"` // Max number of pages kept
+	TTL     time.Duration `env:"PAGE_STATS_CACHE_TTL" yaml:"ttl" toml:"ttl"`
+}
+
+// StreamConfig controls how live page stats updates reach the clients streaming them.
+type StreamConfig struct {
+	Backend string `env:"STREAM_BACKEND" yaml:"backend" toml:"backend"` // StreamBackendMemory or StreamBackendPostgres
+}
+
+const (
+	// StreamBackendMemory fans updates out within this server only. Enough for a single replica.
+	StreamBackendMemory = "memory"
+	// StreamBackendPostgres sends updates through Postgres LISTEN/NOTIFY, so every replica gets them.
+	StreamBackendPostgres = "postgres"
+)
+
+// WebhooksConfig controls the worker that delivers webhook events.
+// Failed deliveries are retried with exponential backoff, then dead-lettered after MaxAttempts.
+type WebhooksConfig struct {
+	Enabled       bool          `env:"WEBHOOKS_ENABLED" yaml:"enabled" toml:"enabled"` // Events are still recorded when off, and go out once it's back on
+	PollInterval  time.Duration `env:"WEBHOOKS_POLL_INTERVAL" yaml:"poll_interval" toml:"poll_interval"`
+	Timeout       time.Duration `env:"WEBHOOKS_TIMEOUT" yaml:"timeout" toml:"timeout"` // Per delivery attempt
+	MaxAttempts   int           `env:"WEBHOOKS_MAX_ATTEMPTS" yaml:"max_attempts" toml:"max_attempts"`
+	RetryDelay    time.Duration `env:"WEBHOOKS_RETRY_DELAY" yaml:"retry_delay" toml:"retry_delay"`             // Wait after the first failure, doubled after each further one
+	MaxRetryDelay time.Duration `env:"WEBHOOKS_MAX_RETRY_DELAY" yaml:"max_retry_delay" toml:"max_retry_delay"` // Cap for the doubling
+	Retention     time.Duration `env:"WEBHOOKS_RETENTION" yaml:"retention" toml:"retention"`                   // How long delivered events are kept
+}
+
+// PagesConfig holds the rules for what we store about pages.
+type PagesConfig struct {
+	// Metadata is first-write-wins, so a later visit can't overwrite a known title. Once it's older than this, it can.
+	MetadataRefreshAfter time.Duration `env:"PAGE_METADATA_REFRESH_AFTER" yaml:"metadata_refresh_after" toml:"metadata_refresh_after"`
+	// A custom URL normalization rules file. Empty means the built-in rules. Changing rules changes URL hashes.
+	URLRulesFile string `env:"URL_RULES_FILE" yaml:"url_rules_file" toml:"url_rules_file"`
+	// Follow the redirects of link wrappers whose target isn't in the link, like t.co, to rate what they lead to.
+	// Only writes request a link, once, then cache it. Reads only use the cache.
+	ResolveRedirects bool          `env:"URL_RESOLVE_REDIRECTS" yaml:"resolve_redirects" toml:"resolve_redirects"`
+	ResolveTimeout   time.Duration `env:"URL_RESOLVE_TIMEOUT" yaml:"resolve_timeout" toml:"resolve_timeout"` // Per redirect
+	ResolveTTL       time.Duration `env:"URL_RESOLVE_TTL" yaml:"resolve_ttl" toml:"resolve_ttl"`             // How long a cached redirect is kept
+}
+
+// FetchConfig controls the worker that fetches new pages to read their metadata, og:type, JSON-LD, and canonical URL.
+// It only connects to public addresses. A failed fetch is retried with exponential backoff, up to MaxAttempts times.
+type FetchConfig struct {
+	Enabled      bool          `env:"PAGE_FETCH_ENABLED" yaml:"enabled" toml:"enabled"` // New pages are still queued when off, and get fetched once it's back on
+	PollInterval time.Duration `env:"PAGE_FETCH_POLL_INTERVAL" yaml:"poll_interval" toml:"poll_interval"`
+	Timeout      time.Duration `env:"PAGE_FETCH_TIMEOUT" yaml:"timeout" toml:"timeout"`       // Per fetch, redirects and body included
+	MaxBytes     int           `env:"PAGE_FETCH_MAX_BYTES" yaml:"max_bytes" toml:"max_bytes"` // Bytes of each page we read
+	MaxAttempts  int           `env:"PAGE_FETCH_MAX_ATTEMPTS" yaml:"max_attempts" toml:"max_attempts"`
+	RetryDelay   time.Duration `env:"PAGE_FETCH_RETRY_DELAY" yaml:"retry_delay" toml:"retry_delay"` // Wait after the first failure, doubled after each further one
+}
+
+// ArticlesConfig holds the per-domain rules for what counts as an article, on top of og:type, JSON-LD, and URL depth.
+// A domain rule covers its subdomains, and the most specific rule wins.
+type ArticlesConfig struct {
+	AllowDomains []string `env:"ARTICLES_ALLOW_DOMAINS" yaml:"allow_domains" toml:"allow_domains"` // Everything here is an article
+	DenyDomains  []string `env:"ARTICLES_DENY_DOMAINS" yaml:"deny_domains" toml:"deny_domains"`    // Nothing here is an article
+	// Pages we haven't fetched yet, and whose URL doesn't tell, can be rated unless this is on
+	RequireVerified bool `env:"ARTICLES_REQUIRE_VERIFIED" yaml:"require_verified" toml:"require_verified"`
+}
+
+// validSSLModes lists the sslmode values Postgres understands.
+var validSSLModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// Default returns a config with every optional setting filled in.
+// Database connection details have no defaults on purpose.
+func Default() Config {
+	return Config{
+		Port: 52181,
+		Database: DatabaseConfig{
+			MaxConns:       10,
+			MinConns:       0,
+			ConnectTimeout: 5 * time.Second,
+			AutoMigrate:    true,
+		},
+		Server: ServerConfig{
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   15 * time.Second,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"*"},
+		},
+		Auth: AuthConfig{
+			Mode:      AuthModeJWT,
+			JWTLeeway: 30 * time.Second,
+		},
+		Ratings: RatingsConfig{
+			MinScore:             1,
+			MaxScore:             10,
+			PriorWeight:          10,
+			PriorRefreshInterval: 15 * time.Minute,
+		},
+		Cache: CacheConfig{
+			Enabled: true,
+			Size:    10000,
+			TTL:     30 * time.Second,
+		},
+		Stream: StreamConfig{
+			Backend: StreamBackendMemory,
+		},
+		Webhooks: WebhooksConfig{
+			Enabled:       true,
+			PollInterval:  5 * time.Second,
+			Timeout:       10 * time.Second,
+			MaxAttempts:   10,
+			RetryDelay:    30 * time.Second,
+			MaxRetryDelay: time.Hour,
+			Retention:     7 * 24 * time.Hour,
+		},
+		Pages: PagesConfig{
+			MetadataRefreshAfter: 30 * 24 * time.Hour,
+			ResolveTimeout:       3 * time.Second,
+			ResolveTTL:           30 * 24 * time.Hour,
+		},
+		Fetch: FetchConfig{
+			Enabled:      true,
+			PollInterval: 10 * time.Second,
+			Timeout:      10 * time.Second,
+			MaxBytes:     2 << 20,
+			MaxAttempts:  3,
+			RetryDelay:   10 * time.Minute,
+		},
+	}
+}
+
+// Validate checks the whole config and returns every problem at once, joined into one error.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT must be between 1 and 65535, got %d", c.Port))
+	}
+
+	errs = append(errs, c.Database.validate()...)
+	errs = append(errs, c.Server.validate()...)
+	errs = append(errs, c.CORS.validate()...)
+	errs = append(errs, c.Auth.validate()...)
+	errs = append(errs, c.Ratings.validate()...)
+	errs = append(errs, c.Cache.validate()...)
+	errs = append(errs, c.Stream.validate()...)
+	errs = append(errs, c.Webhooks.validate()...)
+	errs = append(errs, c.Pages.validate()...)
+	errs = append(errs, c.Fetch.validate()...)
+	errs = append(errs, c.Articles.validate()...)
+
+	return errors.Join(errs...)
+}
+
+func (d *DatabaseConfig) validate() []error {
+	var errs []error
+
+	if d.URL != "" {
+		if parsed, err := url.Parse(d.URL); err != nil || (parsed.Scheme != "postgres" && parsed.Scheme != "postgresql") {
+			errs = append(errs, errors.New("DATABASE_URL must be a postgres:// or postgresql:// URL"))
+		}
+	} else {
+		missing := map[string]bool{
+			"DB_HOST":     d.Host == "",
+			"DB_PORT":     d.Port == 0,
+			"DB_USER":     d.User == "",
+			"DB_PASSWORD": d.Password == "",
+			"DB_NAME":     d.Name == "",
+		}
+		for _, key := range []string{"DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_NAME"} {
+			if missing[key] {
+				errs = append(errs, fmt.Errorf("%s is missing (set DATABASE_URL or all DB_* connection settings)", key))
+			}
+		}
+		if d.Port < 0 || d.Port > 65535 {
+			errs = append(errs, fmt.Errorf("DB_PORT must be between 1 and 65535, got %d", d.Port))
+		}
+	}
+
+	if d.SSLMode != "" && !validSSLModes[d.SSLMode] {
+		errs = append(errs, fmt.Errorf("DB_SSLMODE %q is not one of disable, allow, prefer, require, verify-ca, verify-full", d.SSLMode))
+	}
+	if d.MaxConns < 1 {
+		errs = append(errs, fmt.Errorf("DB_MAX_CONNS must be at least 1, got %d", d.MaxConns))
+	}
+	if d.MinConns < 0 || d.MinConns > d.MaxConns {
+		errs = append(errs, fmt.Errorf("DB_MIN_CONNS must be between 0 and DB_MAX_CONNS (%d), got %d", d.MaxConns, d.MinConns))
+	}
+	if d.ConnectTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("DB_CONNECT_TIMEOUT must be positive, got %s", d.ConnectTimeout))
+	}
+
+	return errs
+}
+
+func (s *ServerConfig) validate() []error {
+	var errs []error
+	timeouts := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"SERVER_READ_HEADER_TIMEOUT", s.ReadHeaderTimeout},
+		{"SERVER_READ_TIMEOUT", s.ReadTimeout},
+		{"SERVER_WRITE_TIMEOUT", s.WriteTimeout},
+		{"SERVER_IDLE_TIMEOUT", s.IdleTimeout},
+		{"SERVER_SHUTDOWN_TIMEOUT", s.ShutdownTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", timeout.key, timeout.value))
+		}
+	}
+	return errs
+}
+
+func (c *CORSConfig) validate() []error {
+	var errs []error
+	if len(c.AllowedOrigins) == 0 {
+		errs = append(errs, errors.New("CORS_ALLOWED_ORIGINS must list at least one origin (use * to allow any)"))
+	}
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		parsed, err := url.Parse(origin)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" || (parsed.Path != "" && parsed.Path != "/") {
+			errs = append(errs, fmt.Errorf("CORS_ALLOWED_ORIGINS entry %q must look like scheme://host, for example chrome-extension://abcdef", origin))
+		}
+	}
+	return errs
+}
+
+func (a *AuthConfig) validate() []error {
+	var errs []error
+	switch a.Mode {
+	case AuthModeHeader:
+	case AuthModeProxy:
+		if len(a.ProxyTrustedCIDRs) == 0 {
+			errs = append(errs, errors.New("AUTH_MODE=proxy needs AUTH_PROXY_TRUSTED_CIDRS"))
+		}
+		for _, value := range a.ProxyTrustedCIDRs {
+			if _, err := netip.ParsePrefix(value); err != nil {
+				if _, err := netip.ParseAddr(value); err != nil {
+					errs = append(errs, fmt.Errorf("AUTH_PROXY_TRUSTED_CIDRS entry %q is not a CIDR range or IP address", value))
+				}
+			}
+		}
+	case AuthModeJWT:
+		if a.JWTSecret == "" && a.JWKSFile == "" && a.JWKSURL == "" {
+			errs = append(errs, errors.New("AUTH_MODE=jwt needs AUTH_JWT_SECRET, AUTH_JWT_JWKS_FILE, or AUTH_JWT_JWKS_URL"))
+		}
+		if a.JWKSFile != "" && a.JWKSURL != "" {
+			errs = append(errs, errors.New("set only one of AUTH_JWT_JWKS_FILE and AUTH_JWT_JWKS_URL"))
+		}
+		if a.JWTSecret != "" && len(a.JWTSecret) < 32 {
+			errs = append(errs, errors.New("AUTH_JWT_SECRET must be at least 32 characters"))
+		}
+		if a.JWTAudience == "" {
+			errs = append(errs, errors.New("AUTH_JWT_AUDIENCE is missing"))
+		}
+		if a.JWTLeeway < 0 {
+			errs = append(errs, fmt.Errorf("AUTH_JWT_LEEWAY must not be negative, got %s", a.JWTLeeway))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("AUTH_MODE %q is not one of %s, %s, %s", a.Mode, AuthModeJWT, AuthModeProxy, AuthModeHeader))
+	}
+	return errs
+}
+
+func (r *RatingsConfig) validate() []error {
+	var errs []error
+	// The DB has a CHECK constraint for 1..10, so the configured range must fit inside it.
+	if r.MinScore < 1 || r.MaxScore > 10 || r.MinScore >= r.MaxScore {
+		errs = append(errs, fmt.Errorf("RATINGS_MIN_SCORE and RATINGS_MAX_SCORE must satisfy 1 <= min < max <= 10, got %d and %d", r.MinScore, r.MaxScore))
+	}
+	if r.PriorWeight < 0 {
+		errs = append(errs, fmt.Errorf("RATINGS_PRIOR_WEIGHT must not be negative, got %d", r.PriorWeight))
+	}
+	if r.PriorRefreshInterval < time.Second {
+		errs = append(errs, fmt.Errorf("RATINGS_PRIOR_REFRESH_INTERVAL must be at least 1s, got %s", r.PriorRefreshInterval))
+	}
+	return errs
+}
+
+func (c *CacheConfig) validate() []error {
+	if !c.Enabled {
+		return nil
+	}
+	var errs []error
+	if c.Size < 1 {
+		errs = append(errs, fmt.Errorf("PAGE_STATS_CACHE_SIZE must be at least 1, got %d", c.Size))
+	}
+	if c.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("PAGE_STATS_CACHE_TTL must be positive, got %s", c.TTL))
+	}
+	return errs
+}
+
+func (s *StreamConfig) validate() []error {
+	if s.Backend != StreamBackendMemory && s.Backend != StreamBackendPostgres {
+		return []error{fmt.Errorf("STREAM_BACKEND %q is not one of %s, %s", s.Backend, StreamBackendMemory, StreamBackendPostgres)}
+	}
+	return nil
+}
+
+func (w *WebhooksConfig) validate() []error {
+	if !w.Enabled {
+		return nil
+	}
+	var errs []error
+	durations := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"WEBHOOKS_POLL_INTERVAL", w.PollInterval},
+		{"WEBHOOKS_TIMEOUT", w.Timeout},
+		{"WEBHOOKS_RETRY_DELAY", w.RetryDelay},
+		{"WEBHOOKS_RETENTION", w.Retention},
+	}
+	for _, duration := range durations {
+		if duration.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", duration.key, duration.value))
+		}
+	}
+	if w.MaxRetryDelay < w.RetryDelay {
+		errs = append(errs, fmt.Errorf("WEBHOOKS_MAX_RETRY_DELAY must be at least WEBHOOKS_RETRY_DELAY (%s), got %s", w.RetryDelay, w.MaxRetryDelay))
+	}
+	if w.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("WEBHOOKS_MAX_ATTEMPTS must be at least 1, got %d", w.MaxAttempts))
+	}
+	return errs
+}
+
+func (p *PagesConfig) validate() []error {
+	var errs []error
+	if p.MetadataRefreshAfter <= 0 {
+		errs = append(errs, fmt.Errorf("PAGE_METADATA_REFRESH_AFTER must be positive, got %s", p.MetadataRefreshAfter))
+	}
+	if p.ResolveRedirects && p.ResolveTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("URL_RESOLVE_TIMEOUT must be positive, got %s", p.ResolveTimeout))
+	}
+	if p.ResolveRedirects && p.ResolveTTL <= 0 {
+		errs = append(errs, fmt.Errorf("URL_RESOLVE_TTL must be positive, got %s", p.ResolveTTL))
+	}
+	return errs
+}
+
+func (f *FetchConfig) validate() []error {
+	if !f.Enabled {
+		return nil
+	}
+	var errs []error
+	durations := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"PAGE_FETCH_POLL_INTERVAL", f.PollInterval},
+		{"PAGE_FETCH_TIMEOUT", f.Timeout},
+		{"PAGE_FETCH_RETRY_DELAY", f.RetryDelay},
+	}
+	for _, duration := range durations {
+		if duration.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", duration.key, duration.value))
+		}
+	}
+	if f.MaxBytes < 1024 {
+		errs = append(errs, fmt.Errorf("PAGE_FETCH_MAX_BYTES must be at least 1024, got %d", f.MaxBytes))
+	}
+	if f.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("PAGE_FETCH_MAX_ATTEMPTS must be at least 1, got %d", f.MaxAttempts))
+	}
+	return errs
+}
+
+func (a *ArticlesConfig) validate() []error {
+	var errs []error
+	rules := []struct {
+		key     string
+		domains []string
+	}{
+		{"ARTICLES_ALLOW_DOMAINS", a.AllowDomains},
+		{"ARTICLES_DENY_DOMAINS", a.DenyDomains},
+	}
+	for _, rule := range rules {
+		for _, domain := range rule.domains {
+			if domain == "" || strings.ContainsAny(domain, "/:*? ") {
+				errs = append(errs, fmt.Errorf("%s must list bare domains like example.com, got %q", rule.key, domain))
+			}
+		}
+	}
+	return errs
+}
+
+// ConnString returns the Postgres connection string, built from URL or the individual fields.
+// SSLMode, if set, overrides any sslmode already present in URL.
+func (d *DatabaseConfig) ConnString() string {
+	if d.URL != "" {
+		if d.SSLMode == "" {
+			return d.URL
+		}
+		parsed, err := url.Parse(d.URL)
+		if err != nil {
+			return d.URL
+		}
+		query := parsed.Query()
+		query.Set("sslmode", d.SSLMode)
+		parsed.RawQuery = query.Encode()
+		return parsed.String()
+	}
+
+	sslMode := d.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	connURL := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.User, d.Password),
+		Host:     d.Host + ":" + strconv.Itoa(d.Port),
+		Path:     "/" + d.Name,
+		RawQuery: url.Values{"sslmode": {sslMode}}.Encode(),
+	}
+	return connURL.String()
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileEnvKey names the env var that points to an optional config file.
+const configFileEnvKey = "CONFIG_FILE"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load builds the config from defaults, an optional config file, .env, and the environment, then validates it.
+// If configFile is empty, it falls back to the CONFIG_FILE env var. If that's empty too, no file is read.
+// All parse and validation problems are reported together in the returned error.
+func Load(configFile string) (*Config, error) {
+	if err := loadDotEnv(); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	if configFile == "" {
+		configFile = os.Getenv(configFileEnvKey)
+	}
+	var errs []error
+	if configFile != "" {
+		if err := loadFile(configFile, &cfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	errs = append(errs, applyEnv(reflect.ValueOf(&cfg).Elem())...)
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+
+	return &cfg, nil
+}
+
+// loadDotEnv loads the first .env file it finds in the working dir or its parent.
+// The parent is there so that both the repo root (Air) and backend/ (go run) work.
+// A missing .env is fine: in Docker, everything comes from the environment.
+// Values already in the environment win over the ones in .env.
+func loadDotEnv() error {
+	for _, path := range []string{".env", "../.env"} {
+		err := godotenv.Load(path)
+		if err == nil {
+			log.Printf("Loaded environment from %s", path)
+			return nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to load %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// loadFile reads a YAML or TOML config file into cfg, picking the format by file extension.
+// Keys missing from the file keep their current values. Unknown keys are an error, so typos don't go unnoticed.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		// An empty file is fine, it just changes nothing
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to parse YAML config file %s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("failed to parse TOML config file %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, len(undecoded))
+			for i, key := range undecoded {
+				keys[i] = key.String()
+			}
+			return fmt.Errorf("unknown keys in TOML config file %s: %s", path, strings.Join(keys, ", "))
+		}
+	default:
+		return fmt.Errorf("config file %s must end in .yaml, .yml, or .toml", path)
+	}
+
+	return nil
+}
+
+// applyEnv walks the struct and overwrites every field that has an `env` tag and a matching, non-empty env var.
+// It returns one error per env var that can't be parsed into its field's type.
+func applyEnv(v reflect.Value) []error {
+	var errs []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			errs = append(errs, applyEnv(value)...)
+			continue
+		}
+
+		key := field.Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		raw := strings.TrimSpace(os.Getenv(key))
+		if raw == "" {
+			continue
+		}
+		if err := setField(value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errs
+}
+
+// setField parses raw into the field's type. Lists are comma-separated.
+func setField(value reflect.Value, raw string) error {
+	if value.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q (use a form like 10s or 1m30s)", raw)
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		value.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		value.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported field type %s", value.Type())
+	}
+	return nil
+}
+
+// Redacted returns a human-readable dump of the config, one KEY=value line per setting,
+// with passwords and other secrets masked. It's meant for debugging, so it's safe to log.
+func (c *Config) Redacted() string {
+	var b strings.Builder
+	dumpFields(&b, reflect.ValueOf(c).Elem())
+	return b.String()
+}
+
+func dumpFields(b *strings.Builder, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			dumpFields(b, value)
+			continue
+		}
+
+		key := field.Tag.Get("env")
+		if key == "" {
+			continue
+		}
+
+		var text string
+		switch {
+		case field.Tag.Get("secret") == "true":
+			text = redact(value.String())
+		case value.Type() == durationType:
+			text = time.Duration(value.Int()).String()
+		case value.Kind() == reflect.Slice:
+			text = strings.Join(value.Interface().([]string), ",")
+		default:
+			text = fmt.Sprint(value.Interface())
+		}
+		fmt.Fprintf(b, "%s=%s\n", key, text)
+	}
+}
+
+// redact masks a secret. For URLs with a password, it keeps everything but the password so the dump stays useful.
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if parsed, err := url.Parse(secret); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+		return parsed.Redacted()
+	}
+	return "xxxxx"
+}
@@ -3,9 +3,9 @@ package db
 import (
 	"context"
 	"fmt"
-	"os"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vdavid/web-annotator/backend/internal/config"
 )
 
 // Pool holds the database connection pool.
@@ -13,28 +13,23 @@ type Pool struct {
 	*pgxpool.Pool
 }
 
-// NewPool creates a new database connection pool.
-func NewPool(ctx context.Context) (*Pool, error) {
-	connStr := os.Getenv("DATABASE_URL")
-	if connStr == "" {
-		host := os.Getenv("DB_HOST")
-		port := os.Getenv("DB_PORT")
-		user := os.Getenv("DB_USER")
-		password := os.Getenv("DB_PASSWORD")
-		dbname := os.Getenv("DB_NAME")
-		if host == "" || port == "" || user == "" || password == "" || dbname == "" {
-			return nil, fmt.Errorf("database configuration missing: set DATABASE_URL or DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, and DB_NAME")
-		}
-		connStr = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-			user, password, host, port, dbname)
+// NewPool creates a new database connection pool from the validated database config.
+func NewPool(ctx context.Context, cfg config.DatabaseConfig) (*Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.ConnString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database config: %w", err)
 	}
+	poolConfig.MaxConns = int32(cfg.MaxConns)
+	poolConfig.MinConns = int32(cfg.MinConns)
+	poolConfig.ConnConfig.ConnectTimeout = cfg.ConnectTimeout
 
-	pool, err := pgxpool.New(ctx, connStr)
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
 	}
 
 	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
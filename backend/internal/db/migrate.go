@@ -0,0 +1,239 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// migrationLockID is the key for the Postgres advisory lock held while migrating.
+// It's an arbitrary constant; it only needs to be the same across all server replicas.
+const migrationLockID int64 = 52181_0001
+
+// migrationFilePattern matches golang-migrate style names like "000001_initial_schema.up.sql".
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// ErrDirtyDatabase is returned when a previous migration run failed halfway and needs manual fixing.
+var ErrDirtyDatabase = errors.New("database is marked dirty by a failed migration: fix the schema by hand, then clear the dirty flag in schema_migrations")
+
+// Migration is one versioned schema change with its up and down SQL.
+type Migration struct {
+	Version int64
+	Name    string
+	UpSQL   string
+	DownSQL string
+}
+
+// Migrator applies the embedded SQL migrations.
+// It uses the same schema_migrations table as golang-migrate, so databases migrated
+// with the migrate CLI or container keep working.
+type Migrator struct {
+	pool       *Pool
+	migrations []Migration
+}
+
+// NewMigrator creates a migrator for the migration files in source, for example migrations.FS.
+func NewMigrator(pool *Pool, source fs.FS) (*Migrator, error) {
+	migrations, err := LoadMigrations(source)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: migrations}, nil
+}
+
+// LoadMigrations reads and pairs up the migration files in the root of source, sorted by version.
+// Every version must have an up file. A missing down file means that version can't be rolled back.
+func LoadMigrations(source fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(source, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s doesn't match {version}_{title}.{up|down}.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration file %s has an invalid version: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(source, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.UpSQL = string(content)
+		} else {
+			migration.DownSQL = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.UpSQL == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up applies every pending migration and returns how many it applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *pgx.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if migration.Version <= current {
+				continue
+			}
+			if err := runMigration(ctx, conn, migration.UpSQL, migration.Version); err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the latest steps migrations and returns how many it rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if steps < 1 {
+		return 0, fmt.Errorf("steps must be at least 1, got %d", steps)
+	}
+
+	rolledBack := 0
+	err := m.withLock(ctx, func(conn *pgx.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && rolledBack < steps; i-- {
+			migration := m.migrations[i]
+			if migration.Version > current {
+				continue
+			}
+			if migration.DownSQL == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+			// After rolling back, the schema is at the previous migration's version, or empty
+			var previous int64 = -1
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+			if err := runMigration(ctx, conn, migration.DownSQL, previous); err != nil {
+				return fmt.Errorf("failed to roll back migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			rolledBack++
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// withLock runs fn on a dedicated connection while holding the migration advisory lock,
+// so only one server replica migrates at a time. The others wait, then find nothing to do.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgx.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Use a fresh context so the lock is released even if ctx was canceled
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID); err != nil {
+			fmt.Printf("Failed to release migration lock: %v\n", err)
+		}
+	}()
+
+	if _, err := conn.Exec(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT NOT NULL PRIMARY KEY,
+			dirty BOOLEAN NOT NULL
+		)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	return fn(conn.Conn())
+}
+
+// currentVersion returns the applied schema version, or -1 for an empty database.
+func currentVersion(ctx context.Context, conn *pgx.Conn) (int64, error) {
+	var version int64
+	var dirty bool
+	err := conn.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return -1, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if dirty {
+		return 0, ErrDirtyDatabase
+	}
+	return version, nil
+}
+
+// runMigration executes one migration file and records the new version in the same transaction,
+// so a failing migration leaves the schema untouched instead of dirty.
+func runMigration(ctx context.Context, conn *pgx.Conn, sql string, newVersion int64) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func(tx pgx.Tx, ctx context.Context) {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			fmt.Printf("Failed to rollback transaction: %v\n", err)
+		}
+	}(tx, ctx)
+
+	// Exec without arguments uses the simple protocol, which allows multiple statements per file
+	if _, err := tx.Exec(ctx, sql); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return fmt.Errorf("failed to clear schema version: %w", err)
+	}
+	if newVersion >= 0 {
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, newVersion); err != nil {
+			return fmt.Errorf("failed to record schema version: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
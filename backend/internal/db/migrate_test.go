@@ -0,0 +1,128 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/vdavid/web-annotator/backend/internal/db"
+	"github.com/vdavid/web-annotator/backend/internal/testutil"
+	"github.com/vdavid/web-annotator/backend/migrations"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name          string
+		files         fstest.MapFS
+		expectedCount int
+		wantErr       bool
+	}{
+		{
+			name: "pairs and sorts by version",
+			files: fstest.MapFS{
+				"000002_second.up.sql":   {Data: []byte("SELECT 2")},
+				"000002_second.down.sql": {Data: []byte("SELECT -2")},
+				"000001_first.up.sql":    {Data: []byte("SELECT 1")},
+				"000001_first.down.sql":  {Data: []byte("SELECT -1")},
+				"migrations.go":          {Data: []byte("package migrations")},
+			},
+			expectedCount: 2,
+		},
+		{
+			name: "down file is optional",
+			files: fstest.MapFS{
+				"000001_first.up.sql": {Data: []byte("SELECT 1")},
+			},
+			expectedCount: 1,
+		},
+		{
+			name: "missing up file",
+			files: fstest.MapFS{
+				"000001_first.down.sql": {Data: []byte("SELECT -1")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "bad file name",
+			files: fstest.MapFS{
+				"first.up.sql": {Data: []byte("SELECT 1")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "conflicting names for one version",
+			files: fstest.MapFS{
+				"000001_first.up.sql":   {Data: []byte("SELECT 1")},
+				"000001_other.down.sql": {Data: []byte("SELECT -1")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.LoadMigrations(tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadMigrations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.expectedCount {
+				t.Fatalf("LoadMigrations() returned %d migrations, want %d", len(got), tt.expectedCount)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i-1].Version >= got[i].Version {
+					t.Errorf("Migrations not sorted: %d before %d", got[i-1].Version, got[i].Version)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadMigrations_Embedded(t *testing.T) {
+	got, err := db.LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("LoadMigrations() error = %v", err)
+	}
+	if len(got) == 0 {
+		t.Fatal("Expected at least one embedded migration")
+	}
+	for _, migration := range got {
+		if migration.DownSQL == "" {
+			t.Errorf("Migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	pool := testutil.NewTestPool(t) // Already migrated up
+	ctx := context.Background()
+
+	migrator, err := db.NewMigrator(pool, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if applied != 0 {
+		t.Errorf("Up() on a migrated database applied %d migrations, want 0", applied)
+	}
+
+	all, _ := db.LoadMigrations(migrations.FS)
+	rolledBack, err := migrator.Down(ctx, len(all))
+	if err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+	if rolledBack != len(all) {
+		t.Errorf("Down() rolled back %d migrations, want %d", rolledBack, len(all))
+	}
+
+	applied, err = migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up() after Down() error = %v", err)
+	}
+	if applied != len(all) {
+		t.Errorf("Up() after Down() applied %d migrations, want %d", applied, len(all))
+	}
+}
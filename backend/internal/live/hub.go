@@ -0,0 +1,129 @@
+// Package live pushes page stats updates to clients that keep a stream open.
+package live
+
+import (
+	"context"
+	"sync"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+// Update is the new state of a page's stats after a rating change.
+// It only carries the histogram, since all other stats derive from it, and that keeps Postgres notifications small.
+type Update struct {
+	URLHash   string               `json:"url_hash"`
+	Histogram [models.MaxScore]int `json:"histogram"`
+}
+
+// Transport carries updates between server replicas. Every replica, including the sender,
+// must get each sent update back and hand it to Hub.Deliver.
+type Transport interface {
+	Send(ctx context.Context, update Update) error
+}
+
+// Hub fans out page stats updates to the subscribers of each page.
+// Without a transport, updates only reach subscribers on this server.
+// A nil *Hub is valid and drops every update, so callers don't need to check whether streaming is on.
+type Hub struct {
+	transport Transport
+
+	mu          sync.Mutex
+	subscribers map[string]map[chan models.PageStats]struct{}
+	closed      bool
+}
+
+// NewHub creates a hub. The transport may be nil for a single server.
+func NewHub(transport Transport) *Hub {
+	return &Hub{
+		transport:   transport,
+		subscribers: make(map[string]map[chan models.PageStats]struct{}),
+	}
+}
+
+// Subscribe returns a channel of stats updates for the page with the given URL hash, and a function to unsubscribe.
+// The channel only holds the latest update: a slow reader skips intermediate ones rather than falling behind.
+// The channel is closed when the hub is closed.
+func (h *Hub) Subscribe(urlHash string) (<-chan models.PageStats, func()) {
+	updates := make(chan models.PageStats, 1)
+	if h == nil {
+		close(updates)
+		return updates, func() {}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(updates)
+		return updates, func() {}
+	}
+	if h.subscribers[urlHash] == nil {
+		h.subscribers[urlHash] = make(map[chan models.PageStats]struct{})
+	}
+	h.subscribers[urlHash][updates] = struct{}{}
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[urlHash][updates]; !ok {
+			return // Already closed by Close
+		}
+		delete(h.subscribers[urlHash], updates)
+		if len(h.subscribers[urlHash]) == 0 {
+			delete(h.subscribers, urlHash)
+		}
+		close(updates)
+	}
+	return updates, unsubscribe
+}
+
+// Publish announces a page's new stats to its subscribers on every replica.
+func (h *Hub) Publish(ctx context.Context, urlHash string, stats *models.PageStats) error {
+	if h == nil {
+		return nil
+	}
+	update := Update{URLHash: urlHash, Histogram: stats.Histogram}
+	if h.transport != nil {
+		return h.transport.Send(ctx, update)
+	}
+	h.Deliver(update)
+	return nil
+}
+
+// Deliver passes an update to this server's subscribers of the page. Transports call it for each update they receive.
+func (h *Hub) Deliver(update Update) {
+	if h == nil {
+		return
+	}
+	stats := *models.NewPageStats(update.Histogram)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for updates := range h.subscribers[update.URLHash] {
+		// Replace any update the subscriber hasn't read yet
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- stats
+	}
+}
+
+// Close closes every subscriber's channel, so open streams end, for example on shutdown.
+func (h *Hub) Close() {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for urlHash, pageSubscribers := range h.subscribers {
+		for updates := range pageSubscribers {
+			close(updates)
+		}
+		delete(h.subscribers, urlHash)
+	}
+}
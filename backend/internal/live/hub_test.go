@@ -0,0 +1,138 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+type mockTransport struct {
+	sent    []Update
+	sendErr error
+}
+
+func (m *mockTransport) Send(ctx context.Context, update Update) error {
+	m.sent = append(m.sent, update)
+	return m.sendErr
+}
+
+func TestHub_FansOutToSubscribersOfThePage(t *testing.T) {
+	hub := NewHub(nil)
+	first, unsubscribeFirst := hub.Subscribe("a")
+	defer unsubscribeFirst()
+	second, unsubscribeSecond := hub.Subscribe("a")
+	defer unsubscribeSecond()
+	other, unsubscribeOther := hub.Subscribe("b")
+	defer unsubscribeOther()
+
+	stats := models.NewPageStats([models.MaxScore]int{6: 2})
+	if err := hub.Publish(context.Background(), "a", stats); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, updates := range map[string]<-chan models.PageStats{"first": first, "second": second} {
+		select {
+		case got := <-updates:
+			if got != *stats {
+				t.Errorf("%s: expected %+v, got %+v", name, *stats, got)
+			}
+		default:
+			t.Errorf("%s: expected an update", name)
+		}
+	}
+	select {
+	case got := <-other:
+		t.Errorf("Expected no update for another page, got %+v", got)
+	default:
+	}
+}
+
+func TestHub_KeepsOnlyTheLatestUpdate(t *testing.T) {
+	hub := NewHub(nil)
+	updates, unsubscribe := hub.Subscribe("a")
+	defer unsubscribe()
+
+	hub.Deliver(Update{URLHash: "a", Histogram: [models.MaxScore]int{0: 1}})
+	hub.Deliver(Update{URLHash: "a", Histogram: [models.MaxScore]int{9: 1}})
+
+	got := <-updates
+	if got.AverageScore != 10 {
+		t.Errorf("Expected the latest update, got %+v", got)
+	}
+	select {
+	case got := <-updates:
+		t.Errorf("Expected a single update, got another: %+v", got)
+	default:
+	}
+}
+
+func TestHub_Unsubscribe(t *testing.T) {
+	hub := NewHub(nil)
+	updates, unsubscribe := hub.Subscribe("a")
+	unsubscribe()
+	unsubscribe() // Must be safe to call twice
+
+	if _, open := <-updates; open {
+		t.Error("Expected the channel to be closed")
+	}
+	if len(hub.subscribers) != 0 {
+		t.Errorf("Expected no subscribers left, got %d pages", len(hub.subscribers))
+	}
+	hub.Deliver(Update{URLHash: "a"}) // Must not panic on the closed channel
+}
+
+func TestHub_Close(t *testing.T) {
+	hub := NewHub(nil)
+	updates, unsubscribe := hub.Subscribe("a")
+	hub.Close()
+	unsubscribe() // Must not close the channel a second time
+
+	if _, open := <-updates; open {
+		t.Error("Expected the channel to be closed")
+	}
+	late, _ := hub.Subscribe("a")
+	if _, open := <-late; open {
+		t.Error("Expected subscribing to a closed hub to return a closed channel")
+	}
+}
+
+func TestHub_PublishGoesThroughTransport(t *testing.T) {
+	transport := &mockTransport{}
+	hub := NewHub(transport)
+	updates, unsubscribe := hub.Subscribe("a")
+	defer unsubscribe()
+
+	stats := models.NewPageStats([models.MaxScore]int{3: 1})
+	if err := hub.Publish(context.Background(), "a", stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.sent) != 1 || transport.sent[0].URLHash != "a" || transport.sent[0].Histogram != stats.Histogram {
+		t.Fatalf("Expected the update to be sent, got %+v", transport.sent)
+	}
+	select {
+	case got := <-updates:
+		t.Errorf("Expected delivery to wait for the transport, got %+v", got)
+	default:
+	}
+
+	transport.sendErr = errors.New("connection lost")
+	if err := hub.Publish(context.Background(), "a", stats); err == nil {
+		t.Error("Expected the transport error")
+	}
+}
+
+func TestHub_Nil(t *testing.T) {
+	var hub *Hub
+	updates, unsubscribe := hub.Subscribe("a")
+	unsubscribe()
+	if _, open := <-updates; open {
+		t.Error("Expected a closed channel")
+	}
+	if err := hub.Publish(context.Background(), "a", &models.PageStats{}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	hub.Deliver(Update{URLHash: "a"})
+	hub.Close()
+}
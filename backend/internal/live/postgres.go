@@ -0,0 +1,92 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vdavid/web-annotator/backend/internal/db"
+)
+
+// notifyChannel is the Postgres channel updates go through.
+const notifyChannel = "page_stats"
+
+// PostgresTransport sends updates with NOTIFY and receives them with LISTEN, so every replica connected
+// to the same database gets them. Notifications are sent on commit and are lost if nobody's listening,
+// which is fine here: a stream that misses an update gets the next one.
+type PostgresTransport struct {
+	pool *db.Pool
+}
+
+// NewPostgresTransport creates a transport on the given pool. Call Listen to start receiving.
+func NewPostgresTransport(pool *db.Pool) *PostgresTransport {
+	return &PostgresTransport{pool: pool}
+}
+
+// Send notifies every listener, including this server's, of the update.
+func (t *PostgresTransport) Send(ctx context.Context, update Update) error {
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("failed to encode update: %w", err)
+	}
+	if _, err := t.pool.Exec(ctx, `SELECT pg_notify($1, $2)`, notifyChannel, string(payload)); err != nil {
+		return fmt.Errorf("failed to send update: %w", err)
+	}
+	return nil
+}
+
+// Listen passes every update from the database to deliver, until ctx is done.
+// It holds one pool connection for the whole time, and reconnects after errors.
+func (t *PostgresTransport) Listen(ctx context.Context, deliver func(Update)) {
+	const retryDelay = 5 * time.Second
+
+	for {
+		err := t.listen(ctx, deliver)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Error: lost the page stats listener, retrying in %s: %v", retryDelay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+// listen runs one LISTEN session until it fails or ctx is done.
+func (t *PostgresTransport) listen(ctx context.Context, deliver func(Update)) error {
+	conn, err := t.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{notifyChannel}.Sanitize()); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	// The session's LISTEN must not leak to whoever gets this connection from the pool next
+	defer func() {
+		if _, err := conn.Exec(context.Background(), "UNLISTEN *"); err != nil {
+			conn.Conn().Close(context.Background())
+		}
+	}()
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var update Update
+		if err := json.Unmarshal([]byte(notification.Payload), &update); err != nil {
+			log.Printf("Error: ignoring malformed page stats notification: %v", err)
+			continue
+		}
+		deliver(update)
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+// Scopes a personal API token can have.
+const (
+	ScopeRatingsRead  = "ratings:read"
+	ScopeRatingsWrite = "ratings:write"
+)
+
+// ValidScopes lists every scope a token may be granted.
+var ValidScopes = []string{ScopeRatingsRead, ScopeRatingsWrite}
+
+const scopesKey contextKey = "scopes"
+
+// APITokenStore resolves a personal API token by its hash.
+// It returns nil for unknown and revoked tokens, and an error only if the lookup itself fails.
+type APITokenStore interface {
+	UseToken(ctx context.Context, tokenHash string) (*models.APIToken, error)
+}
+
+// ScopesFromContext returns the scopes of the API token that authenticated the request.
+// ok is false if the request used the primary auth method, which has no scope limits.
+func ScopesFromContext(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(scopesKey).([]string)
+	return scopes, ok
+}
+
+// hasScope reports whether the request may do what scope allows.
+func hasScope(ctx context.Context, scope string) bool {
+	scopes, isAPIToken := ScopesFromContext(ctx)
+	return !isAPIToken || slices.Contains(scopes, scope)
+}
+
+// RequireScope rejects requests authenticated by an API token that lacks the given scope.
+// Requests authenticated by the primary method always pass. Use it inside AuthMiddleware.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !hasScope(r.Context(), scope) {
+				http.Error(w, "Forbidden: this API token lacks the "+scope+" scope", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePrimaryAuth rejects requests authenticated by an API token.
+// Use it for endpoints like token management, so a leaked token can't mint more tokens.
+func RequirePrimaryAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, isAPIToken := ScopesFromContext(r.Context()); isAPIToken {
+			http.Error(w, "Forbidden: API tokens can't be used here", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+// mockAPITokenStore knows exactly one token, and fails to look up "wa_broken".
+type mockAPITokenStore struct {
+	token  string
+	scopes []string
+}
+
+func (m *mockAPITokenStore) UseToken(_ context.Context, tokenHash string) (*models.APIToken, error) {
+	if tokenHash == utils.HashAPIToken("wa_broken") {
+		return nil, errors.New("connection refused")
+	}
+	if tokenHash != utils.HashAPIToken(m.token) {
+		return nil, nil
+	}
+	return &models.APIToken{UserID: testUserID, Scopes: m.scopes}, nil
+}
+
+func TestAuthMiddleware_APITokens(t *testing.T) {
+	store := &mockAPITokenStore{token: "wa_valid-token", scopes: []string{ScopeRatingsRead}}
+
+	tests := []struct {
+		name           string
+		authorization  string
+		userIDHeader   string
+		scope          string
+		expectedStatus int
+	}{
+		{
+			name:           "token with scope",
+			authorization:  "Bearer wa_valid-token",
+			scope:          ScopeRatingsRead,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "token without scope",
+			authorization:  "Bearer wa_valid-token",
+			scope:          ScopeRatingsWrite,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "unknown token",
+			authorization:  "Bearer wa_other-token",
+			scope:          ScopeRatingsRead,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "failed lookup",
+			authorization:  "Bearer wa_broken",
+			scope:          ScopeRatingsRead,
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "primary auth has every scope",
+			userIDHeader:   testUserID,
+			scope:          ScopeRatingsWrite,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := AuthMiddleware(HeaderAuthenticator{}, store)(RequireScope(tt.scope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if userID, _ := UserIDFromContext(r.Context()); userID != testUserID {
+					t.Errorf("Expected user ID %s, got %s", testUserID, userID)
+				}
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.userIDHeader != "" {
+				req.Header.Set("X-User-ID", tt.userIDHeader)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestRequirePrimaryAuth(t *testing.T) {
+	store := &mockAPITokenStore{token: "wa_valid-token", scopes: ValidScopes}
+	handler := AuthMiddleware(HeaderAuthenticator{}, store)(RequirePrimaryAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	tokenReq := httptest.NewRequest(http.MethodGet, "/test", nil)
+	tokenReq.Header.Set("Authorization", "Bearer wa_valid-token")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, tokenReq)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for an API token, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	primaryReq := httptest.NewRequest(http.MethodGet, "/test", nil)
+	primaryReq.Header.Set("X-User-ID", testUserID)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, primaryReq)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d for primary auth, got %d", http.StatusOK, rr.Code)
+	}
+}
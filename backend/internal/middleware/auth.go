@@ -2,31 +2,103 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
+
+	"github.com/vdavid/web-annotator/backend/internal/utils"
 )
 
 type contextKey string
 
 const userIDKey contextKey = "userID"
 
+// ErrNoCredentials is returned by an Authenticator when the request carries no credentials it understands.
+var ErrNoCredentials = errors.New("no credentials provided")
+
+// Authenticator identifies the user behind a request.
+// It returns the user's ID (a UUID, as stored in the users table) or an error if the request isn't authenticated.
+type Authenticator interface {
+	Authenticate(r *http.Request) (string, error)
+}
+
 // UserIDFromContext extracts the user ID from the request context.
 func UserIDFromContext(ctx context.Context) (string, bool) {
 	userID, ok := ctx.Value(userIDKey).(string)
 	return userID, ok
 }
 
-// AuthMiddleware reads the X-User-ID header and injects it into the request context.
-// This is a mock authentication middleware for MVP. It can be swapped for real Auth0 later.
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userID := strings.TrimSpace(r.Header.Get("X-User-ID"))
-		if userID == "" {
-			http.Error(w, "Missing X-User-ID header", http.StatusUnauthorized)
-			return
-		}
-
-		ctx := context.WithValue(r.Context(), userIDKey, userID)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+// ContextWithUserID returns a copy of ctx carrying the given user ID.
+func ContextWithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// AuthMiddleware authenticates each request and injects the user ID into the request context.
+// Requests with an "Authorization: Bearer wa_..." personal API token are checked against tokens,
+// and their scopes are added to the context too. Everything else goes to the primary authenticator.
+// tokens may be nil to turn off API tokens. Requests that fail authentication get a 401, and ones whose token can't be
+// looked up get a 500.
+func AuthMiddleware(authenticator Authenticator, tokens APITokenStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			if raw, err := bearerToken(r); err == nil && tokens != nil && utils.IsAPIToken(raw) {
+				token, err := tokens.UseToken(ctx, utils.HashAPIToken(raw))
+				if err != nil {
+					// Not the client's fault, so don't make it look like bad credentials
+					log.Printf("Failed to look up API token: %v", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					return
+				}
+				if token == nil {
+					unauthorized(w, errors.New("invalid or revoked API token"))
+					return
+				}
+				ctx = context.WithValue(ContextWithUserID(ctx, token.UserID), scopesKey, token.Scopes)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			userID, err := authenticator.Authenticate(r)
+			if err != nil {
+				unauthorized(w, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ContextWithUserID(ctx, userID)))
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="web-annotator"`)
+	http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+}
+
+// HeaderAuthenticator trusts the X-User-ID header as is.
+// It's the mock authentication from the MVP and lets anyone act as anyone, so only enable it for development.
+type HeaderAuthenticator struct{}
+
+// Authenticate returns the X-User-ID header value.
+func (HeaderAuthenticator) Authenticate(r *http.Request) (string, error) {
+	userID := strings.TrimSpace(r.Header.Get("X-User-ID"))
+	if userID == "" {
+		return "", errors.New("missing X-User-ID header")
+	}
+	return userID, nil
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header.
+func bearerToken(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", ErrNoCredentials
+	}
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", errors.New("malformed Authorization header, expected \"Bearer <token>\"")
+	}
+	return strings.TrimSpace(token), nil
 }
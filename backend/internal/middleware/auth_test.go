@@ -7,7 +7,7 @@ import (
 	"testing"
 )
 
-func TestAuthMiddleware(t *testing.T) {
+func TestAuthMiddleware_HeaderAuthenticator(t *testing.T) {
 	tests := []struct {
 		name           string
 		headerValue    string
@@ -40,7 +40,7 @@ func TestAuthMiddleware(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			handler := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := AuthMiddleware(HeaderAuthenticator{}, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				userID, ok := UserIDFromContext(r.Context())
 				if tt.shouldHaveID && !ok {
 					t.Errorf("Expected user ID in context, but not found")
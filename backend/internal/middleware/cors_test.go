@@ -3,6 +3,8 @@ package middleware
 import (
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -35,7 +37,7 @@ func TestCORSMiddleware(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			handler := CORSMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := CORSMiddleware([]string{"*"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusOK)
 			}))
 
@@ -62,3 +64,77 @@ func TestCORSMiddleware(t *testing.T) {
 		})
 	}
 }
+
+// TestCORSMiddleware_PreflightMethods checks that every method the API routes use passes the preflight,
+// like PUT /api/v1/pages.
+func TestCORSMiddleware_PreflightMethods(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete} {
+		t.Run(method, func(t *testing.T) {
+			handler := CORSMiddleware([]string{"chrome-extension://abcdef"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Error("Expected the preflight not to reach the handler")
+			}))
+
+			req := httptest.NewRequest(http.MethodOptions, "/api/v1/pages", nil)
+			req.Header.Set("Origin", "chrome-extension://abcdef")
+			req.Header.Set("Access-Control-Request-Method", method)
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusNoContent {
+				t.Errorf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+			}
+			allowed := strings.Split(rr.Header().Get("Access-Control-Allow-Methods"), ", ")
+			if !slices.Contains(allowed, method) {
+				t.Errorf("Expected %s in Access-Control-Allow-Methods, got %v", method, allowed)
+			}
+		})
+	}
+}
+
+func TestCORSMiddleware_AllowedOrigins(t *testing.T) {
+	tests := []struct {
+		name           string
+		origin         string
+		expectedOrigin string
+	}{
+		{
+			name:           "allowed origin is echoed back",
+			origin:         "chrome-extension://abcdef",
+			expectedOrigin: "chrome-extension://abcdef",
+		},
+		{
+			name:           "unknown origin gets no allow header",
+			origin:         "https://evil.example.com",
+			expectedOrigin: "",
+		},
+		{
+			name:           "no origin header",
+			origin:         "",
+			expectedOrigin: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CORSMiddleware([]string{"chrome-extension://abcdef"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got != tt.expectedOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tt.expectedOrigin, got)
+			}
+			if rr.Header().Get("Vary") != "Origin" {
+				t.Errorf("Expected Vary: Origin header")
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxJWKSSize caps how much of a JWKS response we read. Real key sets are a few KB.
+const maxJWKSSize = 1 << 20
+
+// jwk is the subset of a JSON Web Key (RFC 7517) we need for RS256.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// parseJWKS reads the RSA signing keys from a JWKS document, keyed by "kid".
+// Keys of other types or for other uses are skipped.
+func parseJWKS(data []byte) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") || (key.Alg != "" && key.Alg != "RS256") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %q has an invalid modulus: %w", key.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %q has an invalid exponent: %w", key.Kid, err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("JWKS key %q has an unsupported exponent", key.Kid)
+		}
+		keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no RS256 signing keys")
+	}
+	return keys, nil
+}
+
+// lookupKey finds a key by ID. An empty kid is accepted if the set has exactly one key.
+func lookupKey(keys map[string]*rsa.PublicKey, kid string) (*rsa.PublicKey, bool) {
+	if key, ok := keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// StaticKeySet is a fixed set of RSA keys, typically loaded from a JWKS file.
+type StaticKeySet struct {
+	keys map[string]*rsa.PublicKey
+}
+
+// LoadJWKSFile reads a JWKS file from disk.
+func LoadJWKSFile(path string) (*StaticKeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticKeySet{keys: keys}, nil
+}
+
+// Key returns the key with the given ID.
+func (s *StaticKeySet) Key(kid string) (any, error) {
+	if key, ok := lookupKey(s.keys, kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key ID %q", kid)
+}
+
+// RemoteKeySet fetches RSA keys from a JWKS URL and caches them.
+// When it sees an unknown key ID (for example, after the provider rotated its keys), it refetches,
+// but at most once per minRefreshInterval so bad tokens can't make us hammer the provider.
+type RemoteKeySet struct {
+	url    string
+	client *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	lastFetched time.Time
+}
+
+// minRefreshInterval limits how often RemoteKeySet refetches the JWKS.
+const minRefreshInterval = time.Minute
+
+// NewRemoteKeySet creates a key set for the given JWKS URL and fetches it once to fail fast on misconfiguration.
+func NewRemoteKeySet(ctx context.Context, url string, client *http.Client) (*RemoteKeySet, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	set := &RemoteKeySet{url: url, client: client}
+	if err := set.refresh(ctx); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Key returns the key with the given ID, refetching the JWKS if the ID is unknown.
+func (s *RemoteKeySet) Key(kid string) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := lookupKey(s.keys, kid); ok {
+		return key, nil
+	}
+	if time.Since(s.lastFetched) >= minRefreshInterval {
+		if err := s.refresh(context.Background()); err != nil {
+			return nil, err
+		}
+		if key, ok := lookupKey(s.keys, kid); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key ID %q", kid)
+}
+
+// refresh fetches the JWKS. Callers other than the constructor must hold s.mu.
+func (s *RemoteKeySet) refresh(ctx context.Context) error {
+	s.lastFetched = time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	s.keys = keys
+	return nil
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+// JWTOptions configures a JWTAuthenticator.
+// At least one of HMACSecret and Keys must be set.
+type JWTOptions struct {
+	HMACSecret []byte        // Enables HS256 if set
+	Keys       KeySource     // Enables RS256 if set, usually a JWKS file or URL
+	Issuer     string        // Required "iss" claim. Empty means any issuer.
+	Audience   string        // Required "aud" claim
+	Leeway     time.Duration // Allowed clock skew for "exp", "nbf", and "iat"
+	Users      UserStore     // If set, stores each user with the display name from their token
+}
+
+// UserStore creates a user with the given ID, or updates their username.
+type UserStore interface {
+	UpsertUser(ctx context.Context, userID string, username string) error
+}
+
+// jwtClaims are the claims we read. Name and PreferredUsername are the OpenID Connect display name claims.
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// KeySource looks up an RSA public key by its key ID ("kid" header).
+type KeySource interface {
+	Key(kid string) (any, error)
+}
+
+// JWTAuthenticator validates signed bearer tokens (JWT) and maps their subject to a user ID.
+type JWTAuthenticator struct {
+	options JWTOptions
+	parser  *jwt.Parser
+
+	// known caches the usernames we've already stored, so we only hit the DB when one changes.
+	// Keys are user IDs, values are usernames.
+	known sync.Map
+}
+
+// NewJWTAuthenticator creates a JWT authenticator.
+func NewJWTAuthenticator(options JWTOptions) (*JWTAuthenticator, error) {
+	var methods []string
+	if len(options.HMACSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if options.Keys != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("JWT authentication needs an HMAC secret or a key source")
+	}
+	if options.Audience == "" {
+		return nil, errors.New("JWT authentication needs an audience")
+	}
+
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithAudience(options.Audience),
+		jwt.WithLeeway(options.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if options.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(options.Issuer))
+	}
+
+	return &JWTAuthenticator{
+		options: options,
+		parser:  jwt.NewParser(parserOptions...),
+	}, nil
+}
+
+// Authenticate validates the bearer token and returns the user ID for its "sub" claim.
+// A subject that's already a UUID is used as is. Any other subject is turned into a stable UUID
+// derived from the issuer and subject, so the same identity always maps to the same user.
+// With a user store, it also stores the user, named by the "name" claim, else "preferred_username", else the subject.
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (string, error) {
+	raw, err := bearerToken(r)
+	if err != nil {
+		return "", err
+	}
+
+	var claims jwtClaims
+	if _, err := a.parser.ParseWithClaims(raw, &claims, a.key); err != nil {
+		return "", fmt.Errorf("invalid token: %w", err)
+	}
+
+	if claims.Subject == "" {
+		return "", errors.New("invalid token: missing subject")
+	}
+	userID := claims.Subject
+	if !utils.IsUUID(userID) {
+		userID = utils.NameUUID(claims.Issuer + "|" + claims.Subject)
+	}
+
+	if a.options.Users != nil {
+		username := cmp.Or(strings.TrimSpace(claims.Name), strings.TrimSpace(claims.PreferredUsername), claims.Subject)
+		if cached, ok := a.known.Load(userID); !ok || cached.(string) != username {
+			if err := a.options.Users.UpsertUser(r.Context(), userID, username); err != nil {
+				return "", fmt.Errorf("failed to store user: %w", err)
+			}
+			a.known.Store(userID, username)
+		}
+	}
+
+	return userID, nil
+}
+
+// key picks the verification key for a token. The parser has already checked that the algorithm is allowed.
+func (a *JWTAuthenticator) key(token *jwt.Token) (any, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return a.options.HMACSecret, nil
+	case *jwt.SigningMethodRSA:
+		kid, _ := token.Header["kid"].(string)
+		return a.options.Keys.Key(kid)
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
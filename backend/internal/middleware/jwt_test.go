@@ -0,0 +1,367 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+const (
+	testSecret   = "test-secret-that-is-at-least-32-chars"
+	testAudience = "web-annotator"
+	testIssuer   = "https://auth.example.com"
+	testUserID   = "123e4567-e89b-12d3-a456-426614174000"
+)
+
+// validClaims returns claims that pass validation. Tests tweak them to break one thing at a time.
+func validClaims() jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Subject:   testUserID,
+		Issuer:    testIssuer,
+		Audience:  jwt.ClaimStrings{testAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+}
+
+func signHS256(t *testing.T, claims jwt.RegisteredClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.RegisteredClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// jwksJSON encodes the public half of key as a one-key JWKS document.
+func jwksJSON(t *testing.T, key *rsa.PrivateKey, kid string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func authenticate(t *testing.T, authenticator Authenticator, authorization string) (string, error) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return authenticator.Authenticate(req)
+}
+
+func TestJWTAuthenticator_HS256(t *testing.T) {
+	authenticator, err := NewJWTAuthenticator(JWTOptions{
+		HMACSecret: []byte(testSecret),
+		Issuer:     testIssuer,
+		Audience:   testAudience,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	wrongAudience := validClaims()
+	wrongAudience.Audience = jwt.ClaimStrings{"someone-else"}
+	wrongIssuer := validClaims()
+	wrongIssuer.Issuer = "https://evil.example.com"
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+	noSubject := validClaims()
+	noSubject.Subject = ""
+	externalSubject := validClaims()
+	externalSubject.Subject = "alice"
+
+	otherSecretToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString([]byte("another-secret-that-is-32-chars-long"))
+
+	tests := []struct {
+		name           string
+		authorization  string
+		expectedUserID string
+		wantErr        bool
+	}{
+		{
+			name:           "valid token",
+			authorization:  "Bearer " + signHS256(t, validClaims()),
+			expectedUserID: testUserID,
+		},
+		{
+			name:           "non-UUID subject maps to a stable UUID",
+			authorization:  "Bearer " + signHS256(t, externalSubject),
+			expectedUserID: utils.NameUUID(testIssuer + "|alice"),
+		},
+		{
+			name:          "missing header",
+			authorization: "",
+			wantErr:       true,
+		},
+		{
+			name:          "wrong scheme",
+			authorization: "Basic dXNlcjpwYXNz",
+			wantErr:       true,
+		},
+		{
+			name:          "malformed token",
+			authorization: "Bearer not.a.jwt",
+			wantErr:       true,
+		},
+		{
+			name:          "garbage token",
+			authorization: "Bearer garbage",
+			wantErr:       true,
+		},
+		{
+			name:          "expired token",
+			authorization: "Bearer " + signHS256(t, expired),
+			wantErr:       true,
+		},
+		{
+			name:          "wrong audience",
+			authorization: "Bearer " + signHS256(t, wrongAudience),
+			wantErr:       true,
+		},
+		{
+			name:          "wrong issuer",
+			authorization: "Bearer " + signHS256(t, wrongIssuer),
+			wantErr:       true,
+		},
+		{
+			name:          "missing expiry",
+			authorization: "Bearer " + signHS256(t, noExpiry),
+			wantErr:       true,
+		},
+		{
+			name:          "missing subject",
+			authorization: "Bearer " + signHS256(t, noSubject),
+			wantErr:       true,
+		},
+		{
+			name:          "wrong signature",
+			authorization: "Bearer " + otherSecretToken,
+			wantErr:       true,
+		},
+		{
+			name:          "unsigned token",
+			authorization: "Bearer " + unsignedToken(t),
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, err := authenticate(t, authenticator, tt.authorization)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if userID != tt.expectedUserID {
+				t.Errorf("Authenticate() = %q, want %q", userID, tt.expectedUserID)
+			}
+		})
+	}
+}
+
+// mockUserStore records the username stored for each user ID.
+type mockUserStore struct {
+	usernames map[string]string
+	upserts   int
+}
+
+func (m *mockUserStore) UpsertUser(_ context.Context, userID string, username string) error {
+	m.usernames[userID] = username
+	m.upserts++
+	return nil
+}
+
+func TestJWTAuthenticator_StoresUsers(t *testing.T) {
+	store := &mockUserStore{usernames: map[string]string{}}
+	authenticator, err := NewJWTAuthenticator(JWTOptions{HMACSecret: []byte(testSecret), Audience: testAudience, Users: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(subject string, name string, preferredUsername string) string {
+		claims := jwtClaims{RegisteredClaims: validClaims(), Name: name, PreferredUsername: preferredUsername}
+		claims.Subject = subject
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + token
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		expectedName  string
+	}{
+		{"name claim", sign(testUserID, "Alice Liddell", "alice"), "Alice Liddell"},
+		{"preferred username", sign(testUserID, "", "alice"), "alice"},
+		{"subject", sign("bob", "", ""), "bob"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, err := authenticate(t, authenticator, tt.authorization)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := store.usernames[userID]; got != tt.expectedName {
+				t.Errorf("Stored username = %q, want %q", got, tt.expectedName)
+			}
+		})
+	}
+
+	// An unchanged name doesn't hit the store again
+	upserts := store.upserts
+	if _, err := authenticate(t, authenticator, sign("bob", "", "")); err != nil {
+		t.Fatal(err)
+	}
+	if store.upserts != upserts {
+		t.Errorf("Expected a known user not to be stored again, got %d upserts, want %d", store.upserts, upserts)
+	}
+}
+
+func unsignedToken(t *testing.T) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestJWTAuthenticator_RS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwksPath := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(jwksPath, jwksJSON(t, key, "key-1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fileKeys, err := LoadJWKSFile(jwksPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(jwksJSON(t, key, "key-1"))
+	}))
+	defer jwksServer.Close()
+	remoteKeys, err := NewRemoteKeySet(t.Context(), jwksServer.URL, jwksServer.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	wrongAudience := validClaims()
+	wrongAudience.Audience = jwt.ClaimStrings{"someone-else"}
+
+	for name, keys := range map[string]KeySource{"file": fileKeys, "url": remoteKeys} {
+		t.Run(name, func(t *testing.T) {
+			authenticator, err := NewJWTAuthenticator(JWTOptions{Keys: keys, Audience: testAudience})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				name          string
+				authorization string
+				wantErr       bool
+			}{
+				{"valid token", "Bearer " + signRS256(t, key, "key-1", validClaims()), false},
+				{"unknown key ID", "Bearer " + signRS256(t, key, "key-2", validClaims()), true},
+				{"signed with another key", "Bearer " + signRS256(t, otherKey, "key-1", validClaims()), true},
+				{"expired token", "Bearer " + signRS256(t, key, "key-1", expired), true},
+				{"wrong audience", "Bearer " + signRS256(t, key, "key-1", wrongAudience), true},
+				{"HS256 not enabled", "Bearer " + signHS256(t, validClaims()), true},
+				{"malformed token", "Bearer a.b.c", true},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					userID, err := authenticate(t, authenticator, tt.authorization)
+					if (err != nil) != tt.wantErr {
+						t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+					}
+					if !tt.wantErr && userID != testUserID {
+						t.Errorf("Authenticate() = %q, want %q", userID, testUserID)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestNewJWTAuthenticator_Validation(t *testing.T) {
+	if _, err := NewJWTAuthenticator(JWTOptions{Audience: testAudience}); err == nil {
+		t.Error("Expected an error without a secret or key source")
+	}
+	if _, err := NewJWTAuthenticator(JWTOptions{HMACSecret: []byte(testSecret)}); err == nil {
+		t.Error("Expected an error without an audience")
+	}
+}
+
+func TestAuthMiddleware_RejectsWithBearerChallenge(t *testing.T) {
+	authenticator, err := NewJWTAuthenticator(JWTOptions{HMACSecret: []byte(testSecret), Audience: testAudience})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := AuthMiddleware(authenticator, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Handler should not be called")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("X-User-ID", testUserID) // Not trusted in JWT mode
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+	}
+	if rr.Header().Get("WWW-Authenticate") == "" {
+		t.Error("Expected a WWW-Authenticate header")
+	}
+}
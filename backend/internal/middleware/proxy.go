@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// ExternalUserStore maps an identity from the auth provider onto a user ID, creating the user if needed.
+type ExternalUserStore interface {
+	UpsertExternalUser(ctx context.Context, externalID string, username string, email *string) (string, error)
+}
+
+// ProxyOptions configures a ProxyAuthenticator.
+type ProxyOptions struct {
+	TrustedProxies []netip.Prefix // Only requests from these source addresses may carry identity headers
+	AllowedGroups  []string       // If not empty, the user must be in at least one of these groups
+}
+
+// ProxyAuthenticator trusts the identity headers set by a forward-auth reverse proxy like Authelia:
+// Remote-User, Remote-Email, and Remote-Groups.
+// Anyone can send these headers, so it only accepts them from the configured trusted proxy addresses.
+type ProxyAuthenticator struct {
+	options ProxyOptions
+	users   ExternalUserStore
+
+	// known caches the users we've already stored, so we only hit the DB when something changes.
+	// Keys are external IDs, values are knownUser.
+	known sync.Map
+}
+
+type knownUser struct {
+	id    string
+	name  string
+	email string
+}
+
+// NewProxyAuthenticator creates a forward-auth authenticator.
+func NewProxyAuthenticator(options ProxyOptions, users ExternalUserStore) (*ProxyAuthenticator, error) {
+	if len(options.TrustedProxies) == 0 {
+		return nil, errors.New("proxy authentication needs at least one trusted proxy address range")
+	}
+	return &ProxyAuthenticator{options: options, users: users}, nil
+}
+
+// Authenticate checks that the request came through a trusted proxy, then returns the stable user ID for Remote-User.
+func (a *ProxyAuthenticator) Authenticate(r *http.Request) (string, error) {
+	remoteUser := strings.TrimSpace(r.Header.Get("Remote-User"))
+
+	source, err := sourceAddr(r)
+	if err != nil {
+		return "", err
+	}
+	if !a.isTrusted(source) {
+		if remoteUser != "" {
+			return "", fmt.Errorf("identity headers from untrusted address %s", source)
+		}
+		return "", ErrNoCredentials
+	}
+	if remoteUser == "" {
+		return "", ErrNoCredentials
+	}
+
+	if len(a.options.AllowedGroups) > 0 && !a.inAllowedGroup(r.Header.Get("Remote-Groups")) {
+		return "", errors.New("user isn't in an allowed group")
+	}
+
+	externalID := "proxy|" + remoteUser
+	email := strings.TrimSpace(r.Header.Get("Remote-Email"))
+
+	if cached, ok := a.known.Load(externalID); ok {
+		if user := cached.(knownUser); user.name == remoteUser && user.email == email {
+			return user.id, nil
+		}
+	}
+
+	var emailPtr *string
+	if email != "" {
+		emailPtr = &email
+	}
+	userID, err := a.users.UpsertExternalUser(r.Context(), externalID, remoteUser, emailPtr)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve user: %w", err)
+	}
+	a.known.Store(externalID, knownUser{id: userID, name: remoteUser, email: email})
+
+	return userID, nil
+}
+
+func (a *ProxyAuthenticator) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range a.options.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// inAllowedGroup checks a comma-separated Remote-Groups value against the allowed groups.
+func (a *ProxyAuthenticator) inAllowedGroup(groups string) bool {
+	for _, group := range strings.Split(groups, ",") {
+		group = strings.TrimSpace(group)
+		for _, allowed := range a.options.AllowedGroups {
+			if group == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sourceAddr returns the address of the direct peer. It ignores X-Forwarded-For on purpose:
+// the point is to check who's actually connected to us.
+func sourceAddr(r *http.Request) (netip.Addr, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("can't parse remote address %q", r.RemoteAddr)
+	}
+	return addr.Unmap(), nil
+}
+
+// ParseTrustedProxies parses a list of CIDR ranges like "10.0.0.0/8". Bare IPs count as single-address ranges.
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a CIDR range or IP address", value)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
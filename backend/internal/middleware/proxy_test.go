@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+// mockExternalUserStore records upserts and derives IDs the same way the real repository does.
+type mockExternalUserStore struct {
+	calls int
+}
+
+func (m *mockExternalUserStore) UpsertExternalUser(_ context.Context, externalID string, _ string, _ *string) (string, error) {
+	m.calls++
+	return utils.NameUUID(externalID), nil
+}
+
+func TestProxyAuthenticator(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		headers        map[string]string
+		allowedGroups  []string
+		expectedUserID string
+		wantErr        bool
+	}{
+		{
+			name:           "trusted proxy",
+			remoteAddr:     "10.1.2.3:54321",
+			headers:        map[string]string{"Remote-User": "alice", "Remote-Email": "alice@example.com"},
+			expectedUserID: utils.NameUUID("proxy|alice"),
+		},
+		{
+			name:           "trusted IPv6 proxy",
+			remoteAddr:     "[::1]:54321",
+			headers:        map[string]string{"Remote-User": "alice"},
+			expectedUserID: utils.NameUUID("proxy|alice"),
+		},
+		{
+			name:       "untrusted source",
+			remoteAddr: "203.0.113.7:54321",
+			headers:    map[string]string{"Remote-User": "alice"},
+			wantErr:    true,
+		},
+		{
+			name:       "trusted proxy without user",
+			remoteAddr: "10.1.2.3:54321",
+			headers:    map[string]string{},
+			wantErr:    true,
+		},
+		{
+			name:       "X-Forwarded-For doesn't make a source trusted",
+			remoteAddr: "203.0.113.7:54321",
+			headers:    map[string]string{"Remote-User": "alice", "X-Forwarded-For": "10.1.2.3"},
+			wantErr:    true,
+		},
+		{
+			name:           "user in allowed group",
+			remoteAddr:     "10.1.2.3:54321",
+			headers:        map[string]string{"Remote-User": "bob", "Remote-Groups": "admins, readers"},
+			allowedGroups:  []string{"readers"},
+			expectedUserID: utils.NameUUID("proxy|bob"),
+		},
+		{
+			name:          "user not in allowed group",
+			remoteAddr:    "10.1.2.3:54321",
+			headers:       map[string]string{"Remote-User": "bob", "Remote-Groups": "admins"},
+			allowedGroups: []string{"readers"},
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator, err := NewProxyAuthenticator(ProxyOptions{
+				TrustedProxies: trusted,
+				AllowedGroups:  tt.allowedGroups,
+			}, &mockExternalUserStore{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+
+			userID, err := authenticator.Authenticate(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if userID != tt.expectedUserID {
+				t.Errorf("Authenticate() = %q, want %q", userID, tt.expectedUserID)
+			}
+		})
+	}
+}
+
+func TestProxyAuthenticator_CachesKnownUsers(t *testing.T) {
+	trusted, _ := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	store := &mockExternalUserStore{}
+	authenticator, err := NewProxyAuthenticator(ProxyOptions{TrustedProxies: trusted}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(email string) {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Remote-User", "alice")
+		req.Header.Set("Remote-Email", email)
+		if _, err := authenticator.Authenticate(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	request("alice@example.com")
+	request("alice@example.com")
+	if store.calls != 1 {
+		t.Errorf("Expected 1 upsert for repeated requests, got %d", store.calls)
+	}
+
+	request("alice@new.example.com")
+	if store.calls != 2 {
+		t.Errorf("Expected a new upsert after the email changed, got %d calls", store.calls)
+	}
+}
+
+func TestNewProxyAuthenticator_NeedsTrustedProxies(t *testing.T) {
+	if _, err := NewProxyAuthenticator(ProxyOptions{}, &mockExternalUserStore{}); err == nil {
+		t.Error("Expected an error without trusted proxies")
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// APIToken represents a personal API token. The token itself is never stored, only its hash.
+type APIToken struct {
+	ID          int64      `db:"id"`
+	UserID      string     `db:"user_id"`
+	Name        string     `db:"name"`
+	TokenPrefix string     `db:"token_prefix"` // First few characters, like "wa_Ab12Cd34", for telling tokens apart
+	TokenHash   string     `db:"token_hash"`   // SHA256 hex of the full token
+	Scopes      []string   `db:"scopes"`
+	CreatedAt   time.Time  `db:"created_at"`
+	LastUsedAt  *time.Time `db:"last_used_at"` // NULL if never used
+	RevokedAt   *time.Time `db:"revoked_at"`   // NULL while active
+}
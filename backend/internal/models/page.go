@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // Page represents a normalized page URL in the database.
 type Page struct {
 	ID            int64  `db:"id"`
@@ -7,3 +9,83 @@ type Page struct {
 	NormalizedURL string `db:"normalized_url"`
 	CreatedAt     string `db:"created_at"`
 }
+
+// PageCheck is everything the extension needs to show for a page: its stats, its metadata, and the user's own rating.
+type PageCheck struct {
+	PageID     int64     // 0 if the page doesn't exist yet
+	UpdatedAt  time.Time // When the page's stats, metadata, or article signals last changed
+	Stats      *PageStats
+	Metadata   *PageMetadata
+	Signals    *ArticleSignals
+	UserRating *UserRating
+}
+
+// ArticleSignals is what we know about whether a page is an article, from fetching it ourselves.
+type ArticleSignals struct {
+	OGType      *string  // og:type, lowercased. nil if the page has none or we haven't fetched it.
+	JSONLDTypes []string // Every JSON-LD @type on the page
+	Fetched     bool     // Whether we've read the page. If not, the other fields are empty for lack of knowing.
+	Abandoned   bool     // Whether the fetcher gave up on the page, so we won't learn more by fetching it again
+}
+
+// PageMetadata describes the article on a page. Every field is optional, nil means unknown.
+type PageMetadata struct {
+	Title       *string    // og:title, or the document title
+	SiteName    *string    // og:site_name
+	Author      *string    // article:author, or the JSON-LD author's name
+	PublishedAt *time.Time // article:published_time, or the JSON-LD datePublished, in UTC
+	Language    *string    // BCP 47, like "en" or "pt-BR"
+	Headline    *string    // JSON-LD headline
+}
+
+// IsEmpty reports whether no field is known.
+func (m *PageMetadata) IsEmpty() bool {
+	return m.Title == nil && m.SiteName == nil && m.Author == nil && m.PublishedAt == nil && m.Language == nil && m.Headline == nil
+}
+
+// PageStatsDrift is a page whose stored stats don't match its ratings.
+type PageStatsDrift struct {
+	PageID            int64  `db:"page_id"`
+	NormalizedURL     string `db:"normalized_url"`
+	StoredRatingCount int    `db:"stored_rating_count"`
+	ActualRatingCount int    `db:"actual_rating_count"`
+	StoredScoreSum    int64  `db:"stored_score_sum"`
+	ActualScoreSum    int64  `db:"actual_score_sum"`
+	StoredHistogram   []int  `db:"stored_histogram"`
+	ActualHistogram   []int  `db:"actual_histogram"`
+}
+
+// PageRehash is a page whose stored URL normalizes differently under the current rules.
+type PageRehash struct {
+	PageID     int64
+	OldURL     string
+	NewURL     string
+	MergedInto *int64 // The page that already had the new URL, if any. This page's ratings moved there.
+}
+
+// PageCanonical is a page that declares another URL as its canonical one.
+type PageCanonical struct {
+	PageID        int64  `db:"page_id"`
+	NormalizedURL string `db:"normalized_url"`
+	CanonicalURL  string `db:"canonical_url"`
+}
+
+// FetchedPage is what we read from a page when we fetched it ourselves.
+type FetchedPage struct {
+	Metadata     PageMetadata
+	OGType       *string  // og:type, lowercased, like "article" or "website". nil if the page has none.
+	JSONLDTypes  []string // Every JSON-LD @type on the page, like "NewsArticle" or "BreadcrumbList"
+	CanonicalURL *string  // The absolute <link rel="canonical"> URL. nil if the page has none.
+}
+
+// Signals returns what the fetched page says about whether it's an article.
+func (p *FetchedPage) Signals() *ArticleSignals {
+	return &ArticleSignals{OGType: p.OGType, JSONLDTypes: p.JSONLDTypes, Fetched: true}
+}
+
+// PageToFetch is a page the fetcher has claimed.
+type PageToFetch struct {
+	ID            int64  `db:"id"`
+	NormalizedURL string `db:"normalized_url"`
+	FetchAttempts int    `db:"fetch_attempts"` // Including the current one
+}
@@ -1,5 +1,13 @@
 package models
 
+import (
+	"math"
+	"time"
+)
+
+// MaxScore is the highest score the schema allows. Scores go from 1 to MaxScore.
+const MaxScore = 10
+
 // Rating represents a user's rating and comment for a page.
 type Rating struct {
 	ID        int64   `db:"id"`
@@ -13,13 +21,77 @@ type Rating struct {
 
 // PageStats contains aggregated statistics for a page.
 type PageStats struct {
-	TotalRatings int     `db:"total_ratings"`
-	AverageScore float64 `db:"avg_score"`
+	TotalRatings int           `db:"total_ratings"`
+	AverageScore float64       `db:"avg_score"`
+	Histogram    [MaxScore]int // Histogram[i] is the number of ratings with score i+1
+	Median       float64       // Average of the two middle scores for an even count, 0 if there are no ratings
+	StdDev       float64       // Population standard deviation, 0 if there are no ratings
+}
+
+// NewPageStats derives all page statistics from a score histogram.
+// Scores are small integers, so the histogram holds all the information we need,
+// and the database only has to return one count per score.
+func NewPageStats(histogram [MaxScore]int) *PageStats {
+	stats := &PageStats{Histogram: histogram}
+
+	sum := 0
+	for i, count := range histogram {
+		stats.TotalRatings += count
+		sum += (i + 1) * count
+	}
+	if stats.TotalRatings == 0 {
+		return stats
+	}
+	stats.AverageScore = float64(sum) / float64(stats.TotalRatings)
+
+	// The median is the average of the scores at these two (0-based) positions. They're the same for odd counts.
+	lower := scoreAt(histogram, (stats.TotalRatings-1)/2)
+	upper := scoreAt(histogram, stats.TotalRatings/2)
+	stats.Median = float64(lower+upper) / 2
+
+	variance := 0.0
+	for i, count := range histogram {
+		diff := float64(i+1) - stats.AverageScore
+		variance += diff * diff * float64(count)
+	}
+	stats.StdDev = math.Sqrt(variance / float64(stats.TotalRatings))
+
+	return stats
+}
+
+// scoreAt returns the score at the given position if all ratings were sorted by score.
+func scoreAt(histogram [MaxScore]int, position int) int {
+	for i, count := range histogram {
+		if position < count {
+			return i + 1
+		}
+		position -= count
+	}
+	return MaxScore
 }
 
 // UserRating contains the current user's rating for a page, if any.
 type UserRating struct {
-	HasRated bool    `db:"has_rated"`
-	Score    *int    `db:"score"`   // Nullable
-	Comment  *string `db:"comment"` // Nullable
+	HasRated  bool       `db:"has_rated"`
+	Score     *int       `db:"score"`      // Nullable
+	Comment   *string    `db:"comment"`    // Nullable
+	UpdatedAt *time.Time `db:"updated_at"` // When the rating last changed. Nil if the user hasn't rated the page.
 }
+
+// RatingRevision is one entry in the history of a user's rating for a page.
+type RatingRevision struct {
+	ID        int64     `db:"id"`
+	UserID    string    `db:"user_id"`
+	PageID    int64     `db:"page_id"`
+	Action    string    `db:"action"`  // "created", "updated", or "deleted"
+	Score     *int      `db:"score"`   // NULL for "deleted"
+	Comment   *string   `db:"comment"` // Nullable
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Rating revision actions.
+const (
+	RevisionCreated = "created"
+	RevisionUpdated = "updated"
+	RevisionDeleted = "deleted"
+)
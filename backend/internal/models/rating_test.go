@@ -0,0 +1,87 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewPageStats(t *testing.T) {
+	tests := []struct {
+		name      string
+		histogram [MaxScore]int
+		total     int
+		average   float64
+		median    float64
+		stdDev    float64
+	}{
+		{
+			name: "no ratings",
+		},
+		{
+			name:      "single rating",
+			histogram: [MaxScore]int{6: 1},
+			total:     1,
+			average:   7,
+			median:    7,
+			stdDev:    0,
+		},
+		{
+			name:      "all fives",
+			histogram: [MaxScore]int{4: 4},
+			total:     4,
+			average:   5,
+			median:    5,
+			stdDev:    0,
+		},
+		{
+			name:      "polarized",
+			histogram: [MaxScore]int{0: 2, 9: 2},
+			total:     4,
+			average:   5.5,
+			median:    5.5,
+			stdDev:    4.5,
+		},
+		{
+			name:      "odd count",
+			histogram: [MaxScore]int{1: 1, 2: 1, 8: 1},
+			total:     3,
+			average:   14.0 / 3,
+			median:    3,
+			stdDev:    math.Sqrt((math.Pow(2-14.0/3, 2) + math.Pow(3-14.0/3, 2) + math.Pow(9-14.0/3, 2)) / 3),
+		},
+		{
+			name:      "skewed even count",
+			histogram: [MaxScore]int{7: 3, 8: 1},
+			total:     4,
+			average:   8.25,
+			median:    8,
+			stdDev:    math.Sqrt(0.1875),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := NewPageStats(tt.histogram)
+
+			if stats.TotalRatings != tt.total {
+				t.Errorf("TotalRatings = %d, want %d", stats.TotalRatings, tt.total)
+			}
+			if !almostEqual(stats.AverageScore, tt.average) {
+				t.Errorf("AverageScore = %v, want %v", stats.AverageScore, tt.average)
+			}
+			if !almostEqual(stats.Median, tt.median) {
+				t.Errorf("Median = %v, want %v", stats.Median, tt.median)
+			}
+			if !almostEqual(stats.StdDev, tt.stdDev) {
+				t.Errorf("StdDev = %v, want %v", stats.StdDev, tt.stdDev)
+			}
+			if stats.Histogram != tt.histogram {
+				t.Errorf("Histogram = %v, want %v", stats.Histogram, tt.histogram)
+			}
+		})
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// ScoreTotals is the sum and count of all ratings on one domain's pages.
+// The adjusted score's priors are computed from these.
+type ScoreTotals struct {
+	Domain      string `db:"domain"`
+	ScoreSum    int64  `db:"score_sum"`
+	RatingCount int64  `db:"rating_count"`
+}
+
+// ScorePriors holds the scores a page is assumed to have before it gets enough ratings of its own.
+type ScorePriors struct {
+	Weight    int                // How many imaginary ratings at the prior score each page starts with
+	Global    float64            // Average score over all ratings
+	Domains   map[string]float64 // Per-domain priors. Empty unless per-domain priors are on.
+	UpdatedAt time.Time          // When the priors last changed, since every adjusted score changes with them
+}
+
+// For returns the prior for a page on the given domain, falling back to the global prior.
+func (p ScorePriors) For(domain string) float64 {
+	if prior, ok := p.Domains[domain]; ok {
+		return prior
+	}
+	return p.Global
+}
+
+// AdjustedScore returns the Bayesian average of a page on the given domain.
+// Pages with few ratings stay close to the prior, and move towards their own average as they get more.
+// It returns 0 for pages with no ratings, like the plain average does.
+func (p ScorePriors) AdjustedScore(domain string, stats *PageStats) float64 {
+	if stats.TotalRatings == 0 {
+		return 0
+	}
+	weight := float64(p.Weight)
+	count := float64(stats.TotalRatings)
+	return (weight*p.For(domain) + stats.AverageScore*count) / (weight + count)
+}
+
+// TopPage is one entry in the list of best-rated pages.
+type TopPage struct {
+	NormalizedURL string  `db:"normalized_url"`
+	TotalRatings  int     `db:"total_ratings"`
+	AverageScore  float64 `db:"avg_score"`
+	AdjustedScore float64 `db:"adjusted_score"`
+}
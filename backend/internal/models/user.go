@@ -0,0 +1,9 @@
+package models
+
+// User represents a user account.
+type User struct {
+	ID         string  `db:"id"`          // UUID
+	Username   string  `db:"username"`    // Display name from the auth provider, or "Test User" in the X-User-ID dev mode
+	ExternalID *string `db:"external_id"` // Identity at the auth provider, like "proxy|alice". NULL for dev mode and JWT users.
+	Email      *string `db:"email"`       // NULL if the auth provider didn't send one
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook event types. A rating's revision action maps to "rating." + action.
+const (
+	WebhookEventRatingCreated = "rating." + RevisionCreated
+	WebhookEventRatingUpdated = "rating." + RevisionUpdated
+	WebhookEventRatingDeleted = "rating." + RevisionDeleted
+)
+
+// WebhookEventTypes lists every event a subscription can ask for.
+var WebhookEventTypes = []string{WebhookEventRatingCreated, WebhookEventRatingUpdated, WebhookEventRatingDeleted}
+
+// Webhook delivery statuses.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookSubscription is an endpoint that gets its owner's rating events.
+type WebhookSubscription struct {
+	ID        int64     `db:"id"`
+	UserID    string    `db:"user_id"`
+	URL       string    `db:"url"`
+	Secret    string    `db:"secret"`
+	Events    []string  `db:"events"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// WebhookDelivery is one event on its way to one subscription, from the outbox.
+type WebhookDelivery struct {
+	ID             int64           `db:"id"`
+	SubscriptionID int64           `db:"subscription_id"`
+	URL            string          `db:"url"` // The subscription's URL
+	EventType      string          `db:"event_type"`
+	Payload        json.RawMessage `db:"payload"`
+	Status         string          `db:"status"`
+	Attempts       int             `db:"attempts"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at"`
+	LastError      *string         `db:"last_error"`   // NULL if no attempt failed
+	CreatedAt      time.Time       `db:"created_at"`   // When the event happened
+	DeliveredAt    *time.Time      `db:"delivered_at"` // NULL unless delivered
+}
+
+// PendingWebhookDelivery is a delivery the worker has claimed, with what it needs to sign it.
+type PendingWebhookDelivery struct {
+	WebhookDelivery
+	Secret string `db:"secret"`
+}
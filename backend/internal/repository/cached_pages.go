@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/vdavid/web-annotator/backend/internal/cache"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+// PageCheckCache caches the part of page checks that's the same for every user, by URL hash.
+// Entries have no user rating. Pages that don't exist yet are cached too, with a zero page ID and nothing else.
+type PageCheckCache = cache.LRU[string, models.PageCheck]
+
+// CachedPagesRepository serves page checks and stats from an in-process cache and everything else from the wrapped
+// repository. A cached check still needs the user's own rating, which is a lookup by primary key.
+// Rating changes don't go through here, so whoever makes them must update the cache, see RatingsHandler.
+// Metadata and article signals only show up once the cached check expires.
+type CachedPagesRepository struct {
+	PagesRepositoryInterface
+	cache *PageCheckCache
+}
+
+// NewCachedPagesRepository wraps pages with the given cache.
+func NewCachedPagesRepository(pages PagesRepositoryInterface, cache *PageCheckCache) *CachedPagesRepository {
+	return &CachedPagesRepository{PagesRepositoryInterface: pages, cache: cache}
+}
+
+// GetOrCreatePage gets or creates the page, and drops what's cached for its URL, which may say it doesn't exist.
+func (r *CachedPagesRepository) GetOrCreatePage(ctx context.Context, normalizedURL string) (int64, error) {
+	pageID, err := r.PagesRepositoryInterface.GetOrCreatePage(ctx, normalizedURL)
+	if err != nil {
+		return 0, err
+	}
+	r.cache.Delete(utils.HashURL(normalizedURL))
+	return pageID, nil
+}
+
+// AliasLegacyPages aliases the pages like the wrapped repository does, and drops what's cached for the URLs it aliased.
+func (r *CachedPagesRepository) AliasLegacyPages(ctx context.Context, normalizedURLs []string) ([]string, error) {
+	aliased, err := r.PagesRepositoryInterface.AliasLegacyPages(ctx, normalizedURLs)
+	if err != nil {
+		return nil, err
+	}
+	for _, urlHash := range aliased {
+		r.cache.Delete(urlHash)
+	}
+	return aliased, nil
+}
+
+// GetPageStats returns the page's stats from the cache, or loads them on a miss.
+// It doesn't cache what it loads: a cached check needs more than the stats.
+func (r *CachedPagesRepository) GetPageStats(ctx context.Context, urlHash string) (*models.PageStats, error) {
+	if check, ok := r.cache.Get(urlHash); ok {
+		if check.PageID == 0 {
+			return pageStatsFromHistogram(nil), nil
+		}
+		return check.Stats, nil
+	}
+
+	return r.PagesRepositoryInterface.GetPageStats(ctx, urlHash)
+}
+
+// GetPageCheck is GetPageChecks for a single page. If the page doesn't exist yet, it returns zero stats and no rating.
+func (r *CachedPagesRepository) GetPageCheck(ctx context.Context, urlHash string, userID string) (*models.PageCheck, error) {
+	checks, err := r.GetPageChecks(ctx, []string{urlHash}, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if check, ok := checks[urlHash]; ok {
+		return check, nil
+	}
+	return emptyPageCheck(), nil
+}
+
+// GetPageChecks takes the pages it can from the cache and loads only their user's ratings.
+// It loads the rest from the wrapped repository in one query, and caches them.
+func (r *CachedPagesRepository) GetPageChecks(ctx context.Context, urlHashes []string, userID string) (map[string]*models.PageCheck, error) {
+	checks := make(map[string]*models.PageCheck, len(urlHashes))
+	var missing []string
+	var pageIDs []int64
+	for _, urlHash := range urlHashes {
+		cached, ok := r.cache.Get(urlHash)
+		if !ok {
+			missing = append(missing, urlHash)
+			continue
+		}
+		if cached.PageID == 0 {
+			// The page doesn't exist yet
+			continue
+		}
+		checks[urlHash] = &cached
+		pageIDs = append(pageIDs, cached.PageID)
+	}
+
+	if len(pageIDs) > 0 {
+		userRatings, err := r.PagesRepositoryInterface.GetUserRatings(ctx, pageIDs, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, check := range checks {
+			check.UserRating = userRatings[check.PageID]
+			if check.UserRating == nil {
+				check.UserRating = &models.UserRating{}
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		loaded, err := r.PagesRepositoryInterface.GetPageChecks(ctx, missing, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, urlHash := range missing {
+			check, ok := loaded[urlHash]
+			if !ok {
+				r.cache.Set(urlHash, models.PageCheck{})
+				continue
+			}
+			page := *check
+			page.UserRating = nil
+			r.cache.Set(urlHash, page)
+			checks[urlHash] = check
+		}
+	}
+
+	return checks, nil
+}
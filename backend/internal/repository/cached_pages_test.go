@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/cache"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+// countingPagesRepository counts the queries that reach the database.
+// Every page exists except "missing", and only "rater" has rated any.
+type countingPagesRepository struct {
+	PagesRepositoryInterface
+	stats        *models.PageStats
+	loaded       []string // URL hashes whose checks were loaded
+	ratingsCalls int
+	statsCalls   int
+}
+
+func (r *countingPagesRepository) GetPageStats(context.Context, string) (*models.PageStats, error) {
+	r.statsCalls++
+	return r.stats, nil
+}
+
+func (r *countingPagesRepository) GetUserRatings(_ context.Context, pageIDs []int64, userID string) (map[int64]*models.UserRating, error) {
+	r.ratingsCalls++
+	userRatings := map[int64]*models.UserRating{}
+	if userID == "rater" {
+		for _, pageID := range pageIDs {
+			userRatings[pageID] = &models.UserRating{HasRated: true, Score: new(int)}
+		}
+	}
+	return userRatings, nil
+}
+
+func (r *countingPagesRepository) GetPageChecks(_ context.Context, urlHashes []string, userID string) (map[string]*models.PageCheck, error) {
+	r.loaded = append(r.loaded, urlHashes...)
+	checks := make(map[string]*models.PageCheck, len(urlHashes))
+	for i, urlHash := range urlHashes {
+		if urlHash == "missing" {
+			continue
+		}
+		checks[urlHash] = &models.PageCheck{PageID: int64(i + 1), Stats: r.stats, UserRating: &models.UserRating{HasRated: userID == "rater"}}
+	}
+	return checks, nil
+}
+
+func TestCachedPagesRepository(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingPagesRepository{stats: models.NewPageStats([models.MaxScore]int{6: 2})}
+	checkCache := cache.NewLRU[string, models.PageCheck](10, time.Minute)
+	repo := NewCachedPagesRepository(inner, checkCache)
+
+	// The first check loads the page and caches it
+	if _, err := repo.GetPageCheck(ctx, "hash", "rater"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Later ones only load their user's rating
+	for _, userID := range []string{"rater", "someone"} {
+		check, err := repo.GetPageCheck(ctx, "hash", userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *check.Stats != *inner.stats || check.UserRating.HasRated != (userID == "rater") {
+			t.Errorf("%s: expected the cached stats and their own rating, got %+v and %+v", userID, check.Stats, check.UserRating)
+		}
+	}
+	if !slices.Equal(inner.loaded, []string{"hash"}) || inner.ratingsCalls != 2 {
+		t.Errorf("Expected the page to be loaded once and ratings twice, got %v and %d", inner.loaded, inner.ratingsCalls)
+	}
+
+	// Batches only load what isn't cached, and remember pages that don't exist
+	for range 2 {
+		checks, err := repo.GetPageChecks(ctx, []string{"hash", "other", "missing"}, "someone")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := checks["missing"]; ok || len(checks) != 2 {
+			t.Errorf("Expected checks for the existing pages only, got %v", checks)
+		}
+	}
+	if !slices.Equal(inner.loaded, []string{"hash", "other", "missing"}) {
+		t.Errorf("Expected only the uncached pages to be loaded, got %v", inner.loaded)
+	}
+	if check, err := repo.GetPageCheck(ctx, "missing", "someone"); err != nil || check.Stats.TotalRatings != 0 || check.UserRating.HasRated {
+		t.Errorf("Expected an empty check for a page that doesn't exist, got %+v (error: %v)", check, err)
+	}
+
+	// Streams share the cached stats
+	stats, err := repo.GetPageStats(ctx, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stats != *inner.stats || inner.statsCalls != 0 {
+		t.Errorf("Expected the cached stats without a load, got %+v after %d loads", stats, inner.statsCalls)
+	}
+	if _, err := repo.GetPageStats(ctx, "uncached"); err != nil {
+		t.Fatal(err)
+	}
+	if inner.statsCalls != 1 {
+		t.Errorf("Expected the uncached page's stats to be loaded, got %d loads", inner.statsCalls)
+	}
+
+	if stats := checkCache.Stats(); stats.Hits != 8 || stats.Misses != 4 {
+		t.Errorf("Expected 8 hits and 4 misses, got %+v", stats)
+	}
+}
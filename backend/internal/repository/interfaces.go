@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/vdavid/web-annotator/backend/internal/models"
 )
@@ -9,13 +10,24 @@ import (
 // PagesRepositoryInterface defines the interface for page repository operations.
 type PagesRepositoryInterface interface {
 	GetOrCreatePage(ctx context.Context, normalizedURL string) (int64, error)
+	AliasLegacyPages(ctx context.Context, normalizedURLs []string) ([]string, error)
 	GetPageStats(ctx context.Context, urlHash string) (*models.PageStats, error)
+	GetPageURLHashes(ctx context.Context, pageID int64) ([]string, error)
 	GetUserRating(ctx context.Context, urlHash string, userID string) (*models.UserRating, error)
+	GetUserRatings(ctx context.Context, pageIDs []int64, userID string) (map[int64]*models.UserRating, error)
+	GetPageCheck(ctx context.Context, urlHash string, userID string) (*models.PageCheck, error)
+	GetPageChecks(ctx context.Context, urlHashes []string, userID string) (map[string]*models.PageCheck, error)
+	SavePageMetadata(ctx context.Context, pageID int64, metadata *models.PageMetadata, refreshAfter time.Duration) (*models.PageMetadata, error)
+	GetArticleSignals(ctx context.Context, urlHash string) (*models.ArticleSignals, error)
+	SaveFetchedPage(ctx context.Context, pageID int64, page *models.FetchedPage) error
+	ListTopPages(ctx context.Context, priors models.ScorePriors, limit int) ([]models.TopPage, error)
 }
 
 // RatingsRepositoryInterface defines the interface for ratings repository operations.
 type RatingsRepositoryInterface interface {
 	UpsertRating(ctx context.Context, pageID int64, userID string, score int, comment *string) error
+	DeleteRating(ctx context.Context, urlHash string, userID string) (int64, error)
+	GetRatingHistory(ctx context.Context, urlHash string, userID string) ([]models.RatingRevision, error)
 	GetPageStatsAfterRating(ctx context.Context, pageID int64) (*models.PageStats, error)
 }
 
@@ -23,3 +35,19 @@ type RatingsRepositoryInterface interface {
 type UsersRepositoryInterface interface {
 	GetOrCreateUser(ctx context.Context, userID string) error
 }
+
+// TokensRepositoryInterface defines the interface for personal API token operations.
+type TokensRepositoryInterface interface {
+	CreateToken(ctx context.Context, userID string, name string, tokenPrefix string, tokenHash string, scopes []string) (*models.APIToken, error)
+	ListTokens(ctx context.Context, userID string) ([]models.APIToken, error)
+	RevokeToken(ctx context.Context, userID string, tokenID int64) error
+	UseToken(ctx context.Context, tokenHash string) (*models.APIToken, error)
+}
+
+// WebhooksRepositoryInterface defines the interface for managing webhook subscriptions.
+type WebhooksRepositoryInterface interface {
+	CreateSubscription(ctx context.Context, userID string, url string, secret string, events []string) (*models.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context, userID string) ([]models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, userID string, subscriptionID int64) error
+	ListDeadDeliveries(ctx context.Context, userID string, limit int) ([]models.WebhookDelivery, error)
+}
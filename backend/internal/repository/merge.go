@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+// ErrPageNotFound is returned when a page to merge doesn't exist.
+var ErrPageNotFound = errors.New("page not found")
+
+// MergePages merges the page with the normalized URL fromURL into the one with intoURL, see mergePages.
+// Both URLs may be aliases. The page for intoURL is created if it doesn't exist yet, since it's usually the canonical
+// URL of a page nobody visited directly. It returns the merged pages' IDs, or ErrPageNotFound if fromURL has no page.
+// If both URLs already lead to the same page, there's nothing to merge, and both IDs are that page's.
+func (r *PagesRepository) MergePages(ctx context.Context, fromURL string, intoURL string) (int64, int64, error) {
+	intoID, err := r.GetOrCreatePage(ctx, intoURL)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer rollback(ctx, tx)
+
+	var fromID *int64
+	if err := tx.QueryRow(ctx, `SELECT `+pageIDByHashSQL, utils.HashURL(fromURL)).Scan(&fromID); err != nil {
+		return 0, 0, fmt.Errorf("failed to look up page: %w", err)
+	}
+	if fromID == nil {
+		return 0, 0, ErrPageNotFound
+	}
+	if *fromID == intoID {
+		return intoID, intoID, nil
+	}
+
+	if err := mergePages(ctx, tx, *fromID, intoID); err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return *fromID, intoID, nil
+}
+
+// ListCanonicalURLs returns every fetched page whose <link rel=canonical> isn't its own URL, by page ID.
+// The canonical URLs are as the pages declared them, not normalized.
+func (r *PagesRepository) ListCanonicalURLs(ctx context.Context) ([]models.PageCanonical, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id AS page_id, normalized_url, canonical_url
+		FROM pages
+		WHERE canonical_url IS NOT NULL AND canonical_url <> normalized_url
+		ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list canonical URLs: %w", err)
+	}
+
+	canonicals, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.PageCanonical])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list canonical URLs: %w", err)
+	}
+
+	return canonicals, nil
+}
+
+// mergePages moves everything about page fromID to page intoID, then deletes fromID and keeps its URL as an alias.
+// If a user rated both, the newer rating wins and the older one goes, with a revision and a webhook event like any
+// deleted rating. The rating history of both stays.
+// Metadata intoID doesn't have is taken from fromID, and intoID's stats are recomputed.
+func mergePages(ctx context.Context, tx pgx.Tx, fromID int64, intoID int64) error {
+	// Lock in ID order, like any two concurrent merges would, so they can't deadlock
+	for _, id := range []int64{min(fromID, intoID), max(fromID, intoID)} {
+		if err := lockPage(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+
+	// On a tie, the rating already on intoID wins
+	rows, err := tx.Query(ctx,
+		`DELETE FROM ratings r
+		USING ratings other
+		WHERE r.page_id IN ($1, $2) AND other.page_id IN ($1, $2) AND r.page_id <> other.page_id
+			AND r.user_id = other.user_id
+			AND (COALESCE(r.updated_at, r.created_at, '-infinity'), r.page_id = $2)
+				< (COALESCE(other.updated_at, other.created_at, '-infinity'), other.page_id = $2)
+		RETURNING r.user_id::text, r.page_id`,
+		fromID, intoID)
+	if err != nil {
+		return fmt.Errorf("failed to drop superseded ratings: %w", err)
+	}
+	superseded, err := pgx.CollectRows(rows, pgx.RowToStructByPos[supersededRating])
+	if err != nil {
+		return fmt.Errorf("failed to drop superseded ratings: %w", err)
+	}
+	// Recorded on the page they were on, before its history moves and its URL goes into the events
+	for _, rating := range superseded {
+		if err := insertRevision(ctx, tx, rating.UserID, rating.PageID, models.RevisionDeleted, nil, nil); err != nil {
+			return err
+		}
+		if err := enqueueWebhookEvents(ctx, tx, rating.UserID, rating.PageID, models.RevisionDeleted, nil, nil); err != nil {
+			return err
+		}
+	}
+
+	statements := []struct {
+		sql    string
+		action string
+	}{
+		{`UPDATE ratings SET page_id = $2 WHERE page_id = $1`, "move ratings"},
+		{`UPDATE rating_revisions SET page_id = $2 WHERE page_id = $1`, "move rating history"},
+		{`UPDATE pages p SET
+			title = COALESCE(p.title, f.title),
+			site_name = COALESCE(p.site_name, f.site_name),
+			author = COALESCE(p.author, f.author),
+			published_at = COALESCE(p.published_at, f.published_at),
+			language = COALESCE(p.language, f.language),
+			headline = COALESCE(p.headline, f.headline),
+			metadata_updated_at = COALESCE(p.metadata_updated_at, f.metadata_updated_at),
+			created_at = LEAST(p.created_at, f.created_at)
+		FROM pages f
+		WHERE p.id = $2 AND f.id = $1`, "merge metadata"},
+		{`UPDATE page_aliases SET page_id = $2 WHERE page_id = $1`, "move aliases"},
+		{`INSERT INTO page_aliases (url_hash, normalized_url, page_id)
+		SELECT url_hash, normalized_url, $2 FROM pages WHERE id = $1`, "keep the merged URL as an alias"},
+		{`DELETE FROM pages WHERE id = $1`, "delete merged page"},
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(ctx, statement.sql, fromID, intoID); err != nil {
+			return fmt.Errorf("failed to %s: %w", statement.action, err)
+		}
+	}
+
+	return refreshPageStats(ctx, tx, intoID)
+}
+
+// supersededRating is a rating mergePages dropped for a newer one by the same user.
+type supersededRating struct {
+	UserID string
+	PageID int64
+}
+
+// refreshPageStats recomputes one page's stored stats from its ratings. The caller must hold the page's lock.
+func refreshPageStats(ctx context.Context, tx pgx.Tx, pageID int64) error {
+	_, err := tx.Exec(ctx,
+		`UPDATE pages p SET
+			rating_count = a.rating_count,
+			score_sum = a.score_sum,
+			score_histogram = a.score_histogram,
+			updated_at = NOW()
+		FROM (`+actualPageStatsSQL+`) a
+		WHERE a.page_id = p.id AND p.id = $1`,
+		pageID)
+	if err != nil {
+		return fmt.Errorf("failed to recompute page stats: %w", err)
+	}
+	return nil
+}
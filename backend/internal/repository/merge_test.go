@@ -0,0 +1,177 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/testutil"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+func TestPagesRepository_MergePages(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	seedRatedPages(t, pool, 1, 5)
+	pagesRepo := repository.NewPagesRepository(pool)
+	ratingsRepo := repository.NewRatingsRepository(pool)
+	ctx := context.Background()
+
+	// Users 150 and 151 haven't rated anything yet
+	var userA, userB string
+	if err := pool.QueryRow(ctx, `SELECT md5('user150')::uuid::text, md5('user151')::uuid::text`).Scan(&userA, &userB); err != nil {
+		t.Fatal(err)
+	}
+	ampURL, mobileURL, articleURL := "https://amp.example.com/article", "https://m.example.com/article", "https://example.com/article"
+	ampID, err := pagesRepo.GetOrCreatePage(ctx, ampURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mobileID, err := pagesRepo.GetOrCreatePage(ctx, mobileURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.NewWebhooksRepository(pool).CreateSubscription(ctx, userA, "https://example.com/hook", "0123456789abcdef", []string{"rating.deleted"}); err != nil {
+		t.Fatal(err)
+	}
+	// User A rated the mobile version last, so that rating wins
+	for _, rating := range []struct {
+		pageID int64
+		userID string
+		score  int
+	}{{ampID, userA, 2}, {ampID, userB, 6}, {mobileID, userA, 9}} {
+		if err := ratingsRepo.UpsertRating(ctx, rating.pageID, rating.userID, rating.score, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The canonical page doesn't exist yet, so merging creates it
+	fromID, intoID, err := pagesRepo.MergePages(ctx, ampURL, articleURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fromID != ampID || intoID == ampID {
+		t.Errorf("MergePages() = %d, %d, want %d and a new page", fromID, intoID, ampID)
+	}
+	if _, _, err := pagesRepo.MergePages(ctx, mobileURL, articleURL); err != nil {
+		t.Fatal(err)
+	}
+
+	// Merging again finds the same page, and unknown pages aren't there to merge
+	if fromID, _, err := pagesRepo.MergePages(ctx, ampURL, articleURL); err != nil || fromID != intoID {
+		t.Errorf("Expected merging an alias into its page to do nothing, got %d, %v", fromID, err)
+	}
+	if _, _, err := pagesRepo.MergePages(ctx, "https://example.com/nope", articleURL); !errors.Is(err, repository.ErrPageNotFound) {
+		t.Errorf("MergePages() error = %v, want ErrPageNotFound", err)
+	}
+
+	// Every URL leads to the merged page
+	for _, pageURL := range []string{articleURL, ampURL, mobileURL} {
+		t.Run(pageURL, func(t *testing.T) {
+			urlHash := utils.HashURL(pageURL)
+			pageID, err := pagesRepo.GetOrCreatePage(ctx, pageURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pageID != intoID {
+				t.Errorf("GetOrCreatePage() = %d, want %d", pageID, intoID)
+			}
+			stats, err := pagesRepo.GetPageStats(ctx, urlHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.TotalRatings != 2 || stats.AverageScore != 7.5 {
+				t.Errorf("Expected user A's newest and user B's rating, got %+v", stats)
+			}
+			rating, err := pagesRepo.GetUserRating(ctx, urlHash, userA)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rating.Score == nil || *rating.Score != 9 {
+				t.Errorf("Expected user A's newest rating, got %+v", rating)
+			}
+			checks, err := pagesRepo.GetPageChecks(ctx, []string{urlHash}, userB)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if check := checks[urlHash]; check == nil || check.UserRating.Score == nil || *check.UserRating.Score != 6 {
+				t.Errorf("Expected the check to find user B's rating, got %+v", checks)
+			}
+			history, err := ratingsRepo.GetRatingHistory(ctx, urlHash, userA)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(history) != 3 || history[0].Action != models.RevisionDeleted {
+				t.Errorf("Expected user A's history from both pages and the superseded rating's deletion, got %+v", history)
+			}
+		})
+	}
+
+	urlHashes, err := pagesRepo.GetPageURLHashes(ctx, intoID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(urlHashes)
+	wantHashes := []string{utils.HashURL(articleURL), utils.HashURL(ampURL), utils.HashURL(mobileURL)}
+	slices.Sort(wantHashes)
+	if !slices.Equal(urlHashes, wantHashes) {
+		t.Errorf("GetPageURLHashes() = %v, want the page's own and both aliases' %v", urlHashes, wantHashes)
+	}
+
+	// User A's webhook hears about the rating the merge dropped
+	var deletedURL string
+	if err := pool.QueryRow(ctx, `SELECT payload->>'url' FROM webhook_deliveries WHERE event_type = 'rating.deleted'`).Scan(&deletedURL); err != nil {
+		t.Fatal(err)
+	}
+	if deletedURL != ampURL {
+		t.Errorf("Expected a rating.deleted event for %s, got %s", ampURL, deletedURL)
+	}
+
+	drift, err := pagesRepo.RecomputePageStats(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drift) > 0 {
+		t.Errorf("Expected no stats drift after merging, got %+v", drift)
+	}
+
+	// Deleting through an alias works too
+	if _, err := ratingsRepo.DeleteRating(ctx, utils.HashURL(ampURL), userB); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ := pagesRepo.GetPageStats(ctx, utils.HashURL(articleURL)); stats.TotalRatings != 1 {
+		t.Errorf("Expected one rating left, got %+v", stats)
+	}
+}
+
+func TestPagesRepository_ListCanonicalURLs(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	pagesRepo := repository.NewPagesRepository(pool)
+	ctx := context.Background()
+
+	canonical := "https://example.com/article"
+	pages := map[string]*string{
+		"https://amp.example.com/article": &canonical,
+		"https://example.com/article":     &canonical,
+		"https://example.com/other":       nil,
+	}
+	for pageURL, canonicalURL := range pages {
+		pageID, err := pagesRepo.GetOrCreatePage(ctx, pageURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pagesRepo.SaveFetchedPage(ctx, pageID, &models.FetchedPage{CanonicalURL: canonicalURL}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	canonicals, err := pagesRepo.ListCanonicalURLs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(canonicals) != 1 || canonicals[0].NormalizedURL != "https://amp.example.com/article" || canonicals[0].CanonicalURL != canonical {
+		t.Errorf("ListCanonicalURLs() = %+v, want only the AMP page", canonicals)
+	}
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+// ClaimPagesToFetch picks up to limit pages that are due for fetching, oldest first, and counts an attempt for each.
+// It also moves their next fetch lease into the future, so other workers skip them while this one fetches them.
+// If the worker dies mid-fetch, they become due again after the lease.
+func (r *PagesRepository) ClaimPagesToFetch(ctx context.Context, limit int, lease time.Duration) ([]models.PageToFetch, error) {
+	rows, err := r.pool.Query(ctx,
+		`WITH due AS (
+			SELECT id FROM pages
+			WHERE fetch_status = 'pending' AND next_fetch_at <= NOW()
+			ORDER BY next_fetch_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE pages p SET
+				fetch_attempts = p.fetch_attempts + 1,
+				next_fetch_at = NOW() + $2::interval
+			FROM due
+			WHERE p.id = due.id
+			RETURNING p.id, p.normalized_url, p.fetch_attempts
+		)
+		SELECT * FROM claimed ORDER BY id`,
+		limit, lease)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim pages to fetch: %w", err)
+	}
+
+	pages, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.PageToFetch])
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim pages to fetch: %w", err)
+	}
+
+	return pages, nil
+}
+
+// SaveFetchedPage stores what the fetcher read from a page and marks it fetched.
+// Fetched metadata is what the page itself says, so unlike SavePageMetadata, it replaces what clients sent.
+// Fields the page doesn't have keep their stored values.
+func (r *PagesRepository) SaveFetchedPage(ctx context.Context, pageID int64, page *models.FetchedPage) error {
+	metadata := &page.Metadata
+	var publishedAt *time.Time
+	if metadata.PublishedAt != nil {
+		utc := metadata.PublishedAt.UTC()
+		publishedAt = &utc
+	}
+
+	_, err := r.pool.Exec(ctx,
+		`UPDATE pages SET
+			title = COALESCE($2, title),
+			site_name = COALESCE($3, site_name),
+			author = COALESCE($4, author),
+			published_at = COALESCE($5, published_at),
+			language = COALESCE($6, language),
+			headline = COALESCE($7, headline),
+			metadata_updated_at = CASE WHEN num_nonnulls($2, $3, $4, $5, $6, $7) > 0 THEN NOW() ELSE metadata_updated_at END,
+			og_type = $8,
+			jsonld_types = $9,
+			canonical_url = $10,
+			fetch_status = 'fetched',
+			fetch_error = NULL,
+			fetched_at = NOW(),
+			updated_at = NOW()
+		WHERE id = $1`,
+		pageID, metadata.Title, metadata.SiteName, metadata.Author, publishedAt, metadata.Language, metadata.Headline,
+		page.OGType, emptyIfNil(page.JSONLDTypes), page.CanonicalURL)
+	if err != nil {
+		return fmt.Errorf("failed to save fetched page: %w", err)
+	}
+	return nil
+}
+
+// MarkFetchFailed records a failed fetch and schedules the next one after retryIn.
+func (r *PagesRepository) MarkFetchFailed(ctx context.Context, pageID int64, reason string, retryIn time.Duration) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE pages SET next_fetch_at = NOW() + $3::interval, fetch_error = $2
+		WHERE id = $1`,
+		pageID, reason, retryIn)
+	if err != nil {
+		return fmt.Errorf("failed to mark page fetch failed: %w", err)
+	}
+	return nil
+}
+
+// MarkFetchAbandoned records a failed last fetch. The page keeps whatever metadata clients sent.
+func (r *PagesRepository) MarkFetchAbandoned(ctx context.Context, pageID int64, reason string) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE pages SET fetch_status = 'failed', fetch_error = $2
+		WHERE id = $1`,
+		pageID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to mark page fetch abandoned: %w", err)
+	}
+	return nil
+}
+
+// emptyIfNil turns a nil slice into an empty one, so it's stored as {} rather than NULL.
+func emptyIfNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,124 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/testutil"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+func TestPagesRepository_FetchQueue(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	repo := repository.NewPagesRepository(pool)
+	ctx := context.Background()
+	text := func(s string) *string { return &s }
+
+	fetchedID, err := repo.GetOrCreatePage(ctx, "https://example.com/fetched")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failingID, err := repo.GetOrCreatePage(ctx, "https://example.com/failing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A client already sent a title and an author
+	if _, err := repo.SavePageMetadata(ctx, fetchedID, &models.PageMetadata{Title: text("Client title"), Author: text("Jane Doe")}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := repo.ClaimPagesToFetch(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimedWant := []models.PageToFetch{
+		{ID: fetchedID, NormalizedURL: "https://example.com/fetched", FetchAttempts: 1},
+		{ID: failingID, NormalizedURL: "https://example.com/failing", FetchAttempts: 1},
+	}
+	if !reflect.DeepEqual(claimed, claimedWant) {
+		t.Fatalf("Claimed %+v, want %+v", claimed, claimedWant)
+	}
+
+	// Claimed pages are leased, so they can't be claimed twice
+	again, err := repo.ClaimPagesToFetch(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 0 {
+		t.Errorf("Expected leased pages to be skipped, got %+v", again)
+	}
+
+	// The page's own title wins over the client's, and fields the page doesn't have stay
+	err = repo.SaveFetchedPage(ctx, fetchedID, &models.FetchedPage{
+		Metadata:     models.PageMetadata{Title: text("Page title")},
+		OGType:       text("article"),
+		JSONLDTypes:  []string{"NewsArticle"},
+		CanonicalURL: text("https://www.example.com/fetched"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var title, author, ogType, canonicalURL, status string
+	var types []string
+	err = pool.QueryRow(ctx,
+		`SELECT title, author, og_type, jsonld_types, canonical_url, fetch_status FROM pages WHERE id = $1`,
+		fetchedID).Scan(&title, &author, &ogType, &types, &canonicalURL, &status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title != "Page title" || author != "Jane Doe" || ogType != "article" || !reflect.DeepEqual(types, []string{"NewsArticle"}) ||
+		canonicalURL != "https://www.example.com/fetched" || status != "fetched" {
+		t.Errorf("Unexpected fetched page: %q, %q, %q, %v, %q, %q", title, author, ogType, types, canonicalURL, status)
+	}
+
+	signals, err := repo.GetArticleSignals(ctx, utils.HashURL("https://example.com/fetched"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &models.ArticleSignals{OGType: text("article"), JSONLDTypes: []string{"NewsArticle"}, Fetched: true}
+	if !reflect.DeepEqual(signals, want) {
+		t.Errorf("GetArticleSignals() = %+v, want %+v", signals, want)
+	}
+	check, err := repo.GetPageCheck(ctx, utils.HashURL("https://example.com/fetched"), "00000000-0000-0000-0000-000000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(check.Signals, want) {
+		t.Errorf("Check signals = %+v, want %+v", check.Signals, want)
+	}
+
+	// A failed fetch is retried after the delay, an abandoned one never
+	if err := repo.MarkFetchFailed(ctx, failingID, "HTTP 503", -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err = repo.ClaimPagesToFetch(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != failingID || claimed[0].FetchAttempts != 2 {
+		t.Fatalf("Expected the failed page to be due again, got %+v", claimed)
+	}
+	if err := repo.MarkFetchAbandoned(ctx, failingID, "HTTP 503"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Exec(ctx, `UPDATE pages SET next_fetch_at = NOW() - INTERVAL '1 hour'`); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err = repo.ClaimPagesToFetch(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 0 {
+		t.Errorf("Expected no pages to fetch, got %+v", claimed)
+	}
+	if signals, err := repo.GetArticleSignals(ctx, utils.HashURL("https://example.com/failing")); err != nil || signals == nil || !signals.Abandoned {
+		t.Errorf("Expected the abandoned page's signals to say so, got %+v (err: %v)", signals, err)
+	}
+	if signals, err := repo.GetArticleSignals(ctx, utils.HashURL("https://example.com/unknown")); err != nil || signals != nil {
+		t.Errorf("Expected no signals for an unknown page, got %+v (err: %v)", signals, err)
+	}
+}
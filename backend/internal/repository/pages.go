@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/vdavid/web-annotator/backend/internal/db"
@@ -20,78 +23,157 @@ func NewPagesRepository(pool *db.Pool) *PagesRepository {
 	return &PagesRepository{pool: pool}
 }
 
+// pageIDByHashSQL finds the ID of the page with the URL hash in $1, following aliases. It's NULL if there's none.
+const pageIDByHashSQL = `(SELECT id FROM pages WHERE url_hash = $1
+	UNION ALL
+	SELECT page_id FROM page_aliases WHERE url_hash = $1
+	LIMIT 1)`
+
 // GetOrCreatePage ensures a page exists in the database and returns its ID.
-// If the page already exists (by url_hash), it returns the existing ID.
+// If the page already exists (by url_hash, or as an alias of another page), it returns the existing ID.
 // Otherwise, it creates a new page and returns the new ID.
 func (r *PagesRepository) GetOrCreatePage(ctx context.Context, normalizedURL string) (int64, error) {
 	urlHash := utils.HashURL(normalizedURL)
 
-	var pageID int64
-	err := r.pool.QueryRow(ctx,
+	// An existing page or alias is the common case, and never needs the insert
+	var pageID *int64
+	err := r.pool.QueryRow(ctx, `SELECT `+pageIDByHashSQL, urlHash).Scan(&pageID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get existing page: %w", err)
+	}
+	if pageID != nil {
+		return *pageID, nil
+	}
+
+	// The page may be from before URLs kept their case
+	aliased, err := r.AliasLegacyPages(ctx, []string{normalizedURL})
+	if err != nil {
+		return 0, err
+	}
+	if len(aliased) > 0 {
+		return r.GetOrCreatePage(ctx, normalizedURL)
+	}
+
+	var newPageID int64
+	err = r.pool.QueryRow(ctx,
 		`INSERT INTO pages (url_hash, normalized_url) 
 		 VALUES ($1, $2) 
 		 ON CONFLICT (url_hash) DO NOTHING 
 		 RETURNING id`,
-		urlHash, normalizedURL).Scan(&pageID)
+		urlHash, normalizedURL).Scan(&newPageID)
 
 	if err == pgx.ErrNoRows {
-		// Page already exists, fetch its ID
+		// Someone else created the page in the meantime, fetch its ID
 		err = r.pool.QueryRow(ctx,
 			`SELECT id FROM pages WHERE url_hash = $1`,
-			urlHash).Scan(&pageID)
+			urlHash).Scan(&newPageID)
 		if err != nil {
 			return 0, fmt.Errorf("failed to get existing page: %w", err)
 		}
-		return pageID, nil
+		return newPageID, nil
 	}
 
 	if err != nil {
 		return 0, fmt.Errorf("failed to create page: %w", err)
 	}
 
-	return pageID, nil
+	return newPageID, nil
+}
+
+// AliasLegacyPages adds URLs as aliases of the pages stored under their lowercased form. Normalizing used to lowercase
+// paths and query values, so pages stored back then have the hash of the lowercased URL. Call it for URLs whose page
+// wasn't found: it returns the hashes of the ones it found a page for, so the caller can look them up again.
+// URLs that are lowercase already have nothing to find, so if all of them are, it doesn't query.
+func (r *PagesRepository) AliasLegacyPages(ctx context.Context, normalizedURLs []string) ([]string, error) {
+	var urlHashes, urls, legacyHashes []string
+	for _, normalizedURL := range normalizedURLs {
+		if legacyURL := strings.ToLower(normalizedURL); legacyURL != normalizedURL {
+			urlHashes = append(urlHashes, utils.HashURL(normalizedURL))
+			urls = append(urls, normalizedURL)
+			legacyHashes = append(legacyHashes, utils.HashURL(legacyURL))
+		}
+	}
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	rows, err := r.pool.Query(ctx,
+		`INSERT INTO page_aliases (url_hash, normalized_url, page_id)
+		SELECT DISTINCT ON (u.url_hash) u.url_hash, u.normalized_url, found.page_id
+		FROM unnest($1::text[], $2::text[], $3::text[]) AS u(url_hash, normalized_url, legacy_hash)
+		CROSS JOIN LATERAL (
+			SELECT id FROM pages WHERE url_hash = u.legacy_hash
+			UNION ALL
+			SELECT page_id FROM page_aliases WHERE url_hash = u.legacy_hash
+			LIMIT 1
+		) AS found(page_id)
+		WHERE NOT EXISTS (SELECT 1 FROM pages WHERE url_hash = u.url_hash)
+		ON CONFLICT (url_hash) DO NOTHING
+		RETURNING url_hash`,
+		urlHashes, urls, legacyHashes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to alias legacy pages: %w", err)
+	}
+
+	aliased, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to alias legacy pages: %w", err)
+	}
+
+	return aliased, nil
+}
+
+// GetPageURLHashes returns every URL hash that leads to a page: its own, and its aliases'.
+func (r *PagesRepository) GetPageURLHashes(ctx context.Context, pageID int64) ([]string, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT url_hash FROM pages WHERE id = $1
+		UNION ALL
+		SELECT url_hash FROM page_aliases WHERE page_id = $1`,
+		pageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page URL hashes: %w", err)
+	}
+
+	urlHashes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page URL hashes: %w", err)
+	}
+
+	return urlHashes, nil
 }
 
-// GetPageStats retrieves aggregated statistics for a page by its URL hash.
+// GetPageStats retrieves aggregated statistics for a page by its URL hash, following aliases.
+// If the page doesn't exist yet, it returns zero stats.
 func (r *PagesRepository) GetPageStats(ctx context.Context, urlHash string) (*models.PageStats, error) {
-	var stats models.PageStats
+	var counts []int
 	err := r.pool.QueryRow(ctx,
-		`SELECT 
-			COUNT(r.id)::int as total_ratings,
-			COALESCE(AVG(r.score), 0)::float as avg_score
-		FROM pages p
-		LEFT JOIN ratings r ON p.id = r.page_id
-		WHERE p.url_hash = $1
-		GROUP BY p.id`,
-		urlHash).Scan(&stats.TotalRatings, &stats.AverageScore)
+		`SELECT score_histogram FROM pages WHERE id = `+pageIDByHashSQL,
+		urlHash).Scan(&counts)
 
 	if err == pgx.ErrNoRows {
 		// Page doesn't exist yet, return zero stats
-		return &models.PageStats{
-			TotalRatings: 0,
-			AverageScore: 0,
-		}, nil
+		return pageStatsFromHistogram(nil), nil
 	}
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get page stats: %w", err)
 	}
 
-	return &stats, nil
+	return pageStatsFromHistogram(counts), nil
 }
 
-// GetUserRating retrieves the current user's rating for a page, if it exists.
+// GetUserRating retrieves the current user's rating for a page, if it exists. It follows aliases.
 func (r *PagesRepository) GetUserRating(ctx context.Context, urlHash string, userID string) (*models.UserRating, error) {
 	var userRating models.UserRating
 	var score *int
 	var comment *string
 
 	err := r.pool.QueryRow(ctx,
-		`SELECT r.score, r.comment
+		`SELECT r.score, r.comment, r.updated_at
 		FROM pages p
 		INNER JOIN ratings r ON p.id = r.page_id
-		WHERE p.url_hash = $1 AND r.user_id = $2`,
-		urlHash, userID).Scan(&score, &comment)
+		WHERE p.id = `+pageIDByHashSQL+` AND r.user_id = $2`,
+		urlHash, userID).Scan(&score, &comment, &userRating.UpdatedAt)
 
 	if err == pgx.ErrNoRows {
 		// User hasn't rated this page
@@ -112,3 +194,238 @@ func (r *PagesRepository) GetUserRating(ctx context.Context, urlHash string, use
 
 	return &userRating, nil
 }
+
+// GetUserRatings retrieves the user's ratings for many pages by their IDs, in a single query.
+// Pages the user hasn't rated are left out.
+func (r *PagesRepository) GetUserRatings(ctx context.Context, pageIDs []int64, userID string) (map[int64]*models.UserRating, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT page_id, score, comment, updated_at
+		FROM ratings
+		WHERE page_id = ANY($1) AND user_id = $2`,
+		pageIDs, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user ratings: %w", err)
+	}
+	defer rows.Close()
+
+	userRatings := make(map[int64]*models.UserRating, len(pageIDs))
+	for rows.Next() {
+		var pageID int64
+		userRating := models.UserRating{HasRated: true}
+		if err := rows.Scan(&pageID, &userRating.Score, &userRating.Comment, &userRating.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user rating: %w", err)
+		}
+		userRatings[pageID] = &userRating
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get user ratings: %w", err)
+	}
+
+	return userRatings, nil
+}
+
+// GetPageCheck retrieves the stats and the user's rating for a page in a single query, along with what a check
+// response's validators are built from, so a conditional check doesn't need a query of its own.
+// If the page doesn't exist yet, it returns zero stats and no rating.
+func (r *PagesRepository) GetPageCheck(ctx context.Context, urlHash string, userID string) (*models.PageCheck, error) {
+	checks, err := r.GetPageChecks(ctx, []string{urlHash}, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if check, ok := checks[urlHash]; ok {
+		return check, nil
+	}
+	return emptyPageCheck(), nil
+}
+
+// emptyPageCheck returns the check of a page that doesn't exist yet: zero stats and no rating.
+func emptyPageCheck() *models.PageCheck {
+	return &models.PageCheck{
+		Stats:      pageStatsFromHistogram(nil),
+		Metadata:   &models.PageMetadata{},
+		Signals:    &models.ArticleSignals{},
+		UserRating: &models.UserRating{HasRated: false},
+	}
+}
+
+// GetPageChecks retrieves the stats, the metadata, the article signals, and the user's rating for many pages in a single query.
+// The result is keyed by the given URL hashes, so an alias gets its page's check. Pages that don't exist yet are left out.
+func (r *PagesRepository) GetPageChecks(ctx context.Context, urlHashes []string, userID string) (map[string]*models.PageCheck, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT h.url_hash,
+			p.id,
+			p.updated_at,
+			p.score_histogram,
+			`+pageMetadataColumns+`,
+			`+articleSignalsColumns+`,
+			ur.score,
+			ur.comment,
+			ur.updated_at
+		FROM unnest($1::text[]) AS h(url_hash)
+		CROSS JOIN LATERAL (
+			SELECT id FROM pages WHERE url_hash = h.url_hash
+			UNION ALL
+			SELECT page_id FROM page_aliases WHERE url_hash = h.url_hash
+			LIMIT 1
+		) AS found(page_id)
+		INNER JOIN pages p ON p.id = found.page_id
+		LEFT JOIN ratings ur ON ur.page_id = p.id AND ur.user_id = $2`,
+		urlHashes, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page checks: %w", err)
+	}
+	defer rows.Close()
+
+	checks := make(map[string]*models.PageCheck, len(urlHashes))
+	for rows.Next() {
+		var urlHash string
+		var counts []int
+		check := models.PageCheck{Metadata: &models.PageMetadata{}, Signals: &models.ArticleSignals{}, UserRating: &models.UserRating{}}
+		dest := append([]any{&urlHash, &check.PageID, &check.UpdatedAt, &counts}, pageMetadataFields(check.Metadata)...)
+		dest = append(dest, articleSignalsFields(check.Signals)...)
+		dest = append(dest, &check.UserRating.Score, &check.UserRating.Comment, &check.UserRating.UpdatedAt)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan page check: %w", err)
+		}
+
+		check.Stats = pageStatsFromHistogram(counts)
+		check.UserRating.HasRated = check.UserRating.Score != nil
+		checks[urlHash] = &check
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get page checks: %w", err)
+	}
+
+	return checks, nil
+}
+
+// SavePageMetadata merges metadata into what's stored for the page and returns the result.
+// The first value saved for each field wins, so a later visit can fill in missing fields but not change known ones.
+// Once the stored metadata is older than refreshAfter, though, the new values replace it, so pages that
+// changed their title or fixed their author eventually show up right.
+func (r *PagesRepository) SavePageMetadata(ctx context.Context, pageID int64, metadata *models.PageMetadata, refreshAfter time.Duration) (*models.PageMetadata, error) {
+	var publishedAt *time.Time
+	if metadata.PublishedAt != nil {
+		utc := metadata.PublishedAt.UTC()
+		publishedAt = &utc
+	}
+
+	saved := &models.PageMetadata{}
+	err := r.pool.QueryRow(ctx,
+		`UPDATE pages p SET
+			title = CASE WHEN s.stale THEN COALESCE($2, p.title) ELSE COALESCE(p.title, $2) END,
+			site_name = CASE WHEN s.stale THEN COALESCE($3, p.site_name) ELSE COALESCE(p.site_name, $3) END,
+			author = CASE WHEN s.stale THEN COALESCE($4, p.author) ELSE COALESCE(p.author, $4) END,
+			published_at = CASE WHEN s.stale THEN COALESCE($5, p.published_at) ELSE COALESCE(p.published_at, $5) END,
+			language = CASE WHEN s.stale THEN COALESCE($6, p.language) ELSE COALESCE(p.language, $6) END,
+			headline = CASE WHEN s.stale THEN COALESCE($7, p.headline) ELSE COALESCE(p.headline, $7) END,
+			metadata_updated_at = CASE WHEN s.stale THEN NOW() ELSE p.metadata_updated_at END,
+			-- Filling in a missing field changes the page too
+			updated_at = CASE WHEN (p.title, p.site_name, p.author, p.published_at, p.language, p.headline)
+				IS DISTINCT FROM (COALESCE(p.title, $2), COALESCE(p.site_name, $3), COALESCE(p.author, $4),
+					COALESCE(p.published_at, $5), COALESCE(p.language, $6), COALESCE(p.headline, $7))
+				OR s.stale THEN NOW() ELSE p.updated_at END
+		FROM (
+			-- Saving no metadata at all mustn't restart the refresh clock
+			SELECT id, (metadata_updated_at IS NULL OR metadata_updated_at < NOW() - $8::interval)
+				AND num_nonnulls($2, $3, $4, $5, $6, $7) > 0 AS stale
+			FROM pages WHERE id = $1
+		) s
+		WHERE p.id = s.id
+		RETURNING `+pageMetadataColumns,
+		pageID, metadata.Title, metadata.SiteName, metadata.Author, publishedAt, metadata.Language, metadata.Headline,
+		refreshAfter).Scan(pageMetadataFields(saved)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save page metadata: %w", err)
+	}
+
+	return saved, nil
+}
+
+// pageMetadataColumns selects a page's metadata from pages aliased as p. Scan it with pageMetadataFields.
+const pageMetadataColumns = `p.title, p.site_name, p.author, p.published_at, p.language, p.headline`
+
+// pageMetadataFields returns the scan destinations for pageMetadataColumns.
+func pageMetadataFields(metadata *models.PageMetadata) []any {
+	return []any{&metadata.Title, &metadata.SiteName, &metadata.Author, &metadata.PublishedAt, &metadata.Language, &metadata.Headline}
+}
+
+// GetArticleSignals returns what the fetcher found out about whether a page is an article, following aliases.
+// If the page doesn't exist yet, it returns nil.
+func (r *PagesRepository) GetArticleSignals(ctx context.Context, urlHash string) (*models.ArticleSignals, error) {
+	signals := &models.ArticleSignals{}
+	err := r.pool.QueryRow(ctx,
+		`SELECT `+articleSignalsColumns+` FROM pages p WHERE p.id = `+pageIDByHashSQL,
+		urlHash).Scan(articleSignalsFields(signals)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get article signals: %w", err)
+	}
+	return signals, nil
+}
+
+// articleSignalsColumns selects a page's article signals from pages aliased as p. Scan it with articleSignalsFields.
+const articleSignalsColumns = `p.og_type, COALESCE(p.jsonld_types, '{}'), p.fetch_status = 'fetched', p.fetch_status = 'failed'`
+
+// articleSignalsFields returns the scan destinations for articleSignalsColumns.
+func articleSignalsFields(signals *models.ArticleSignals) []any {
+	return []any{&signals.OGType, &signals.JSONLDTypes, &signals.Fetched, &signals.Abandoned}
+}
+
+// GetScoreTotals returns the sum and count of all ratings, grouped by the domain of the rated page.
+// Domains with no ratings are left out.
+func (r *PagesRepository) GetScoreTotals(ctx context.Context) ([]models.ScoreTotals, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+domainSQL+` AS domain,
+			SUM(p.score_sum)::bigint AS score_sum,
+			SUM(p.rating_count)::bigint AS rating_count
+		FROM pages p
+		WHERE p.rating_count > 0
+		GROUP BY 1`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get score totals: %w", err)
+	}
+
+	totals, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.ScoreTotals])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get score totals: %w", err)
+	}
+
+	return totals, nil
+}
+
+// ListTopPages returns the rated pages with the highest adjusted score, best first.
+// Ties go to the page with more ratings.
+func (r *PagesRepository) ListTopPages(ctx context.Context, priors models.ScorePriors, limit int) ([]models.TopPage, error) {
+	domains := make([]string, 0, len(priors.Domains))
+	domainPriors := make([]float64, 0, len(priors.Domains))
+	for domain, prior := range priors.Domains {
+		domains = append(domains, domain)
+		domainPriors = append(domainPriors, prior)
+	}
+
+	rows, err := r.pool.Query(ctx,
+		`SELECT p.normalized_url,
+			p.rating_count AS total_ratings,
+			p.score_sum::float8 / p.rating_count AS avg_score,
+			($1::float8 * COALESCE(d.prior, $2::float8) + p.score_sum) / ($1::float8 + p.rating_count) AS adjusted_score
+		FROM pages p
+		LEFT JOIN unnest($3::text[], $4::float8[]) AS d(domain, prior) ON d.domain = `+domainSQL+`
+		WHERE p.rating_count > 0
+		ORDER BY adjusted_score DESC, p.rating_count DESC
+		LIMIT $5`,
+		float64(priors.Weight), priors.Global, domains, domainPriors, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list top pages: %w", err)
+	}
+
+	pages, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.TopPage])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list top pages: %w", err)
+	}
+
+	return pages, nil
+}
@@ -0,0 +1,286 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/cache"
+	"github.com/vdavid/web-annotator/backend/internal/db"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/testutil"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+// seedRatedPages fills the database with a realistic amount of data: pageCount pages on one domain,
+// each rated by ratingsPerPage of 200 users. It returns the ID of the first user, who has rated the first page.
+// Page i has the normalized URL https://example.com/i, counting from 1.
+func seedRatedPages(tb testing.TB, pool *db.Pool, pageCount int, ratingsPerPage int) string {
+	tb.Helper()
+	ctx := context.Background()
+
+	statements := []string{
+		`INSERT INTO users (id, username)
+		SELECT md5('user' || u)::uuid, 'user' || u
+		FROM generate_series(1, 200) AS u`,
+		`INSERT INTO pages (url_hash, normalized_url)
+		SELECT encode(sha256(convert_to('https://example.com/' || p, 'UTF8')), 'hex'), 'https://example.com/' || p
+		FROM generate_series(1, $1::int) AS p`,
+		`INSERT INTO ratings (user_id, page_id, score)
+		SELECT md5('user' || ((p.id + k - 1) % 200 + 1))::uuid, p.id, 1 + (p.id * k) % 10
+		FROM pages p, generate_series(0, $1::int - 1) AS k`,
+	}
+	args := [][]any{nil, {pageCount}, {ratingsPerPage}}
+	for i, statement := range statements {
+		if _, err := pool.Exec(ctx, statement, args[i]...); err != nil {
+			tb.Fatalf("failed to seed test data: %v", err)
+		}
+	}
+	// The ratings went in behind the repository's back, so the pages' stored stats need a refresh
+	if _, err := repository.NewPagesRepository(pool).RecomputePageStats(ctx, false); err != nil {
+		tb.Fatalf("failed to compute seeded page stats: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `ANALYZE`); err != nil {
+		tb.Fatalf("failed to analyze test data: %v", err)
+	}
+
+	var userID string
+	err := pool.QueryRow(ctx,
+		`SELECT r.user_id::text FROM ratings r INNER JOIN pages p ON p.id = r.page_id
+		WHERE p.normalized_url = 'https://example.com/1' ORDER BY r.id LIMIT 1`).Scan(&userID)
+	if err != nil {
+		tb.Fatalf("failed to find a seeded rating: %v", err)
+	}
+	return userID
+}
+
+func TestPagesRepository_GetPageCheck(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	userID := seedRatedPages(t, pool, 3, 20)
+	repo := repository.NewPagesRepository(pool)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		url    string
+		userID string
+	}{
+		{name: "rated by the user", url: "https://example.com/1", userID: userID},
+		{name: "not rated by the user", url: "https://example.com/1", userID: "00000000-0000-0000-0000-000000000001"},
+		{name: "unknown page", url: "https://example.com/nope", userID: userID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlHash := utils.HashURL(tt.url)
+
+			check, err := repo.GetPageCheck(ctx, urlHash, tt.userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// It must agree with the two separate queries it replaces
+			stats, err := repo.GetPageStats(ctx, urlHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			userRating, err := repo.GetUserRating(ctx, urlHash, tt.userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(check.Stats, stats) {
+				t.Errorf("Stats = %+v, want %+v", check.Stats, stats)
+			}
+			if !reflect.DeepEqual(check.UserRating, userRating) {
+				t.Errorf("UserRating = %+v, want %+v", check.UserRating, userRating)
+			}
+
+			// And it must come with what its validators are built from, if the page exists
+			if exists := tt.url != "https://example.com/nope"; (check.PageID != 0) != exists || check.UpdatedAt.IsZero() == exists {
+				t.Errorf("Expected a page ID and an update time only for existing pages, got %d and %v", check.PageID, check.UpdatedAt)
+			}
+			if (check.UserRating.UpdatedAt != nil) != userRating.HasRated {
+				t.Errorf("Expected a rating time only for rated pages, got %v", check.UserRating.UpdatedAt)
+			}
+
+			// Cached checks load the rating by page ID instead
+			userRatings, err := repo.GetUserRatings(ctx, []int64{check.PageID}, tt.userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := userRatings[check.PageID]; ok != userRating.HasRated || (ok && !reflect.DeepEqual(got, userRating)) {
+				t.Errorf("GetUserRatings() = %+v, want %+v", userRatings, userRating)
+			}
+		})
+	}
+}
+
+func TestPagesRepository_AliasLegacyPages(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	userID := seedRatedPages(t, pool, 1, 5)
+	pagesRepo := repository.NewPagesRepository(pool)
+	ratingsRepo := repository.NewRatingsRepository(pool)
+	ctx := context.Background()
+
+	// Before normalizing kept the case of paths and query values, these were stored lowercased
+	legacyURL, legacyPathURL := "https://youtube.com/watch?v=abc", "https://example.com/about"
+	pageID, err := pagesRepo.GetOrCreatePage(ctx, legacyURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ratingsRepo.UpsertRating(ctx, pageID, userID, 7, nil); err != nil {
+		t.Fatal(err)
+	}
+	legacyPathID, err := pagesRepo.GetOrCreatePage(ctx, legacyPathURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Now the same links normalize with their case
+	pageURL, pathURL := "https://youtube.com/watch?v=AbC", "https://example.com/About"
+	check, err := pagesRepo.GetPageCheck(ctx, utils.HashURL(pageURL), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.PageID != 0 {
+		t.Fatalf("Expected no page under the new URL yet, got %+v", check)
+	}
+
+	// Lowercase URLs and ones without a legacy page have nothing to alias
+	aliased, err := pagesRepo.AliasLegacyPages(ctx, []string{pageURL, legacyURL, "https://example.com/New"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(aliased, []string{utils.HashURL(pageURL)}) {
+		t.Errorf("Expected only the new URL to be aliased, got %v", aliased)
+	}
+
+	// The rating is found under the new URL
+	check, err = pagesRepo.GetPageCheck(ctx, utils.HashURL(pageURL), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.PageID != pageID || !check.UserRating.HasRated || *check.UserRating.Score != 7 || check.Stats.TotalRatings != 1 {
+		t.Errorf("Expected the legacy page and its rating, got %+v with rating %+v", check, check.UserRating)
+	}
+
+	// Aliasing again changes nothing
+	if aliased, err := pagesRepo.AliasLegacyPages(ctx, []string{pageURL}); err != nil || len(aliased) != 0 {
+		t.Errorf("Expected nothing new to alias, got %v (error: %v)", aliased, err)
+	}
+
+	// Getting the page by its new URL aliases it too, instead of creating a copy
+	if gotID, err := pagesRepo.GetOrCreatePage(ctx, pathURL); err != nil || gotID != legacyPathID {
+		t.Errorf("GetOrCreatePage() = %d (error: %v), want the legacy page %d", gotID, err, legacyPathID)
+	}
+	urlHashes, err := pagesRepo.GetPageURLHashes(ctx, legacyPathID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(urlHashes, utils.HashURL(pathURL)) {
+		t.Errorf("Expected the new URL among the page's hashes, got %v", urlHashes)
+	}
+}
+
+func TestPagesRepository_SavePageMetadata(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	repo := repository.NewPagesRepository(pool)
+	ctx := context.Background()
+
+	pageURL := "https://example.com/article"
+	pageID, err := repo.GetOrCreatePage(ctx, pageURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := func(s string) *string { return &s }
+	published := time.Date(2025, 5, 30, 9, 15, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	// The first save wins
+	saved, err := repo.SavePageMetadata(ctx, pageID, &models.PageMetadata{Title: text("First title"), PublishedAt: &published}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *saved.Title != "First title" || !saved.PublishedAt.Equal(published) {
+		t.Errorf("Unexpected first save: %+v", saved)
+	}
+
+	// Later saves only fill in what's missing
+	saved, err = repo.SavePageMetadata(ctx, pageID, &models.PageMetadata{Title: text("Second title"), Author: text("Jane Doe")}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *saved.Title != "First title" || saved.Author == nil || *saved.Author != "Jane Doe" {
+		t.Errorf("Expected the first title and the new author, got %+v", saved)
+	}
+
+	// Saving nothing doesn't count as a refresh, even when the metadata is stale
+	if _, err := pool.Exec(ctx, `UPDATE pages SET metadata_updated_at = NOW() - INTERVAL '2 hours' WHERE id = $1`, pageID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.SavePageMetadata(ctx, pageID, &models.PageMetadata{}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stale metadata gets replaced, but fields the new save doesn't know stay
+	saved, err = repo.SavePageMetadata(ctx, pageID, &models.PageMetadata{Title: text("Fixed title")}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *saved.Title != "Fixed title" || saved.Author == nil || *saved.Author != "Jane Doe" {
+		t.Errorf("Expected the refreshed title and the old author, got %+v", saved)
+	}
+
+	// And the check returns it
+	check, err := repo.GetPageCheck(ctx, utils.HashURL(pageURL), "00000000-0000-0000-0000-000000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(check.Metadata, saved) {
+		t.Errorf("Check metadata = %+v, want %+v", check.Metadata, saved)
+	}
+}
+
+// BenchmarkPageCheck compares what the check endpoint runs, with the cache off and with it warm, against the
+// two queries it used to run.
+// Run it with TEST_DATABASE_URL set: go test ./internal/repository -run '^$' -bench PageCheck
+func BenchmarkPageCheck(b *testing.B) {
+	pool := testutil.NewTestPool(b)
+	userID := seedRatedPages(b, pool, 10000, 50)
+	repo := repository.NewPagesRepository(pool)
+	ctx := context.Background()
+	urlHash := utils.HashURL("https://example.com/1")
+
+	b.Run("stats and rating queries", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := repo.GetPageStats(ctx, urlHash); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := repo.GetUserRating(ctx, urlHash, userID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("check", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := repo.GetPageCheck(ctx, urlHash, userID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cached check", func(b *testing.B) {
+		cached := repository.NewCachedPagesRepository(repo, cache.NewLRU[string, models.PageCheck](10, time.Hour))
+		if _, err := cached.GetPageCheck(ctx, urlHash, userID); err != nil {
+			b.Fatal(err)
+		}
+		for b.Loop() {
+			if _, err := cached.GetPageCheck(ctx, urlHash, userID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
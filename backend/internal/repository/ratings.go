@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/jackc/pgx/v5"
@@ -9,6 +10,9 @@ import (
 	"github.com/vdavid/web-annotator/backend/internal/models"
 )
 
+// ErrRatingNotFound is returned when the user hasn't rated the page.
+var ErrRatingNotFound = errors.New("rating not found")
+
 // RatingsRepository handles database operations for ratings.
 type RatingsRepository struct {
 	pool *db.Pool
@@ -20,33 +24,61 @@ func NewRatingsRepository(pool *db.Pool) *RatingsRepository {
 }
 
 // UpsertRating creates or updates a user's rating for a page.
-// It uses a transaction to ensure atomicity.
+// It uses a transaction to ensure atomicity: the rating, the page's stored stats,
+// the revision history entry, and the webhook events are written together.
 func (r *RatingsRepository) UpsertRating(ctx context.Context, pageID int64, userID string, score int, comment *string) error {
 	tx, err := r.pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer func(tx pgx.Tx, ctx context.Context) {
-		err := tx.Rollback(ctx)
-		if err != nil {
-			fmt.Printf("Failed to rollback transaction: %v\n", err)
-		}
-	}(tx, ctx)
+	defer rollback(ctx, tx)
+
+	if err := lockPage(ctx, tx, pageID); err != nil {
+		return err
+	}
+
+	// The previous score, if any, has to come out of the page's stats
+	var oldScore *int
+	err = tx.QueryRow(ctx,
+		`SELECT score FROM ratings WHERE user_id = $1 AND page_id = $2`,
+		userID, pageID).Scan(&oldScore)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to get previous rating: %w", err)
+	}
 
-	_, err = tx.Exec(ctx,
+	// xmax is 0 for freshly inserted rows, which tells us whether this was a create or an update
+	var inserted bool
+	err = tx.QueryRow(ctx,
 		`INSERT INTO ratings (user_id, page_id, score, comment, updated_at)
 		 VALUES ($1, $2, $3, $4, NOW())
 		 ON CONFLICT (user_id, page_id) 
 		 DO UPDATE SET 
 			score = EXCLUDED.score,
 			comment = EXCLUDED.comment,
-			updated_at = NOW()`,
-		userID, pageID, score, comment)
+			updated_at = NOW()
+		 RETURNING (xmax = 0)`,
+		userID, pageID, score, comment).Scan(&inserted)
 
 	if err != nil {
 		return fmt.Errorf("failed to upsert rating: %w", err)
 	}
 
+	if err := updatePageStats(ctx, tx, pageID, oldScore, &score); err != nil {
+		return err
+	}
+
+	action := models.RevisionUpdated
+	if inserted {
+		action = models.RevisionCreated
+	}
+	if err := insertRevision(ctx, tx, userID, pageID, action, &score, comment); err != nil {
+		return err
+	}
+
+	if err := enqueueWebhookEvents(ctx, tx, userID, pageID, action, &score, comment); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -54,20 +86,133 @@ func (r *RatingsRepository) UpsertRating(ctx context.Context, pageID int64, user
 	return nil
 }
 
-// GetPageStatsAfterRating recalculates page statistics after a rating change.
+// GetPageStatsAfterRating reads the page statistics after a rating change.
 func (r *RatingsRepository) GetPageStatsAfterRating(ctx context.Context, pageID int64) (*models.PageStats, error) {
-	var stats models.PageStats
+	var counts []int
 	err := r.pool.QueryRow(ctx,
-		`SELECT 
-			COUNT(id)::int as total_ratings,
-			COALESCE(AVG(score), 0)::float as avg_score
-		FROM ratings
-		WHERE page_id = $1`,
-		pageID).Scan(&stats.TotalRatings, &stats.AverageScore)
+		`SELECT score_histogram FROM pages WHERE id = $1`,
+		pageID).Scan(&counts)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get page stats: %w", err)
 	}
 
-	return &stats, nil
+	return pageStatsFromHistogram(counts), nil
+}
+
+// DeleteRating removes a user's rating for the page with the given URL hash or alias and returns the page's ID,
+// so the caller can recompute its statistics. It returns ErrRatingNotFound if there's nothing to delete.
+// The page's stored stats, the revision history, and the webhook events are updated in the same transaction.
+func (r *RatingsRepository) DeleteRating(ctx context.Context, urlHash string, userID string) (int64, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer rollback(ctx, tx)
+
+	// Lock the page like lockPage does, we just don't know its ID yet
+	var pageID int64
+	err = tx.QueryRow(ctx,
+		`SELECT id FROM pages WHERE id = `+pageIDByHashSQL+` FOR UPDATE`,
+		urlHash).Scan(&pageID)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrRatingNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock page: %w", err)
+	}
+
+	var oldScore *int
+	err = tx.QueryRow(ctx,
+		`DELETE FROM ratings
+		WHERE page_id = $1 AND user_id = $2
+		RETURNING score`,
+		pageID, userID).Scan(&oldScore)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrRatingNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete rating: %w", err)
+	}
+
+	if err := updatePageStats(ctx, tx, pageID, oldScore, nil); err != nil {
+		return 0, err
+	}
+
+	if err := insertRevision(ctx, tx, userID, pageID, models.RevisionDeleted, nil, nil); err != nil {
+		return 0, err
+	}
+
+	if err := enqueueWebhookEvents(ctx, tx, userID, pageID, models.RevisionDeleted, nil, nil); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return pageID, nil
+}
+
+// GetRatingHistory returns every revision of a user's rating for the page with the given URL hash or alias, newest first.
+// It returns an empty list if the user never rated the page.
+func (r *RatingsRepository) GetRatingHistory(ctx context.Context, urlHash string, userID string) ([]models.RatingRevision, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT rr.id, rr.user_id, rr.page_id, rr.action, rr.score, rr.comment, rr.created_at
+		FROM pages p
+		INNER JOIN rating_revisions rr ON p.id = rr.page_id
+		WHERE p.id = `+pageIDByHashSQL+` AND rr.user_id = $2
+		ORDER BY rr.created_at DESC, rr.id DESC`,
+		urlHash, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rating history: %w", err)
+	}
+
+	revisions, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.RatingRevision])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rating history: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// insertRevision appends an entry to the rating's revision history.
+func insertRevision(ctx context.Context, tx pgx.Tx, userID string, pageID int64, action string, score *int, comment *string) error {
+	_, err := tx.Exec(ctx,
+		`INSERT INTO rating_revisions (user_id, page_id, action, score, comment)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		userID, pageID, action, score, comment)
+	if err != nil {
+		return fmt.Errorf("failed to record rating revision: %w", err)
+	}
+	return nil
+}
+
+// enqueueWebhookEvents adds the rating change to the webhook outbox, once for each of the user's subscriptions
+// that wants it. The webhook worker sends them after the transaction commits.
+func enqueueWebhookEvents(ctx context.Context, tx pgx.Tx, userID string, pageID int64, action string, score *int, comment *string) error {
+	_, err := tx.Exec(ctx,
+		`INSERT INTO webhook_deliveries (subscription_id, event_type, payload)
+		 SELECT s.id, $3::text, jsonb_build_object(
+			'url', p.normalized_url,
+			'user_id', $1::uuid,
+			'score', $4::int,
+			'comment', $5::text)
+		 FROM webhook_subscriptions s
+		 INNER JOIN pages p ON p.id = $2
+		 WHERE s.user_id = $1 AND $3 = ANY(s.events)`,
+		userID, pageID, "rating."+action, score, comment)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook events: %w", err)
+	}
+	return nil
+}
+
+// rollback rolls back tx unless it's already committed. Meant to be deferred right after Begin.
+func rollback(ctx context.Context, tx pgx.Tx) {
+	if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		fmt.Printf("Failed to rollback transaction: %v\n", err)
+	}
 }
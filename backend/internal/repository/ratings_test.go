@@ -0,0 +1,116 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/testutil"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+func TestRatingsRepository_KeepsStoredStatsInSync(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	seedRatedPages(t, pool, 2, 5)
+	pagesRepo := repository.NewPagesRepository(pool)
+	ratingsRepo := repository.NewRatingsRepository(pool)
+	ctx := context.Background()
+
+	// Users 150 and 151 haven't rated anything yet
+	var userA, userB string
+	if err := pool.QueryRow(ctx, `SELECT md5('user150')::uuid::text, md5('user151')::uuid::text`).Scan(&userA, &userB); err != nil {
+		t.Fatal(err)
+	}
+	pageURL := "https://example.com/1"
+	pageID, err := pagesRepo.GetOrCreatePage(ctx, pageURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"create", func() error { return ratingsRepo.UpsertRating(ctx, pageID, userA, 3, nil) }},
+		{"create another", func() error { return ratingsRepo.UpsertRating(ctx, pageID, userB, 9, nil) }},
+		{"update", func() error { return ratingsRepo.UpsertRating(ctx, pageID, userA, 7, nil) }},
+		{"update to the same score", func() error { return ratingsRepo.UpsertRating(ctx, pageID, userA, 7, nil) }},
+		{"delete", func() error {
+			_, err := ratingsRepo.DeleteRating(ctx, utils.HashURL(pageURL), userB)
+			return err
+		}},
+		{"rate a new page", func() error {
+			newPageID, err := pagesRepo.GetOrCreatePage(ctx, "https://example.com/new")
+			if err != nil {
+				return err
+			}
+			return ratingsRepo.UpsertRating(ctx, newPageID, userB, 10, nil)
+		}},
+	}
+
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		drift, err := pagesRepo.RecomputePageStats(ctx, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(drift) != 0 {
+			t.Fatalf("%s: stored stats drifted: %+v", step.name, drift)
+		}
+	}
+}
+
+func TestPagesRepository_RecomputePageStats(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	seedRatedPages(t, pool, 3, 4)
+	pagesRepo := repository.NewPagesRepository(pool)
+	ctx := context.Background()
+
+	if _, err := pool.Exec(ctx,
+		`UPDATE pages SET rating_count = 99, score_histogram = '{0,0,0,0,0,0,0,0,0,99}'
+		WHERE normalized_url = 'https://example.com/2'`); err != nil {
+		t.Fatal(err)
+	}
+	want, err := pagesRepo.GetPageStats(ctx, utils.HashURL("https://example.com/1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A dry run reports the drift but leaves it alone
+	for _, dryRun := range []bool{true, false} {
+		drift, err := pagesRepo.RecomputePageStats(ctx, dryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(drift) != 1 || drift[0].NormalizedURL != "https://example.com/2" {
+			t.Fatalf("dryRun=%v: expected drift on page 2 only, got %+v", dryRun, drift)
+		}
+		if drift[0].StoredRatingCount != 99 || drift[0].ActualRatingCount != 4 {
+			t.Errorf("dryRun=%v: expected count 99 -> 4, got %d -> %d", dryRun, drift[0].StoredRatingCount, drift[0].ActualRatingCount)
+		}
+	}
+
+	drift, err := pagesRepo.RecomputePageStats(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drift) != 0 {
+		t.Errorf("Expected no drift after fixing, got %+v", drift)
+	}
+
+	// Untouched pages keep their stats
+	got, err := pagesRepo.GetPageStats(ctx, utils.HashURL("https://example.com/1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Page 1 stats changed from %+v to %+v", want, got)
+	}
+	if got.TotalRatings != 4 || got.Histogram == ([models.MaxScore]int{}) {
+		t.Errorf("Expected page 1 to have 4 ratings, got %+v", got)
+	}
+}
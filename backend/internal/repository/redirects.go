@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vdavid/web-annotator/backend/internal/db"
+)
+
+// RedirectsRepository caches where link wrappers redirect to.
+type RedirectsRepository struct {
+	pool *db.Pool
+}
+
+// NewRedirectsRepository creates a new redirects repository.
+func NewRedirectsRepository(pool *db.Pool) *RedirectsRepository {
+	return &RedirectsRepository{pool: pool}
+}
+
+// GetRedirect returns where a link redirects to, or nil if we haven't resolved it yet.
+func (r *RedirectsRepository) GetRedirect(ctx context.Context, link string) (*string, error) {
+	var target string
+	err := r.pool.QueryRow(ctx, `SELECT target FROM url_redirects WHERE link = $1`, link).Scan(&target)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get redirect: %w", err)
+	}
+	return &target, nil
+}
+
+// SaveRedirect stores where a link redirects to, replacing what we knew.
+func (r *RedirectsRepository) SaveRedirect(ctx context.Context, link string, target string) error {
+	_, err := r.pool.Exec(ctx,
+		`INSERT INTO url_redirects (link, target)
+		VALUES ($1, $2)
+		ON CONFLICT (link) DO UPDATE SET target = EXCLUDED.target, resolved_at = NOW()`,
+		link, target)
+	if err != nil {
+		return fmt.Errorf("failed to save redirect: %w", err)
+	}
+	return nil
+}
+
+// DeleteRedirects deletes redirects resolved more than olderThan ago, and returns how many it deleted.
+func (r *RedirectsRepository) DeleteRedirects(ctx context.Context, olderThan time.Duration) (int64, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM url_redirects WHERE resolved_at < NOW() - $1::interval`, olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old redirects: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,45 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/testutil"
+)
+
+func TestRedirectsRepository(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	repo := repository.NewRedirectsRepository(pool)
+	ctx := context.Background()
+	link := "https://t.co/abc123"
+
+	target, err := repo.GetRedirect(ctx, link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != nil {
+		t.Errorf("Expected no redirect before saving one, got %q", *target)
+	}
+
+	for _, saved := range []string{"https://example.com/first", "https://example.com/second"} {
+		if err := repo.SaveRedirect(ctx, link, saved); err != nil {
+			t.Fatal(err)
+		}
+		target, err = repo.GetRedirect(ctx, link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target == nil || *target != saved {
+			t.Errorf("GetRedirect() = %v, want %q", target, saved)
+		}
+	}
+
+	if deleted, err := repo.DeleteRedirects(ctx, time.Hour); err != nil || deleted != 0 {
+		t.Errorf("Expected a fresh redirect to be kept, got %d deleted (err: %v)", deleted, err)
+	}
+	if deleted, err := repo.DeleteRedirects(ctx, -time.Hour); err != nil || deleted != 1 {
+		t.Errorf("Expected the redirect to be deleted, got %d (err: %v)", deleted, err)
+	}
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+// RehashPages normalizes every page's stored URL again with normalize, and moves pages whose URL changed to the
+// new URL's hash. If another page already has the new URL, or it's an alias of one, it merges the page into that one,
+// see mergePages.
+// With dryRun, it only reports what it would do.
+// It can't restore the case of URLs stored lowercased, see AliasLegacyPages for those.
+// Each page moves in its own transaction, so it's safe to run while the server is up, and to run again if it stops.
+func (r *PagesRepository) RehashPages(ctx context.Context, normalize func(string) (string, error), dryRun bool) ([]models.PageRehash, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id, normalized_url FROM pages ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pages: %w", err)
+	}
+	var changes []models.PageRehash
+	var pageID int64
+	var oldURL string
+	_, err = pgx.ForEachRow(rows, []any{&pageID, &oldURL}, func() error {
+		newURL, err := normalize(oldURL)
+		if err != nil {
+			return fmt.Errorf("failed to normalize page %d (%s): %w", pageID, oldURL, err)
+		}
+		if newURL != oldURL {
+			changes = append(changes, models.PageRehash{PageID: pageID, OldURL: oldURL, NewURL: newURL})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pages: %w", err)
+	}
+
+	// Normalizing is idempotent, so a new URL never belongs to a page that moves later in this loop
+	for i := range changes {
+		change := &changes[i]
+		if dryRun {
+			err = r.pool.QueryRow(ctx, `SELECT `+pageIDByHashSQL, utils.HashURL(change.NewURL)).Scan(&change.MergedInto)
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up page: %w", err)
+			}
+			continue
+		}
+		if change.MergedInto, err = r.rehashPage(ctx, change.PageID, change.NewURL); err != nil {
+			return nil, fmt.Errorf("failed to rehash page %d (%s): %w", change.PageID, change.OldURL, err)
+		}
+	}
+
+	if !dryRun {
+		if err := r.rehashAliases(ctx, normalize); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// rehashAliases moves aliases to the hashes of their URLs under normalize. An alias whose new URL already leads
+// to a page is dropped, since looking it up works without it.
+func (r *PagesRepository) rehashAliases(ctx context.Context, normalize func(string) (string, error)) error {
+	rows, err := r.pool.Query(ctx, `SELECT url_hash, normalized_url FROM page_aliases ORDER BY url_hash`)
+	if err != nil {
+		return fmt.Errorf("failed to list aliases: %w", err)
+	}
+	type alias struct{ hash, newURL string }
+	var changed []alias
+	var urlHash, oldURL string
+	_, err = pgx.ForEachRow(rows, []any{&urlHash, &oldURL}, func() error {
+		newURL, err := normalize(oldURL)
+		if err != nil {
+			return fmt.Errorf("failed to normalize alias %s: %w", oldURL, err)
+		}
+		if newURL != oldURL {
+			changed = append(changed, alias{hash: urlHash, newURL: newURL})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list aliases: %w", err)
+	}
+
+	for _, a := range changed {
+		tag, err := r.pool.Exec(ctx,
+			`UPDATE page_aliases SET url_hash = $2, normalized_url = $3
+			WHERE url_hash = $1
+				AND NOT EXISTS (SELECT 1 FROM pages WHERE url_hash = $2)
+				AND NOT EXISTS (SELECT 1 FROM page_aliases WHERE url_hash = $2)`,
+			a.hash, utils.HashURL(a.newURL), a.newURL)
+		if err != nil {
+			return fmt.Errorf("failed to rehash alias %s: %w", a.newURL, err)
+		}
+		if tag.RowsAffected() == 0 {
+			if _, err := r.pool.Exec(ctx, `DELETE FROM page_aliases WHERE url_hash = $1`, a.hash); err != nil {
+				return fmt.Errorf("failed to delete alias %s: %w", a.newURL, err)
+			}
+		}
+	}
+	return nil
+}
+
+// rehashPage moves a page to a new URL, or merges it into the page that already has it and returns that page's ID.
+func (r *PagesRepository) rehashPage(ctx context.Context, pageID int64, newURL string) (*int64, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer rollback(ctx, tx)
+
+	newHash := utils.HashURL(newURL)
+	var targetID *int64
+	if err := tx.QueryRow(ctx, `SELECT `+pageIDByHashSQL, newHash).Scan(&targetID); err != nil {
+		return nil, fmt.Errorf("failed to look up page: %w", err)
+	}
+	// The new URL may be one of the page's own aliases, which it then replaces
+	if targetID == nil || *targetID == pageID {
+		if err := lockPage(ctx, tx, pageID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM page_aliases WHERE url_hash = $1`, newHash); err != nil {
+			return nil, fmt.Errorf("failed to delete alias: %w", err)
+		}
+		_, err = tx.Exec(ctx, `UPDATE pages SET url_hash = $2, normalized_url = $3 WHERE id = $1`, pageID, newHash, newURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update page URL: %w", err)
+		}
+		return nil, tx.Commit(ctx)
+	}
+
+	if err := mergePages(ctx, tx, pageID, *targetID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return targetID, nil
+}
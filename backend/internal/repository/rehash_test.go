@@ -0,0 +1,108 @@
+package repository_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/testutil"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+func TestPagesRepository_RehashPages(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	seedRatedPages(t, pool, 1, 5)
+	pagesRepo := repository.NewPagesRepository(pool)
+	ratingsRepo := repository.NewRatingsRepository(pool)
+	ctx := context.Background()
+
+	// Users 150 and 151 haven't rated anything yet
+	var userA, userB string
+	if err := pool.QueryRow(ctx, `SELECT md5('user150')::uuid::text, md5('user151')::uuid::text`).Scan(&userA, &userB); err != nil {
+		t.Fatal(err)
+	}
+	pageIDs := make(map[string]int64)
+	for _, pageURL := range []string{"https://example.com/OLD/a", "https://example.com/OLD/b", "https://example.com/new/b"} {
+		pageID, err := pagesRepo.GetOrCreatePage(ctx, pageURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pageIDs[pageURL] = pageID
+	}
+	oldB, newB := pageIDs["https://example.com/OLD/b"], pageIDs["https://example.com/new/b"]
+	title := "Page B"
+	if _, err := pagesRepo.SavePageMetadata(ctx, oldB, &models.PageMetadata{Title: &title}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// User A rated both copies of page B, the new one later. User B only rated the old one.
+	for _, rating := range []struct {
+		pageID int64
+		userID string
+		score  int
+	}{{oldB, userA, 3}, {oldB, userB, 5}, {newB, userA, 8}} {
+		if err := ratingsRepo.UpsertRating(ctx, rating.pageID, rating.userID, rating.score, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	normalize := func(rawURL string) (string, error) { return strings.Replace(rawURL, "/OLD/", "/new/", 1), nil }
+
+	// A dry run reports, but changes nothing
+	changes, err := pagesRepo.RehashPages(ctx, normalize, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].MergedInto != nil || changes[1].MergedInto == nil || *changes[1].MergedInto != newB {
+		t.Fatalf("Unexpected dry run changes: %+v", changes)
+	}
+	if stats, _ := pagesRepo.GetPageStats(ctx, utils.HashURL("https://example.com/OLD/b")); stats.TotalRatings != 2 {
+		t.Errorf("Expected the dry run to leave the old page alone, got %+v", stats)
+	}
+
+	if _, err := pagesRepo.RehashPages(ctx, normalize, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Page A just moved
+	if stats, _ := pagesRepo.GetPageStats(ctx, utils.HashURL("https://example.com/OLD/a")); stats.TotalRatings != 0 {
+		t.Errorf("Expected nothing under the old URL, got %+v", stats)
+	}
+	check, err := pagesRepo.GetPageCheck(ctx, utils.HashURL("https://example.com/new/a"), userA)
+	if err != nil || check == nil {
+		t.Fatalf("Expected page A under its new URL, got %+v, %v", check, err)
+	}
+
+	// Page B merged: user A's newer rating won, user B's moved, and the title came along
+	check, err = pagesRepo.GetPageCheck(ctx, utils.HashURL("https://example.com/new/b"), userA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Stats.TotalRatings != 2 || check.Stats.AverageScore != 6.5 {
+		t.Errorf("Expected the merged stats, got %+v", check.Stats)
+	}
+	if check.UserRating.Score == nil || *check.UserRating.Score != 8 {
+		t.Errorf("Expected user A's newer rating to win, got %+v", check.UserRating)
+	}
+	if check.Metadata == nil || check.Metadata.Title == nil || *check.Metadata.Title != title {
+		t.Errorf("Expected the merged page to get the title, got %+v", check.Metadata)
+	}
+	drift, err := pagesRepo.RecomputePageStats(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drift) > 0 {
+		t.Errorf("Expected no stats drift after merging, got %+v", drift)
+	}
+
+	// Running it again finds nothing to do
+	changes, err = pagesRepo.RehashPages(ctx, normalize, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes on a second run, got %+v", changes)
+	}
+}
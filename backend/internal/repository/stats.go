@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+// domainSQL extracts the domain of the page aliased p from its normalized URL, matching url.Domain.
+const domainSQL = `COALESCE((regexp_match(p.normalized_url, '^[a-z]+://(?:[^/?#]*@)?([^/?#]+)'))[1], '')`
+
+// actualPageStatsSQL computes every page's stats from scratch, the slow way, as
+// (page_id, rating_count, score_sum, score_histogram) rows. The stored columns on pages must always match it.
+const actualPageStatsSQL = `
+	SELECT g.page_id,
+		SUM(g.n)::int AS rating_count,
+		SUM(g.n * g.score)::bigint AS score_sum,
+		array_agg(g.n ORDER BY g.score)::int[] AS score_histogram
+	FROM (
+		SELECT p.id AS page_id, s.score, COUNT(r.id)::int AS n
+		FROM pages p
+		CROSS JOIN generate_series(1, 10) AS s(score)
+		LEFT JOIN ratings r ON r.page_id = p.id AND r.score = s.score
+		GROUP BY p.id, s.score
+	) g
+	GROUP BY g.page_id`
+
+// pageStatsFromHistogram derives the page stats from a pages.score_histogram value. Nil means no ratings.
+func pageStatsFromHistogram(counts []int) *models.PageStats {
+	var histogram [models.MaxScore]int
+	copy(histogram[:], counts)
+	return models.NewPageStats(histogram)
+}
+
+// lockPage locks the page's row until the end of tx.
+// Every rating change takes this lock first, so that changes to the same page can't interleave
+// and the page's stored stats stay exact.
+func lockPage(ctx context.Context, tx pgx.Tx, pageID int64) error {
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM pages WHERE id = $1 FOR UPDATE`, pageID); err != nil {
+		return fmt.Errorf("failed to lock page: %w", err)
+	}
+	return nil
+}
+
+// updatePageStats applies a rating change to the page's stored stats: oldScore is taken out, newScore is added.
+// Pass nil for oldScore when a rating is created, and nil for newScore when one is deleted.
+// The caller must hold the page's lock.
+func updatePageStats(ctx context.Context, tx pgx.Tx, pageID int64, oldScore *int, newScore *int) error {
+	_, err := tx.Exec(ctx,
+		`UPDATE pages SET
+			rating_count = rating_count + (CASE WHEN $3::int IS NULL THEN 0 ELSE 1 END) - (CASE WHEN $2::int IS NULL THEN 0 ELSE 1 END),
+			score_sum = score_sum + COALESCE($3::int, 0) - COALESCE($2::int, 0),
+			updated_at = NOW(),
+			score_histogram = ARRAY(
+				SELECT h.n + (CASE WHEN h.score = $3::int THEN 1 ELSE 0 END) - (CASE WHEN h.score = $2::int THEN 1 ELSE 0 END)
+				FROM unnest(score_histogram) WITH ORDINALITY AS h(n, score)
+				ORDER BY h.score
+			)
+		WHERE id = $1`,
+		pageID, oldScore, newScore)
+	if err != nil {
+		return fmt.Errorf("failed to update page stats: %w", err)
+	}
+	return nil
+}
+
+// RecomputePageStats recomputes every page's stored stats from its ratings and returns the pages whose
+// stored stats were wrong. With dryRun, it only reports them. Rating changes wait while it runs.
+func (r *PagesRepository) RecomputePageStats(ctx context.Context, dryRun bool) ([]models.PageStatsDrift, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer rollback(ctx, tx)
+
+	// SHARE mode blocks writers but not readers, so checks keep working
+	if _, err := tx.Exec(ctx, `LOCK TABLE ratings IN SHARE MODE`); err != nil {
+		return nil, fmt.Errorf("failed to lock ratings: %w", err)
+	}
+
+	rows, err := tx.Query(ctx,
+		`SELECT p.id AS page_id,
+			p.normalized_url,
+			p.rating_count AS stored_rating_count,
+			a.rating_count AS actual_rating_count,
+			p.score_sum AS stored_score_sum,
+			a.score_sum AS actual_score_sum,
+			p.score_histogram AS stored_histogram,
+			a.score_histogram AS actual_histogram
+		FROM pages p
+		INNER JOIN (`+actualPageStatsSQL+`) a ON a.page_id = p.id
+		WHERE (p.rating_count, p.score_sum, p.score_histogram) IS DISTINCT FROM (a.rating_count, a.score_sum, a.score_histogram)
+		ORDER BY p.id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find page stats drift: %w", err)
+	}
+	drift, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.PageStatsDrift])
+	if err != nil {
+		return nil, fmt.Errorf("failed to find page stats drift: %w", err)
+	}
+
+	if dryRun || len(drift) == 0 {
+		return drift, nil
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE pages p SET
+			rating_count = a.rating_count,
+			score_sum = a.score_sum,
+			score_histogram = a.score_histogram,
+			updated_at = NOW()
+		FROM (`+actualPageStatsSQL+`) a
+		WHERE a.page_id = p.id
+			AND (p.rating_count, p.score_sum, p.score_histogram) IS DISTINCT FROM (a.rating_count, a.score_sum, a.score_histogram)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fix page stats: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return drift, nil
+}
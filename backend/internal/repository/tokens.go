@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vdavid/web-annotator/backend/internal/db"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+// ErrTokenNotFound is returned when revoking a token that doesn't exist, is revoked, or belongs to someone else.
+var ErrTokenNotFound = errors.New("token not found")
+
+// TokensRepository handles database operations for personal API tokens.
+type TokensRepository struct {
+	pool *db.Pool
+}
+
+// NewTokensRepository creates a new tokens repository.
+func NewTokensRepository(pool *db.Pool) *TokensRepository {
+	return &TokensRepository{pool: pool}
+}
+
+// CreateToken stores a new token for a user and returns it with its generated fields filled in.
+func (r *TokensRepository) CreateToken(ctx context.Context, userID string, name string, tokenPrefix string, tokenHash string, scopes []string) (*models.APIToken, error) {
+	token := models.APIToken{
+		UserID:      userID,
+		Name:        name,
+		TokenPrefix: tokenPrefix,
+		TokenHash:   tokenHash,
+		Scopes:      scopes,
+	}
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO api_tokens (user_id, name, token_prefix, token_hash, scopes)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING id, created_at`,
+		userID, name, tokenPrefix, tokenHash, scopes).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// ListTokens returns a user's active tokens, newest first.
+func (r *TokensRepository) ListTokens(ctx context.Context, userID string) ([]models.APIToken, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, user_id, name, token_prefix, token_hash, scopes, created_at, last_used_at, revoked_at
+		FROM api_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC, id DESC`,
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	tokens, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.APIToken])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// RevokeToken revokes one of the user's tokens. It returns ErrTokenNotFound if the user has no such active token.
+func (r *TokensRepository) RevokeToken(ctx context.Context, userID string, tokenID int64) error {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE api_tokens SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`,
+		tokenID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTokenNotFound
+	}
+
+	return nil
+}
+
+// UseToken looks up an active token by hash for authentication and records that it was used.
+// It only updates last_used_at if it's more than a minute old, which saves a write on every request
+// for scripts that make many calls in a row. It returns nil for unknown and revoked tokens.
+func (r *TokensRepository) UseToken(ctx context.Context, tokenHash string) (*models.APIToken, error) {
+	rows, err := r.pool.Query(ctx,
+		`WITH token AS (
+			SELECT id, user_id, name, token_prefix, token_hash, scopes, created_at, last_used_at, revoked_at
+			FROM api_tokens
+			WHERE token_hash = $1 AND revoked_at IS NULL
+		), touched AS (
+			UPDATE api_tokens SET last_used_at = NOW()
+			WHERE id = (SELECT id FROM token)
+			  AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+		)
+		SELECT * FROM token`,
+		tokenHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up token: %w", err)
+	}
+
+	token, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[models.APIToken])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up token: %w", err)
+	}
+
+	return &token, nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/vdavid/web-annotator/backend/internal/db"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
 )
 
 // UsersRepository handles database operations for users.
@@ -40,3 +41,38 @@ func (r *UsersRepository) GetOrCreateUser(ctx context.Context, userID string) er
 
 	return nil
 }
+
+// UpsertUser ensures a user exists with the given ID, and sets their username if it changed.
+func (r *UsersRepository) UpsertUser(ctx context.Context, userID string, username string) error {
+	_, err := r.pool.Exec(ctx,
+		`INSERT INTO users (id, username)
+		 VALUES ($1, $2)
+		 ON CONFLICT (id) DO UPDATE SET username = EXCLUDED.username
+		 WHERE users.username IS DISTINCT FROM EXCLUDED.username`,
+		userID, username)
+	if err != nil {
+		return fmt.Errorf("failed to upsert user: %w", err)
+	}
+
+	return nil
+}
+
+// UpsertExternalUser ensures a user exists for an identity from the auth provider and returns their ID.
+// New users get a UUID derived from externalID, so the ID is stable even if the row is recreated.
+// For existing users, it refreshes the username and email if the provider reports new ones.
+func (r *UsersRepository) UpsertExternalUser(ctx context.Context, externalID string, username string, email *string) (string, error) {
+	var userID string
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO users (id, username, external_id, email)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (external_id) DO UPDATE SET
+			username = EXCLUDED.username,
+			email = EXCLUDED.email
+		 RETURNING id`,
+		utils.NameUUID(externalID), username, externalID, email).Scan(&userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to upsert external user: %w", err)
+	}
+
+	return userID, nil
+}
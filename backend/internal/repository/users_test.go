@@ -0,0 +1,29 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/testutil"
+)
+
+func TestUsersRepository_UpsertUser(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	repo := repository.NewUsersRepository(pool)
+	ctx := context.Background()
+	userID := "123e4567-e89b-12d3-a456-426614174000"
+
+	for _, name := range []string{"alice", "Alice Liddell"} {
+		if err := repo.UpsertUser(ctx, userID, name); err != nil {
+			t.Fatal(err)
+		}
+		var username string
+		if err := pool.QueryRow(ctx, `SELECT username FROM users WHERE id = $1`, userID).Scan(&username); err != nil {
+			t.Fatal(err)
+		}
+		if username != name {
+			t.Errorf("username = %q, want %q", username, name)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vdavid/web-annotator/backend/internal/db"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+// ErrWebhookNotFound is returned when a webhook subscription doesn't exist or belongs to someone else.
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// WebhooksRepository handles database operations for webhook subscriptions and their delivery outbox.
+type WebhooksRepository struct {
+	pool *db.Pool
+}
+
+// NewWebhooksRepository creates a new webhooks repository.
+func NewWebhooksRepository(pool *db.Pool) *WebhooksRepository {
+	return &WebhooksRepository{pool: pool}
+}
+
+// CreateSubscription stores a new webhook subscription for a user and returns it with its generated fields filled in.
+func (r *WebhooksRepository) CreateSubscription(ctx context.Context, userID string, url string, secret string, events []string) (*models.WebhookSubscription, error) {
+	subscription := models.WebhookSubscription{
+		UserID: userID,
+		URL:    url,
+		Secret: secret,
+		Events: events,
+	}
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO webhook_subscriptions (user_id, url, secret, events)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING id, created_at`,
+		userID, url, secret, events).Scan(&subscription.ID, &subscription.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return &subscription, nil
+}
+
+// ListSubscriptions returns a user's webhook subscriptions, newest first.
+func (r *WebhooksRepository) ListSubscriptions(ctx context.Context, userID string) ([]models.WebhookSubscription, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, user_id, url, secret, events, created_at
+		FROM webhook_subscriptions
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC`,
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	subscriptions, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.WebhookSubscription])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteSubscription deletes one of the user's webhook subscriptions, along with its undelivered events.
+// It returns ErrWebhookNotFound if the user has no such subscription.
+func (r *WebhooksRepository) DeleteSubscription(ctx context.Context, userID string, subscriptionID int64) error {
+	tag, err := r.pool.Exec(ctx,
+		`DELETE FROM webhook_subscriptions WHERE id = $1 AND user_id = $2`,
+		subscriptionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// ListDeadDeliveries returns the user's deliveries that failed for good, newest first.
+func (r *WebhooksRepository) ListDeadDeliveries(ctx context.Context, userID string, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT d.id, d.subscription_id, s.url, d.event_type, d.payload, d.status, d.attempts,
+			d.next_attempt_at, d.last_error, d.created_at, d.delivered_at
+		FROM webhook_deliveries d
+		INNER JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE s.user_id = $1 AND d.status = 'dead'
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT $2`,
+		userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead webhook deliveries: %w", err)
+	}
+
+	deliveries, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.WebhookDelivery])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// ClaimDueDeliveries picks up to limit pending deliveries that are due, oldest first, and counts an attempt for each.
+// It also moves their next attempt lease into the future, so other workers skip them while this one sends them.
+// If the worker dies mid-send, they become due again after the lease.
+func (r *WebhooksRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.PendingWebhookDelivery, error) {
+	rows, err := r.pool.Query(ctx,
+		`WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE webhook_deliveries d SET
+				attempts = d.attempts + 1,
+				next_attempt_at = NOW() + $2::interval
+			FROM due, webhook_subscriptions s
+			WHERE d.id = due.id AND s.id = d.subscription_id
+			RETURNING d.id, d.subscription_id, s.url, d.event_type, d.payload, d.status, d.attempts,
+				d.next_attempt_at, d.last_error, d.created_at, d.delivered_at, s.secret
+		)
+		SELECT * FROM claimed ORDER BY id`,
+		limit, lease)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+
+	deliveries, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.PendingWebhookDelivery])
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// MarkDelivered records that the receiver accepted a delivery.
+func (r *WebhooksRepository) MarkDelivered(ctx context.Context, deliveryID int64) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE webhook_deliveries SET status = 'delivered', delivered_at = NOW(), last_error = NULL
+		WHERE id = $1`,
+		deliveryID)
+	if err != nil {
+		return fmt.Errorf("failed to mark webhook delivered: %w", err)
+	}
+	return nil
+}
+
+// DeleteDelivered deletes deliveries that were delivered longer than olderThan ago, and returns how many.
+func (r *WebhooksRepository) DeleteDelivered(ctx context.Context, olderThan time.Duration) (int64, error) {
+	tag, err := r.pool.Exec(ctx,
+		`DELETE FROM webhook_deliveries WHERE status = 'delivered' AND delivered_at < NOW() - $1::interval`,
+		olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete delivered webhooks: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// MarkFailed records a failed attempt and schedules the next one after retryIn.
+func (r *WebhooksRepository) MarkFailed(ctx context.Context, deliveryID int64, reason string, retryIn time.Duration) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE webhook_deliveries SET next_attempt_at = NOW() + $3::interval, last_error = $2
+		WHERE id = $1`,
+		deliveryID, reason, retryIn)
+	if err != nil {
+		return fmt.Errorf("failed to mark webhook failed: %w", err)
+	}
+	return nil
+}
+
+// MarkDead records a failed last attempt, which moves the delivery to the dead-letter view.
+func (r *WebhooksRepository) MarkDead(ctx context.Context, deliveryID int64, reason string) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE webhook_deliveries SET status = 'dead', last_error = $2
+		WHERE id = $1`,
+		deliveryID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to mark webhook dead: %w", err)
+	}
+	return nil
+}
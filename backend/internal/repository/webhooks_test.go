@@ -0,0 +1,125 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+	"github.com/vdavid/web-annotator/backend/internal/repository"
+	"github.com/vdavid/web-annotator/backend/internal/testutil"
+	"github.com/vdavid/web-annotator/backend/internal/utils"
+)
+
+func TestWebhooksRepository_Outbox(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	pagesRepo := repository.NewPagesRepository(pool)
+	ratingsRepo := repository.NewRatingsRepository(pool)
+	usersRepo := repository.NewUsersRepository(pool)
+	webhooksRepo := repository.NewWebhooksRepository(pool)
+	ctx := context.Background()
+
+	owner, other := utils.NameUUID("owner"), utils.NameUUID("other")
+	for _, userID := range []string{owner, other} {
+		if err := usersRepo.GetOrCreateUser(ctx, userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	subscription, err := webhooksRepo.CreateSubscription(ctx, owner, "https://example.com/hook", "whsec_test",
+		[]string{models.WebhookEventRatingCreated, models.WebhookEventRatingDeleted})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pageURL := "https://example.com/article"
+	pageID, err := pagesRepo.GetOrCreatePage(ctx, pageURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment := "Great read"
+	if err := ratingsRepo.UpsertRating(ctx, pageID, owner, 8, &comment); err != nil {
+		t.Fatal(err)
+	}
+	if err := ratingsRepo.UpsertRating(ctx, pageID, owner, 9, nil); err != nil { // Not subscribed to updates
+		t.Fatal(err)
+	}
+	if err := ratingsRepo.UpsertRating(ctx, pageID, other, 5, nil); err != nil { // Someone else's rating
+		t.Fatal(err)
+	}
+	if _, err := ratingsRepo.DeleteRating(ctx, utils.HashURL(pageURL), owner); err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := webhooksRepo.ClaimDueDeliveries(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 2 {
+		t.Fatalf("Expected 2 deliveries, got %d: %+v", len(claimed), claimed)
+	}
+	created, deleted := claimed[0], claimed[1]
+	if created.EventType != models.WebhookEventRatingCreated || deleted.EventType != models.WebhookEventRatingDeleted {
+		t.Fatalf("Expected created then deleted, got %s and %s", created.EventType, deleted.EventType)
+	}
+	if created.SubscriptionID != subscription.ID || created.URL != subscription.URL || created.Secret != "whsec_test" || created.Attempts != 1 {
+		t.Errorf("Unexpected claimed delivery: %+v", created)
+	}
+	var data map[string]any
+	if err := json.Unmarshal(created.Payload, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data["url"] != pageURL || data["user_id"] != owner || data["score"] != 8.0 || data["comment"] != comment {
+		t.Errorf("Unexpected payload: %s", created.Payload)
+	}
+
+	// Claimed deliveries are leased, so they can't be claimed twice
+	again, err := webhooksRepo.ClaimDueDeliveries(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 0 {
+		t.Errorf("Expected leased deliveries to be skipped, got %d", len(again))
+	}
+
+	if err := webhooksRepo.MarkDelivered(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := webhooksRepo.MarkFailed(ctx, deleted.ID, "HTTP 500", 0); err != nil {
+		t.Fatal(err)
+	}
+	if pruned, err := webhooksRepo.DeleteDelivered(ctx, time.Hour); err != nil || pruned != 0 {
+		t.Errorf("Expected a fresh delivery to be kept, got %d deleted (err: %v)", pruned, err)
+	}
+	if pruned, err := webhooksRepo.DeleteDelivered(ctx, -time.Hour); err != nil || pruned != 1 {
+		t.Errorf("Expected only the delivered event to be deleted, got %d (err: %v)", pruned, err)
+	}
+	retried, err := webhooksRepo.ClaimDueDeliveries(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retried) != 1 || retried[0].ID != deleted.ID || retried[0].Attempts != 2 {
+		t.Fatalf("Expected the failed delivery to be retried, got %+v", retried)
+	}
+
+	if err := webhooksRepo.MarkDead(ctx, deleted.ID, "HTTP 500"); err != nil {
+		t.Fatal(err)
+	}
+	dead, err := webhooksRepo.ListDeadDeliveries(ctx, owner, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].ID != deleted.ID || dead[0].LastError == nil || *dead[0].LastError != "HTTP 500" {
+		t.Errorf("Expected the dead delivery in the dead-letter view, got %+v", dead)
+	}
+	if others, err := webhooksRepo.ListDeadDeliveries(ctx, other, 10); err != nil || len(others) != 0 {
+		t.Errorf("Expected no dead deliveries for another user, got %+v (err: %v)", others, err)
+	}
+
+	if err := webhooksRepo.DeleteSubscription(ctx, other, subscription.ID); err != repository.ErrWebhookNotFound {
+		t.Errorf("Expected ErrWebhookNotFound for someone else's webhook, got %v", err)
+	}
+	if err := webhooksRepo.DeleteSubscription(ctx, owner, subscription.ID); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,120 @@
+// Package scoring keeps the priors for the Bayesian adjusted score up to date.
+package scoring
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+// TotalsSource loads the rating totals the priors are computed from.
+type TotalsSource interface {
+	GetScoreTotals(ctx context.Context) ([]models.ScoreTotals, error)
+}
+
+// Options configures the priors.
+type Options struct {
+	Weight    int     // How many imaginary ratings at the prior score each page starts with
+	PerDomain bool    // Compute a prior for each domain, not just a global one
+	Fallback  float64 // The global prior until there are ratings to compute it from
+}
+
+// Priors computes the adjusted score priors from all ratings and caches them.
+// Computing them needs a full scan of the ratings, so it's done periodically by Run, never per request.
+// It's safe for concurrent use.
+type Priors struct {
+	source  TotalsSource
+	options Options
+
+	mu     sync.RWMutex
+	priors models.ScorePriors
+}
+
+// NewPriors creates priors that start out at the fallback until the first Refresh.
+func NewPriors(source TotalsSource, options Options) *Priors {
+	return &Priors{
+		source:  source,
+		options: options,
+		priors:  models.ScorePriors{Weight: options.Weight, Global: options.Fallback, UpdatedAt: time.Now()},
+	}
+}
+
+// Current returns the latest priors.
+func (p *Priors) Current() models.ScorePriors {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.priors
+}
+
+// Refresh recomputes the priors from the source.
+func (p *Priors) Refresh(ctx context.Context) error {
+	totals, err := p.source.GetScoreTotals(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to refresh score priors: %w", err)
+	}
+
+	priors := Compute(totals, p.options)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	priors.UpdatedAt = p.priors.UpdatedAt
+	if priors.Global != p.priors.Global || !maps.Equal(priors.Domains, p.priors.Domains) {
+		priors.UpdatedAt = time.Now()
+	}
+	p.priors = priors
+	return nil
+}
+
+// Run refreshes the priors right away and then at every interval, until ctx is done.
+// Failed refreshes are logged, and the previous priors stay in use.
+func (p *Priors) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Error: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Compute builds priors from per-domain rating totals.
+// The global prior is the average of all ratings. Each domain's prior is the domain's own average,
+// pulled towards the global prior the same way a page's adjusted score is, so a domain with a handful
+// of ratings doesn't get an extreme prior.
+func Compute(totals []models.ScoreTotals, options Options) models.ScorePriors {
+	priors := models.ScorePriors{Weight: options.Weight, Global: options.Fallback}
+
+	var sum, count int64
+	for _, t := range totals {
+		sum += t.ScoreSum
+		count += t.RatingCount
+	}
+	if count == 0 {
+		return priors
+	}
+	priors.Global = float64(sum) / float64(count)
+
+	if !options.PerDomain {
+		return priors
+	}
+	weight := float64(options.Weight)
+	priors.Domains = make(map[string]float64, len(totals))
+	for _, t := range totals {
+		if t.RatingCount == 0 {
+			continue
+		}
+		priors.Domains[t.Domain] = (weight*priors.Global + float64(t.ScoreSum)) / (weight + float64(t.RatingCount))
+	}
+	return priors
+}
@@ -0,0 +1,139 @@
+package scoring
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+type fakeTotalsSource struct {
+	totals []models.ScoreTotals
+	err    error
+}
+
+func (f *fakeTotalsSource) GetScoreTotals(context.Context) ([]models.ScoreTotals, error) {
+	return f.totals, f.err
+}
+
+func TestCompute(t *testing.T) {
+	totals := []models.ScoreTotals{
+		{Domain: "good.example", ScoreSum: 90, RatingCount: 10},
+		{Domain: "bad.example", ScoreSum: 30, RatingCount: 10},
+	}
+
+	tests := []struct {
+		name            string
+		totals          []models.ScoreTotals
+		options         Options
+		expectedGlobal  float64
+		expectedDomains map[string]float64
+	}{
+		{
+			name:           "no ratings falls back",
+			totals:         nil,
+			options:        Options{Weight: 10, Fallback: 5.5},
+			expectedGlobal: 5.5,
+		},
+		{
+			name:           "global only",
+			totals:         totals,
+			options:        Options{Weight: 10, Fallback: 5.5},
+			expectedGlobal: 6,
+		},
+		{
+			name:           "per domain, pulled towards global",
+			totals:         totals,
+			options:        Options{Weight: 10, PerDomain: true, Fallback: 5.5},
+			expectedGlobal: 6,
+			expectedDomains: map[string]float64{
+				"good.example": 7.5, // (10 * 6 + 90) / 20
+				"bad.example":  4.5, // (10 * 6 + 30) / 20
+			},
+		},
+		{
+			name:           "per domain with zero weight is the raw domain average",
+			totals:         totals,
+			options:        Options{Weight: 0, PerDomain: true},
+			expectedGlobal: 6,
+			expectedDomains: map[string]float64{
+				"good.example": 9,
+				"bad.example":  3,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			priors := Compute(tt.totals, tt.options)
+
+			if priors.Weight != tt.options.Weight {
+				t.Errorf("Weight = %d, want %d", priors.Weight, tt.options.Weight)
+			}
+			if priors.Global != tt.expectedGlobal {
+				t.Errorf("Global = %v, want %v", priors.Global, tt.expectedGlobal)
+			}
+			if len(priors.Domains) != len(tt.expectedDomains) {
+				t.Fatalf("Domains = %v, want %v", priors.Domains, tt.expectedDomains)
+			}
+			for domain, want := range tt.expectedDomains {
+				if got := priors.For(domain); math.Abs(got-want) > 1e-9 {
+					t.Errorf("For(%q) = %v, want %v", domain, got, want)
+				}
+			}
+			if got := priors.For("unknown.example"); got != tt.expectedGlobal {
+				t.Errorf("For(unknown) = %v, want the global prior %v", got, tt.expectedGlobal)
+			}
+		})
+	}
+}
+
+func TestAdjustedScore_RanksConfidenceOverLuck(t *testing.T) {
+	priors := models.ScorePriors{Weight: 10, Global: 6}
+	lucky := priors.AdjustedScore("example.com", &models.PageStats{TotalRatings: 1, AverageScore: 10})
+	popular := priors.AdjustedScore("example.com", &models.PageStats{TotalRatings: 200, AverageScore: 9.2})
+
+	if lucky >= popular {
+		t.Errorf("Expected one 10/10 (%v) to rank below 200 ratings averaging 9.2 (%v)", lucky, popular)
+	}
+	if got := priors.AdjustedScore("example.com", &models.PageStats{}); got != 0 {
+		t.Errorf("Expected 0 for a page without ratings, got %v", got)
+	}
+}
+
+func TestPriors_Refresh(t *testing.T) {
+	source := &fakeTotalsSource{}
+	priors := NewPriors(source, Options{Weight: 5, Fallback: 5.5})
+
+	if got := priors.Current().Global; got != 5.5 {
+		t.Errorf("Expected the fallback before the first refresh, got %v", got)
+	}
+
+	source.totals = []models.ScoreTotals{{Domain: "example.com", ScoreSum: 80, RatingCount: 10}}
+	if err := priors.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := priors.Current().Global; got != 8 {
+		t.Errorf("Expected 8 after refresh, got %v", got)
+	}
+
+	// Only a change moves UpdatedAt
+	updatedAt := priors.Current().UpdatedAt
+	if err := priors.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := priors.Current().UpdatedAt; !got.Equal(updatedAt) {
+		t.Errorf("Expected UpdatedAt to stay %v when nothing changed, got %v", updatedAt, got)
+	}
+
+	// A failed refresh keeps the previous priors
+	source.err = errors.New("database is down")
+	if err := priors.Refresh(context.Background()); err == nil {
+		t.Error("Expected an error")
+	}
+	if got := priors.Current().Global; got != 8 {
+		t.Errorf("Expected the previous priors to stay, got %v", got)
+	}
+}
@@ -0,0 +1,50 @@
+// Package testutil holds helpers shared by tests across packages.
+package testutil
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/vdavid/web-annotator/backend/internal/config"
+	"github.com/vdavid/web-annotator/backend/internal/db"
+	"github.com/vdavid/web-annotator/backend/migrations"
+)
+
+// TestDatabaseURLEnvKey names the env var that points integration tests to a throwaway Postgres database.
+// Tests that need it are skipped when it's not set. The database gets wiped, so don't point it at real data.
+const TestDatabaseURLEnvKey = "TEST_DATABASE_URL"
+
+// NewTestPool connects to the test database, resets it, and applies every embedded migration.
+// It skips the test if TEST_DATABASE_URL isn't set, and closes the pool when the test ends.
+func NewTestPool(tb testing.TB) *db.Pool {
+	tb.Helper()
+
+	connStr := os.Getenv(TestDatabaseURLEnvKey)
+	if connStr == "" {
+		tb.Skipf("%s not set, skipping integration test", TestDatabaseURLEnvKey)
+	}
+
+	ctx := context.Background()
+	cfg := config.Default().Database
+	cfg.URL = connStr
+	pool, err := db.NewPool(ctx, cfg)
+	if err != nil {
+		tb.Fatalf("failed to connect to test database: %v", err)
+	}
+	tb.Cleanup(pool.Close)
+
+	if _, err := pool.Exec(ctx, `DROP SCHEMA public CASCADE; CREATE SCHEMA public`); err != nil {
+		tb.Fatalf("failed to reset test database: %v", err)
+	}
+
+	migrator, err := db.NewMigrator(pool, migrations.FS)
+	if err != nil {
+		tb.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		tb.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	return pool
+}
@@ -2,6 +2,7 @@ package url
 
 import (
 	"errors"
+	"fmt"
 	"net/url"
 	"sort"
 	"strings"
@@ -9,10 +10,30 @@ import (
 
 var ErrInvalidURL = errors.New("invalid URL: missing scheme or host")
 
-// Normalize normalizes a URL according to the project's ruleset.
-// It converts the URL to lowercase, forces https, removes www prefix,
-// removes trailing slashes, removes fragments, and filters/sorts query parameters.
+// Normalize normalizes a URL with the current rules, see SetRules.
 func Normalize(rawURL string) (string, error) {
+	return CurrentRules().Normalize(rawURL)
+}
+
+// maxRewrites is how many times path rewrites run on a URL. Rules whose rewrites feed each other in a loop stop here.
+const maxRewrites = 10
+
+// Normalize normalizes a URL according to the rules.
+// It applies the syntax-based normalization of RFC 3986: it lowercases the host and converts international ones to
+// ASCII, removes default ports, normalizes percent-encoding, and resolves dot segments. Then it forces https, removes
+// the www prefix, user info, duplicate and trailing slashes, and the fragment, and filters and sorts query parameters.
+// The rules decide which parameters go, and how paths get rewritten.
+// Paths, parameter names, and values keep their case, since many sites treat them as case-sensitive,
+// unless the host's rules opt in to folding them.
+// Links from wrappers that carry their target, like Google's /url?q=, are replaced by that target first.
+// Hosts whose rules move them to another host, like youtu.be, get that host's rules after their path rewrites.
+// Normalizing a normalized URL doesn't change it.
+func (r *Rules) Normalize(rawURL string) (string, error) {
+	return r.normalize(rawURL, 0)
+}
+
+// normalize is Normalize for a link inside depth wrappers.
+func (r *Rules) normalize(rawURL string, depth int) (string, error) {
 	parsed, err := url.Parse(rawURL)
 	if err != nil {
 		return "", err
@@ -23,68 +44,98 @@ func Normalize(rawURL string) (string, error) {
 		return "", ErrInvalidURL
 	}
 
-	// Force https scheme
-	parsed.Scheme = "https"
-
-	// Lowercase host and remove www prefix
-	host := strings.ToLower(parsed.Host)
-	if strings.HasPrefix(host, "www.") {
-		host = host[4:]
+	// Normalize the host, then force https and remove user info
+	host := normalizeHost(parsed.Hostname(), parsed.Port(), parsed.Scheme)
+	if host == "" {
+		return "", ErrInvalidURL
 	}
+	parsed.Scheme = "https"
 	parsed.Host = host
-
-	// Remove trailing slashes from path
-	path := strings.TrimSuffix(parsed.Path, "/")
-	parsed.Path = path
+	parsed.User = nil
+	rules := r.forHost(parsed.Hostname())
 
 	// Remove fragment
 	parsed.Fragment = ""
+	parsed.RawFragment = ""
 
-	// Filter and sort query parameters
+	path := normalizePath(parsed.EscapedPath())
 	query := parsed.Query()
-	filtered := make(url.Values)
-	keysToRemove := map[string]bool{
-		"gclid":  true,
-		"fbclid": true,
-		"ref":    true,
-		"source": true,
-		"share":  true,
+
+	// Unwrap wrapped links
+	if rules.unwrap != nil && depth < maxUnwrapDepth && rules.unwrap.matches(path) {
+		// A target that doesn't normalize, like one with a bad host, leaves the wrapper as the page
+		if target := rules.unwrap.target(query, path); target != "" {
+			if normalized, err := r.normalize(target, depth+1); err == nil {
+				return normalized, nil
+			}
+		}
 	}
 
-	for key, values := range query {
-		lowerKey := strings.ToLower(key)
-		// Skip utm_* parameters
-		if strings.HasPrefix(lowerKey, "utm_") {
-			continue
+	// Rewrite and fold the path
+	for range maxRewrites {
+		rewritten, rewrittenQuery, matched := rules.rewritePath(path)
+		if !matched {
+			break
 		}
-		// Skip keys in removal list
-		if keysToRemove[lowerKey] {
-			continue
+		path = normalizePath(rewritten)
+		if rewrittenQuery != "" {
+			extra, err := url.ParseQuery(rewrittenQuery)
+			if err != nil {
+				return "", fmt.Errorf("invalid query from path rewrite: %w", err)
+			}
+			for key, values := range extra {
+				query[key] = append(query[key], values...)
+			}
 		}
-		// Keep other parameters
-		filtered[lowerKey] = values
+	}
+	if rules.lowercasePath {
+		// Every non-ASCII byte is escaped by now, so this only touches letters, and fixes the escapes' hex digits after
+		path = normalizeEscapes(strings.ToLower(path))
+	}
+	parsed.RawPath = path
+	if parsed.Path, err = url.PathUnescape(path); err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
 	}
 
-	// Sort keys alphabetically
-	keys := make([]string, 0, len(filtered))
-	for k := range filtered {
-		keys = append(keys, k)
+	// Move to another host, whose rules then apply from the start, like youtu.be links to youtube.com
+	if rules.rewriteHost != "" && depth < maxUnwrapDepth {
+		parsed.Host = rules.rewriteHost
+		parsed.RawQuery = query.Encode()
+		return r.normalize(parsed.String(), depth+1)
 	}
-	sort.Strings(keys)
 
-	// Rebuild query string with sorted keys
-	if len(keys) > 0 {
-		sortedQuery := make(url.Values)
-		for _, k := range keys {
-			sortedQuery[k] = filtered[k]
+	// Go through keys in order, so keys that only differ in case merge the same way every time
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	filtered := make(url.Values)
+	for _, key := range keys {
+		lowerKey := strings.ToLower(key)
+		if rules.dropsParam(lowerKey) {
+			continue
+		}
+		name := key
+		if rules.lowercaseParamNames {
+			name = lowerKey
 		}
-		parsed.RawQuery = sortedQuery.Encode()
-	} else {
-		parsed.RawQuery = ""
+		filtered[name] = append(filtered[name], query[key]...)
 	}
 
-	result := parsed.String()
-	// Remove trailing "?" if present (can happen with empty query strings)
-	result = strings.TrimSuffix(result, "?")
-	return result, nil
+	// Rebuild query string, which Encode sorts by key. An empty one goes, "?" and all.
+	parsed.RawQuery = filtered.Encode()
+	parsed.ForceQuery = false
+
+	return parsed.String(), nil
+}
+
+// Domain returns the host of a normalized URL, with the port if it has one.
+// Per-domain features group pages by it, so it must stay in sync with the domain expression in the repository's SQL.
+func Domain(normalizedURL string) string {
+	parsed, err := url.Parse(normalizedURL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
 }
@@ -0,0 +1,306 @@
+package url
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RulesFormatVersion is the rules file format this code understands.
+const RulesFormatVersion = 1
+
+//go:embed rules.yaml
+var defaultRulesFile []byte
+
+// Rules tell Normalize which query parameters to drop and how to rewrite URLs, globally and per host.
+// Load them with LoadRules. A Rules is read-only once loaded, so it's safe to use from many goroutines.
+type Rules struct {
+	version int
+	global  *ruleSet
+	hosts   map[string]*ruleSet // By host without "www.", or "*." and a domain. Each already includes the global rules.
+}
+
+// ruleSet is the compiled form of a rule set from the file.
+type ruleSet struct {
+	removeParams        map[string]bool // Lowercased
+	removeParamPrefixes []string        // Lowercased
+	allowParams         map[string]bool // Lowercased
+	keepParams          map[string]bool // Lowercased. Nil means keep everything that isn't removed.
+	rewritePaths        []pathRewrite
+	lowercasePath       bool
+	lowercaseParamNames bool
+	unwrap              *unwrapRule // Nil unless the host is a link wrapper
+	rewriteHost         string      // The host URLs move to, which then gets its own rules. Empty to stay.
+}
+
+// pathRewrite is one regular expression replacement on a URL path.
+type pathRewrite struct {
+	pattern *regexp.Regexp
+	replace string
+}
+
+// unwrapRule tells how to find where a link wrapper, like a newsletter's click tracker, leads.
+type unwrapRule struct {
+	paths    []*regexp.Regexp // Paths that are wrapped links. Empty means every path.
+	params   []string         // Lowercased. Query parameters that can hold the target URL.
+	jwtClaim string           // If set, the target is this claim of a JWT in one of params or in the last path segment
+	resolve  bool             // Follow the HTTP redirect when the target isn't in the link itself
+}
+
+// rulesFile is the YAML layout of a rules file. See rules.yaml for what each field does.
+type rulesFile struct {
+	Version int                    `yaml:"version"`
+	Global  ruleSetFile            `yaml:"global"`
+	Hosts   map[string]ruleSetFile `yaml:"hosts"`
+}
+
+type ruleSetFile struct {
+	RemoveParams        []string          `yaml:"remove_params"`
+	RemoveParamPrefixes []string          `yaml:"remove_param_prefixes"`
+	AllowParams         []string          `yaml:"allow_params"`
+	KeepParams          *[]string         `yaml:"keep_params"` // A pointer, since an empty list means "drop everything"
+	RewritePaths        []pathRewriteFile `yaml:"rewrite_paths"`
+	LowercasePath       *bool             `yaml:"lowercase_path"`
+	LowercaseParamNames *bool             `yaml:"lowercase_param_names"`
+	Unwrap              *unwrapFile       `yaml:"unwrap"`
+	RewriteHost         string            `yaml:"rewrite_host"`
+}
+
+type unwrapFile struct {
+	Paths    []string `yaml:"paths"`
+	Params   []string `yaml:"params"`
+	JWTClaim string   `yaml:"jwt_claim"`
+	Resolve  bool     `yaml:"resolve"`
+}
+
+type pathRewriteFile struct {
+	Pattern string `yaml:"pattern"`
+	Replace string `yaml:"replace"`
+}
+
+// LoadRules reads and checks a rules file. It reports every problem at once.
+func LoadRules(r io.Reader) (*Rules, error) {
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	var file rulesFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid URL rules file: %w", err)
+	}
+
+	var errs []error
+	if file.Version < 1 {
+		errs = append(errs, errors.New("version must be at least 1"))
+	}
+	if len(file.Global.AllowParams) > 0 || file.Global.KeepParams != nil || len(file.Global.RewritePaths) > 0 || file.Global.Unwrap != nil || file.Global.RewriteHost != "" {
+		errs = append(errs, errors.New("global rules can't have allow_params, keep_params, rewrite_paths, unwrap, or rewrite_host"))
+	}
+
+	global, err := compileRuleSet(&ruleSet{}, file.Global)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("global: %w", err))
+	}
+	rules := &Rules{version: file.Version, global: global, hosts: make(map[string]*ruleSet, len(file.Hosts))}
+	for host, hostFile := range file.Hosts {
+		if !isRuleHost(strings.TrimPrefix(host, "*.")) {
+			errs = append(errs, fmt.Errorf("host %q must be a lowercase host name without www., optionally after *.", host))
+			continue
+		}
+		if hostFile.RewriteHost != "" && !isRuleHost(hostFile.RewriteHost) {
+			errs = append(errs, fmt.Errorf("host %s: rewrite_host %q must be a lowercase host name without www.", host, hostFile.RewriteHost))
+			continue
+		}
+		hostRules, err := compileRuleSet(global, hostFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("host %s: %w", host, err))
+			continue
+		}
+		rules.hosts[host] = hostRules
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid URL rules file: %w", err)
+	}
+	return rules, nil
+}
+
+// isRuleHost reports whether host is fit for a rule: lowercase, and without "www." or anything but the name.
+func isRuleHost(host string) bool {
+	return host != "" && host == strings.ToLower(host) && !strings.HasPrefix(host, "www.") && !strings.ContainsAny(host, "/:*")
+}
+
+// LoadRulesFile reads and checks the rules file at path.
+func LoadRulesFile(path string) (*Rules, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open URL rules file: %w", err)
+	}
+	defer file.Close()
+	return LoadRules(file)
+}
+
+// compileRuleSet builds a rule set from the file's, on top of base.
+func compileRuleSet(base *ruleSet, file ruleSetFile) (*ruleSet, error) {
+	set := &ruleSet{
+		removeParams:        lowerSet(base.removeParams, file.RemoveParams),
+		removeParamPrefixes: append(slices.Clone(base.removeParamPrefixes), lowerAll(file.RemoveParamPrefixes)...),
+		allowParams:         lowerSet(nil, file.AllowParams),
+		lowercasePath:       base.lowercasePath,
+		lowercaseParamNames: base.lowercaseParamNames,
+		rewriteHost:         file.RewriteHost,
+	}
+	if file.KeepParams != nil {
+		set.keepParams = lowerSet(nil, *file.KeepParams)
+	}
+	if file.LowercasePath != nil {
+		set.lowercasePath = *file.LowercasePath
+	}
+	if file.LowercaseParamNames != nil {
+		set.lowercaseParamNames = *file.LowercaseParamNames
+	}
+	for _, rewrite := range file.RewritePaths {
+		pattern, err := regexp.Compile(rewrite.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path pattern: %w", err)
+		}
+		set.rewritePaths = append(set.rewritePaths, pathRewrite{pattern: pattern, replace: rewrite.Replace})
+	}
+	if file.Unwrap != nil {
+		unwrap, err := compileUnwrapRule(*file.Unwrap)
+		if err != nil {
+			return nil, err
+		}
+		set.unwrap = unwrap
+	}
+	return set, nil
+}
+
+// compileUnwrapRule builds an unwrap rule from the file's.
+func compileUnwrapRule(file unwrapFile) (*unwrapRule, error) {
+	if len(file.Params) == 0 && file.JWTClaim == "" && !file.Resolve {
+		return nil, errors.New("unwrap needs params, jwt_claim, or resolve")
+	}
+	rule := &unwrapRule{params: lowerAll(file.Params), jwtClaim: file.JWTClaim, resolve: file.Resolve}
+	for _, path := range file.Paths {
+		pattern, err := regexp.Compile(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unwrap path: %w", err)
+		}
+		rule.paths = append(rule.paths, pattern)
+	}
+	return rule, nil
+}
+
+// Version returns the rules file's version.
+func (r *Rules) Version() int {
+	return r.version
+}
+
+// forHost returns the rules for a host without "www.": its own if it has any, else the ones of the closest domain
+// that covers its subdomains with "*.", else the global ones.
+func (r *Rules) forHost(host string) *ruleSet {
+	if set, ok := r.hosts[host]; ok {
+		return set
+	}
+	for candidate := host; candidate != ""; {
+		if set, ok := r.hosts["*."+candidate]; ok {
+			return set
+		}
+		_, parent, found := strings.Cut(candidate, ".")
+		if !found {
+			break
+		}
+		candidate = parent
+	}
+	return r.global
+}
+
+// dropsParam reports whether the rules drop a query parameter. name must be lowercase.
+func (s *ruleSet) dropsParam(name string) bool {
+	if s.keepParams != nil {
+		return !s.keepParams[name]
+	}
+	if s.allowParams[name] {
+		return false
+	}
+	if s.removeParams[name] {
+		return true
+	}
+	for _, prefix := range s.removeParamPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// rewritePath applies the first matching path rewrite. It returns the new path, any query the rewrite added,
+// and whether a rewrite matched.
+func (s *ruleSet) rewritePath(path string) (string, string, bool) {
+	for _, rewrite := range s.rewritePaths {
+		if rewrite.pattern.MatchString(path) {
+			path, query, _ := strings.Cut(rewrite.pattern.ReplaceAllString(path, rewrite.replace), "?")
+			return path, query, true
+		}
+	}
+	return path, "", false
+}
+
+// defaultRules are the embedded rules.yaml. A broken embedded file is a bug, so it panics right at startup.
+var defaultRules = func() *Rules {
+	rules, err := LoadRules(strings.NewReader(string(defaultRulesFile)))
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}()
+
+// currentRules are what Normalize uses.
+var currentRules atomic.Pointer[Rules]
+
+func init() {
+	currentRules.Store(defaultRules)
+}
+
+// DefaultRules returns the rules built into the server.
+func DefaultRules() *Rules {
+	return defaultRules
+}
+
+// SetRules makes Normalize use the given rules from now on, for example ones loaded from a custom file at startup.
+func SetRules(rules *Rules) {
+	currentRules.Store(rules)
+}
+
+// CurrentRules returns the rules Normalize uses.
+func CurrentRules() *Rules {
+	return currentRules.Load()
+}
+
+// lowerSet adds lowercased values to a copy of base.
+func lowerSet(base map[string]bool, values []string) map[string]bool {
+	set := make(map[string]bool, len(base)+len(values))
+	for value := range base {
+		set[value] = true
+	}
+	for _, value := range values {
+		set[strings.ToLower(value)] = true
+	}
+	return set
+}
+
+// lowerAll lowercases every value.
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(value)
+	}
+	return lowered
+}
@@ -0,0 +1,182 @@
+package url
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		// Global trackers
+		{"mailchimp", "https://example.com/article?mc_cid=abc&mc_eid=def", "https://example.com/article"},
+		{"instagram", "https://example.com/article?igshid=abc&igsh=def", "https://example.com/article"},
+		{"alibaba spm", "https://example.com/article?spm=a2g0o.home&id=1", "https://example.com/article?id=1"},
+		{"matomo prefixes", "https://example.com/article?pk_campaign=x&mtm_source=y", "https://example.com/article"},
+		{"hubspot", "https://example.com/article?_hsenc=x&_hsmi=y&hsa_acc=z", "https://example.com/article"},
+		{"trackers are case-insensitive", "https://example.com/article?UTM_Source=x&FBCLID=y", "https://example.com/article"},
+		{"si is only a tracker on some hosts", "https://example.com/article?si=1", "https://example.com/article?si=1"},
+
+		// youtube.com
+		{"youtube keeps only v", "https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=PL1&t=42s&si=abc", "https://youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"youtube mobile", "https://m.youtube.com/watch?v=dQw4w9WgXcQ&feature=share", "https://youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"youtube rules skip other subdomains", "https://music.youtube.com/watch?v=dQw4w9WgXcQ&list=PL1", "https://music.youtube.com/watch?list=PL1&v=dQw4w9WgXcQ"},
+		{"youtube shorts", "https://youtube.com/shorts/dQw4w9WgXcQ?si=abc", "https://youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"youtube live", "https://youtube.com/live/dQw4w9WgXcQ/", "https://youtube.com/watch?v=dQw4w9WgXcQ"},
+
+		// youtu.be
+		{"youtu.be moves to youtube.com", "https://youtu.be/dQw4w9WgXcQ?si=abc&t=42", "https://youtube.com/watch?v=dQw4w9WgXcQ"},
+
+		// news.ycombinator.com
+		{"hacker news keeps only id", "https://news.ycombinator.com/item?id=123&goto=news&p=2", "https://news.ycombinator.com/item?id=123"},
+
+		// x.com and twitter.com
+		{"x drops everything", "https://x.com/jane/status/123?s=20&t=abc", "https://x.com/jane/status/123"},
+		{"twitter drops everything", "https://mobile.twitter.com/jane/status/123?s=20", "https://mobile.twitter.com/jane/status/123"},
+
+		// open.spotify.com
+		{"spotify drops si", "https://open.spotify.com/episode/abc?si=def", "https://open.spotify.com/episode/abc"},
+
+		// github.com
+		{"github keeps ref", "https://github.com/org/repo/blob/main/README.md?ref=v2&utm_source=x", "https://github.com/org/repo/blob/main/README.md?ref=v2"},
+
+		// substack.com
+		{"substack share params", "https://jane.substack.com/p/my-post?r=abc&s=r&triedRedirect=true", "https://jane.substack.com/p/my-post"},
+
+		// medium.com
+		{"medium amp", "https://medium.com/amp/p/abc123", "https://medium.com/p/abc123"},
+		{"medium source", "https://medium.com/@jane/my-post-abc123?source=rss", "https://medium.com/@jane/my-post-abc123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DefaultRules().Normalize(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("Normalize() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// testRulesFile exercises every feature of the rules engine.
+const testRulesFile = `
+version: 3
+global:
+  remove_params: [ref]
+  remove_param_prefixes: [utm_]
+  lowercase_param_names: true
+hosts:
+  '*.example.com':
+    remove_params: [session]
+  example.org:
+    remove_params: [session]
+  old.example.org:
+    rewrite_paths:
+      - pattern: ^/p/(\d+)$
+        replace: /posts?id=$1
+    rewrite_host: example.org
+  docs.example.com:
+    lowercase_path: true
+    lowercase_param_names: false
+  shop.example.com:
+    keep_params: [ID]
+  blog.example.com:
+    allow_params: [ref]
+    rewrite_paths:
+      - pattern: ^/(\d{4})/(\d{2})/(.+)$
+        replace: /posts/$3?year=$1
+      - pattern: ^/posts/(.+)\.html$
+        replace: /posts/$1
+`
+
+func TestRules_Normalize(t *testing.T) {
+	rules, err := LoadRules(strings.NewReader(testRulesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules.Version() != 3 {
+		t.Errorf("Version() = %d, want 3", rules.Version())
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"global rules", "https://other.com/a?REF=x&utm_medium=y&Page=2", "https://other.com/a?page=2"},
+		{"host rules add to global ones", "https://example.com/a?session=1&ref=x&id=2", "https://example.com/a?id=2"},
+		{"wildcard host rules cover subdomains", "https://api.example.com/a?session=1", "https://api.example.com/a"},
+		{"other host rules only cover their host", "https://api.example.org/a?session=1", "https://api.example.org/a?session=1"},
+		{"rewrite_host moves to the other host's rules", "https://old.example.org/p/42?session=1&utm_source=x", "https://example.org/posts?id=42"},
+		{"most specific host wins", "https://docs.example.com/Guide/Intro?Lang=EN&utm_source=x", "https://docs.example.com/guide/intro?Lang=EN"},
+		{"keep list is case-insensitive", "https://shop.example.com/item?id=5&color=red&utm_source=x", "https://shop.example.com/item?id=5"},
+		{"allow list beats global rules", "https://blog.example.com/a?ref=x&utm_source=y", "https://blog.example.com/a?ref=x"},
+		{"rewrites repeat and can add a query", "https://blog.example.com/2025/10/my-post.html/?b=1", "https://blog.example.com/posts/my-post?b=1&year=2025"},
+		{"merged names keep a stable order", "https://other.com/a?B=2&b=1&A=3", "https://other.com/a?a=3&b=2&b=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rules.Normalize(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("Normalize() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadRules_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{"missing version", "global: {}", "version"},
+		{"unknown field", "version: 1\nglobal:\n  remove_param: [ref]", "remove_param"},
+		{"global keep list", "version: 1\nglobal:\n  keep_params: [id]", "global rules can't"},
+		{"uppercase host", "version: 1\nhosts:\n  Example.com: {}", "lowercase host"},
+		{"www host", "version: 1\nhosts:\n  www.example.com: {}", "lowercase host"},
+		{"wildcard inside host", "version: 1\nhosts:\n  api.*.example.com: {}", "lowercase host"},
+		{"global rewrite_host", "version: 1\nglobal:\n  rewrite_host: example.com", "global rules can't"},
+		{"bad rewrite_host", "version: 1\nhosts:\n  example.com:\n    rewrite_host: https://example.org/", "rewrite_host"},
+		{"global unwrap", "version: 1\nglobal:\n  unwrap: {resolve: true}", "global rules can't"},
+		{"empty unwrap", "version: 1\nhosts:\n  example.com:\n    unwrap: {paths: ['^/go']}", "unwrap needs"},
+		{"bad unwrap path", "version: 1\nhosts:\n  example.com:\n    unwrap: {paths: ['('], resolve: true}", "invalid unwrap path"},
+		{"bad pattern", "version: 1\nhosts:\n  example.com:\n    rewrite_paths: [{pattern: '(', replace: x}]", "invalid path pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadRules(strings.NewReader(tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadRules() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetRules(t *testing.T) {
+	rules, err := LoadRules(strings.NewReader("version: 2\nglobal:\n  remove_params: [id]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetRules(rules)
+	defer SetRules(DefaultRules())
+
+	got, err := Normalize("https://example.com/a?id=1&utm_source=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "https://example.com/a?utm_source=x" {
+		t.Errorf("Expected Normalize to use the new rules, got %v", got)
+	}
+}
@@ -0,0 +1,142 @@
+package url
+
+import (
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// idnaProfile converts international host names to their ASCII (punycode) form. It's the lookup profile, but it
+// allows underscores, which some real hosts have.
+var idnaProfile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.StrictDomainName(false))
+
+// hostNamePattern matches a host name in ASCII form. Every label must be non-empty.
+var hostNamePattern = regexp.MustCompile(`^[a-z0-9_-]+(\.[a-z0-9_-]+)*$`)
+
+// normalizeHost returns the normalized host, with the port if it isn't the default one for scheme.
+// Host names get lowercased and converted to ASCII, and lose "www." and a trailing dot. IP addresses get their
+// standard form. It returns "" if the host isn't valid.
+func normalizeHost(hostname string, port string, scheme string) string {
+	var host string
+	if addr, err := netip.ParseAddr(hostname); err == nil {
+		if addr.Zone() != "" {
+			return ""
+		}
+		host = addr.String()
+		if addr.Is6() {
+			host = "[" + host + "]"
+		}
+	} else {
+		// IDNA would turn bytes that aren't UTF-8 into replacement characters, which then wouldn't normalize the same
+		if !utf8.ValidString(hostname) {
+			return ""
+		}
+		ascii, err := idnaProfile.ToASCII(strings.TrimSuffix(hostname, "."))
+		if err != nil || !hostNamePattern.MatchString(ascii) {
+			return ""
+		}
+		host = strings.TrimPrefix(ascii, "www.")
+	}
+
+	if port == "" {
+		return host
+	}
+	number, err := strconv.Atoi(port)
+	if err != nil || number > 65535 {
+		return ""
+	}
+	// The result is always https, so 443 is its default, as was 80 for http
+	if number == 443 || (number == 80 && scheme == "http") {
+		return host
+	}
+	return host + ":" + strconv.Itoa(number)
+}
+
+// normalizePath returns an escaped path in its normal form: percent-encoding normalized, dot segments and
+// duplicate slashes removed, and no trailing slash.
+func normalizePath(escapedPath string) string {
+	return strings.TrimSuffix(removeDotSegments(normalizeEscapes(escapedPath)), "/")
+}
+
+// normalizeEscapes decodes percent-encoded unreserved characters, like %7E to ~, uppercases the hex digits of the
+// other escapes, and escapes every character that isn't allowed in a path, per RFC 3986.
+func normalizeEscapes(escaped string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	b.Grow(len(escaped))
+	for i := 0; i < len(escaped); i++ {
+		c := escaped[i]
+		if c == '%' && i+2 < len(escaped) && isHex(escaped[i+1]) && isHex(escaped[i+2]) {
+			c = unhex(escaped[i+1])<<4 | unhex(escaped[i+2])
+			i += 2
+			if isUnreserved(c) {
+				b.WriteByte(c)
+				continue
+			}
+		} else if isUnreserved(c) || strings.IndexByte("!$&'()*+,;=:@/", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&15])
+	}
+	return b.String()
+}
+
+// removeDotSegments resolves "." and ".." segments in an absolute path, per RFC 3986 section 5.2.4.
+// It also drops empty segments, so duplicate slashes collapse to one.
+func removeDotSegments(path string) string {
+	if path == "" {
+		return ""
+	}
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	kept := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+		case "..":
+			if len(kept) > 0 {
+				kept = kept[:len(kept)-1]
+			}
+		case "":
+			if !last {
+				continue
+			}
+			kept = append(kept, segment)
+		default:
+			kept = append(kept, segment)
+			continue
+		}
+		// A path ending in a dot segment is a directory, like one ending in a slash
+		if last && segment != "" {
+			kept = append(kept, "")
+		}
+	}
+	return "/" + strings.Join(kept, "/")
+}
+
+// isUnreserved reports whether c is an unreserved character, which never needs escaping.
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,133 @@
+package url
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// maxUnwrapDepth is how many wrappers around a link Normalize and NormalizeResolving take off. Real links rarely
+// have more than two, like a Google result for a t.co link. Moves to another host with rewrite_host count as one.
+const maxUnwrapDepth = 5
+
+// ErrNotResolved is returned by Resolvers that don't know where a link leads without sending a request.
+var ErrNotResolved = errors.New("link isn't resolved yet")
+
+// Resolver follows a link's HTTP redirect, one hop at a time. See NormalizeResolving.
+type Resolver interface {
+	Resolve(ctx context.Context, link string) (string, error)
+}
+
+// NormalizeResolving normalizes a URL like Normalize does. If the result is a link wrapper whose rules say to
+// resolve it, like t.co, it then follows its redirects with resolver and normalizes where they lead.
+// If resolving fails, it logs why and returns the wrapper's normalized URL, so the link still gets a page of its own.
+// It does the same without logging when the resolver returns ErrNotResolved.
+// A nil resolver makes it the same as Normalize.
+func NormalizeResolving(ctx context.Context, rawURL string, resolver Resolver) (string, error) {
+	rules := CurrentRules()
+	normalized, err := rules.Normalize(rawURL)
+	if err != nil || resolver == nil {
+		return normalized, err
+	}
+
+	for range maxUnwrapDepth {
+		if !rules.resolves(normalized) {
+			break
+		}
+		target, err := resolver.Resolve(ctx, normalized)
+		if err != nil {
+			if !errors.Is(err, ErrNotResolved) {
+				log.Printf("Error: failed to resolve %s: %v", normalized, err)
+			}
+			break
+		}
+		resolved, err := rules.Normalize(target)
+		if err != nil {
+			log.Printf("Error: %s redirects to an invalid URL: %v", normalized, err)
+			break
+		}
+		normalized = resolved
+	}
+	return normalized, nil
+}
+
+// resolves reports whether the rules say to follow the redirect of a normalized URL.
+func (r *Rules) resolves(normalizedURL string) bool {
+	parsed, err := url.Parse(normalizedURL)
+	if err != nil {
+		return false
+	}
+	unwrap := r.forHost(parsed.Hostname()).unwrap
+	return unwrap != nil && unwrap.resolve && unwrap.matches(parsed.EscapedPath())
+}
+
+// matches reports whether a normalized path is a wrapped link.
+func (u *unwrapRule) matches(path string) bool {
+	if len(u.paths) == 0 {
+		return true
+	}
+	for _, pattern := range u.paths {
+		if pattern.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// target returns the URL a wrapped link with the given query and normalized path leads to, if the link itself says.
+// Otherwise, it returns "".
+// It tries params in the rule's order, and names that only differ in case in sorted order, so a link with more than
+// one target always unwraps to the same one.
+func (u *unwrapRule) target(query url.Values, linkPath string) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var candidates []string
+	for _, param := range u.params {
+		for _, key := range keys {
+			if strings.ToLower(key) == param {
+				candidates = append(candidates, query[key]...)
+			}
+		}
+	}
+	if u.jwtClaim != "" {
+		candidates = append(candidates, path.Base(linkPath))
+	}
+
+	for _, candidate := range candidates {
+		if u.jwtClaim != "" {
+			candidate = jwtClaim(candidate, u.jwtClaim)
+		}
+		if target, err := url.Parse(candidate); err == nil && (target.Scheme == "http" || target.Scheme == "https") && target.Host != "" {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// jwtClaim returns a string claim from a JWT's payload, or "" if it's not there. It doesn't check the signature:
+// the worst a forged token can do is name another URL, which the user could have visited directly anyway.
+func jwtClaim(token string, claim string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return ""
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	value, _ := claims[claim].(string)
+	return value
+}
@@ -0,0 +1,169 @@
+package url
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"testing"
+)
+
+// testJWT builds an unsigned JWT with the given claims JSON, like the ones in Substack's email links.
+func testJWT(claims string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + encode([]byte(claims)) + ".c2lnbmF0dXJl"
+}
+
+func TestNormalize_Unwrap(t *testing.T) {
+	target := "https://www.example.com/article?id=1&utm_source=newsletter"
+	escaped := url.QueryEscape(target)
+	token := testJWT(`{"e":"` + target + `","p":123,"s":456}`)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"google", "https://www.google.com/url?sa=t&q=" + escaped + "&ved=abc", "https://example.com/article?id=1"},
+		{"google url param", "https://google.com/url?url=" + escaped, "https://example.com/article?id=1"},
+		{"facebook", "https://l.facebook.com/l.php?u=" + escaped + "&h=AT0", "https://example.com/article?id=1"},
+		{"linkedin", "https://www.linkedin.com/safety/go?url=" + escaped + "&trk=flagship", "https://example.com/article?id=1"},
+		{"reddit", "https://out.reddit.com/t3_abc?url=" + escaped + "&token=x", "https://example.com/article?id=1"},
+		{"outlook", "https://eur01.safelinks.protection.outlook.com/?url=" + escaped + "&data=05", "https://example.com/article?id=1"},
+		{"substack path", "https://substack.com/redirect/2/" + token, "https://example.com/article?id=1"},
+		{"substack param", "https://jane.substack.com/redirect/abc-123?j=" + token, "https://example.com/article?id=1"},
+		{"nested", "https://google.com/url?q=" + url.QueryEscape("https://l.facebook.com/l.php?u="+escaped), "https://example.com/article?id=1"},
+		{"parameter names ignore case", "https://google.com/url?Q=" + escaped, "https://example.com/article?id=1"},
+
+		{"other paths on a wrapper host", "https://google.com/search?q=" + escaped, "https://google.com/search?q=" + escaped},
+		{"target isn't a URL", "https://google.com/url?q=hello", "https://google.com/url?q=hello"},
+		{"target isn't http", "https://google.com/url?q=javascript:alert(1)", "https://google.com/url?q=javascript%3Aalert%281%29"},
+		{"target that doesn't normalize", "https://google.com/url?q=" + url.QueryEscape("https://%80/a"), "https://google.com/url?q=https%3A%2F%2F%2580%2Fa"},
+		{"undecodable token", "https://substack.com/redirect/2/not-a-token", "https://substack.com/redirect/2/not-a-token"},
+		{"target only behind a redirect", "https://t.co/AbC123", "https://t.co/AbC123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("Normalize() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestNormalize_UnwrapIsStable checks that a link with more than one target always unwraps to the same one, since
+// query parameters come out of a map in random order.
+func TestNormalize_UnwrapIsStable(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"names that differ in case", "https://google.com/url?q=https://a.com/x&Q=https://b.com/y", "https://b.com/y"},
+		{"params in the rule's order", "https://google.com/url?url=https://b.com/y&q=https://a.com/x", "https://a.com/x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 50 {
+				got, err := Normalize(tt.input)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.expected {
+					t.Fatalf("Normalize() = %v, want %v", got, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+// mockResolver resolves links from a map and records what it was asked.
+type mockResolver struct {
+	redirects map[string]string
+	calls     []string
+}
+
+func (m *mockResolver) Resolve(_ context.Context, link string) (string, error) {
+	m.calls = append(m.calls, link)
+	target, ok := m.redirects[link]
+	if !ok {
+		return "", errors.New("no redirect")
+	}
+	return target, nil
+}
+
+func TestNormalizeResolving(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		redirects     map[string]string
+		expected      string
+		expectedCalls int
+	}{
+		{
+			name:          "resolves a shortener",
+			input:         "https://t.co/AbC123",
+			redirects:     map[string]string{"https://t.co/AbC123": "https://www.example.com/article?utm_source=twitter"},
+			expected:      "https://example.com/article",
+			expectedCalls: 1,
+		},
+		{
+			name:  "follows a chain of wrappers",
+			input: "http://t.co/AbC123",
+			redirects: map[string]string{
+				"https://t.co/AbC123": "https://lnkd.in/xyz",
+				"https://lnkd.in/xyz": "https://example.com/article",
+			},
+			expected:      "https://example.com/article",
+			expectedCalls: 2,
+		},
+		{
+			name:          "only resolves hosts whose rules say so",
+			input:         "https://example.com/article",
+			redirects:     map[string]string{"https://example.com/article": "https://example.com/other"},
+			expected:      "https://example.com/article",
+			expectedCalls: 0,
+		},
+		{
+			name:          "only resolves wrapped paths",
+			input:         "https://us1.list-manage.com/subscribe?u=1",
+			expected:      "https://us1.list-manage.com/subscribe?u=1",
+			expectedCalls: 0,
+		},
+		{
+			name:          "keeps the wrapper if resolving fails",
+			input:         "https://lnkd.in/broken",
+			expected:      "https://lnkd.in/broken",
+			expectedCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &mockResolver{redirects: tt.redirects}
+			got, err := NormalizeResolving(context.Background(), tt.input, resolver)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("NormalizeResolving() = %v, want %v", got, tt.expected)
+			}
+			if len(resolver.calls) != tt.expectedCalls {
+				t.Errorf("Expected %d resolve calls, got %v", tt.expectedCalls, resolver.calls)
+			}
+		})
+	}
+
+	t.Run("nil resolver", func(t *testing.T) {
+		got, err := NormalizeResolving(context.Background(), "https://t.co/AbC123", nil)
+		if err != nil || got != "https://t.co/AbC123" {
+			t.Errorf("NormalizeResolving() = %v, %v, want the normalized wrapper", got, err)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// APITokenPrefix starts every personal API token, so they're easy to recognize (also by secret scanners).
+const APITokenPrefix = "wa_"
+
+// apiTokenDisplayLength is how many characters of a token we keep in plain text for display.
+const apiTokenDisplayLength = len(APITokenPrefix) + 8
+
+// GenerateAPIToken creates a new random API token like "wa_3q2-7w...". It has 256 bits of entropy.
+func GenerateAPIToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return APITokenPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// IsAPIToken reports whether token looks like a personal API token rather than, say, a JWT.
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// HashAPIToken computes the SHA256 hash we store and look tokens up by.
+// A plain hash is enough here (no salt or slow KDF) because tokens are long and random.
+func HashAPIToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// APITokenDisplayPrefix returns the non-secret start of a token, shown in token lists.
+func APITokenDisplayPrefix(token string) string {
+	if len(token) < apiTokenDisplayLength {
+		return token
+	}
+	return token[:apiTokenDisplayLength]
+}
+
+// WebhookSecretPrefix starts every generated webhook signing secret.
+const WebhookSecretPrefix = "whsec_"
+
+// GenerateWebhookSecret creates a new random webhook signing secret like "whsec_3q2-7w...". It has 256 bits of entropy.
+func GenerateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return WebhookSecretPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// uuidPattern matches the canonical 8-4-4-4-12 hex form.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// userNamespace is the UUID namespace for user IDs derived from external identities.
+// Never change it: doing so would give every existing external user a new ID.
+var userNamespace = [16]byte{0x6b, 0xa7, 0xb8, 0x14, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+
+// IsUUID reports whether s is a UUID in canonical text form.
+func IsUUID(s string) bool {
+	return uuidPattern.MatchString(s)
+}
+
+// NameUUID derives a stable, version 5 (SHA-1, name-based) UUID from name.
+// The same name always maps to the same UUID, so it works for turning external
+// identities like a JWT "iss" + "sub" pair into IDs for the users table.
+func NameUUID(name string) string {
+	h := sha1.New()
+	h.Write(userNamespace[:])
+	h.Write([]byte(name))
+	sum := h.Sum(nil)
+
+	sum[6] = (sum[6] & 0x0f) | 0x50 // Version 5
+	sum[8] = (sum[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return strings.ToLower(fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16]))
+}
@@ -0,0 +1,236 @@
+// Package webhooks delivers rating events from the outbox to webhook subscribers.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/article"
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderEvent     = "X-WebAnnotator-Event"     // The event type, like "rating.created"
+	HeaderDelivery  = "X-WebAnnotator-Delivery"  // The delivery ID. Retries reuse it, so receivers can drop duplicates.
+	HeaderTimestamp = "X-WebAnnotator-Timestamp" // Unix seconds when this attempt was signed
+	HeaderSignature = "X-WebAnnotator-Signature" // "sha256=" followed by Sign's result
+)
+
+// batchSize is how many deliveries the worker claims at once.
+const batchSize = 20
+
+// maxReasonLength caps the failure reason we store.
+const maxReasonLength = 500
+
+// pruneInterval is how often the worker deletes old delivered events.
+const pruneInterval = time.Hour
+
+// Store is the outbox the worker reads deliveries from and records results in.
+type Store interface {
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.PendingWebhookDelivery, error)
+	MarkDelivered(ctx context.Context, deliveryID int64) error
+	MarkFailed(ctx context.Context, deliveryID int64, reason string, retryIn time.Duration) error
+	MarkDead(ctx context.Context, deliveryID int64, reason string) error
+	DeleteDelivered(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+// Options configures the worker.
+type Options struct {
+	Timeout       time.Duration // Per attempt
+	MaxAttempts   int           // After this many failed attempts, a delivery is dead-lettered
+	RetryDelay    time.Duration // Wait after the first failed attempt, doubled after each further one
+	MaxRetryDelay time.Duration // Cap for the doubling
+	Retention     time.Duration // How long delivered events are kept. Dead ones stay for the dead-letter view.
+}
+
+// Event is the JSON body of a delivery.
+type Event struct {
+	ID        int64           `json:"id"` // Same as the delivery ID header
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"` // For rating events: url, user_id, score, and comment. Score and comment are null for rating.deleted.
+}
+
+// Worker sends due deliveries from the outbox. Several workers, even on different servers, can share one outbox.
+type Worker struct {
+	store   Store
+	client  *http.Client
+	options Options
+	now     func() time.Time
+}
+
+// NewWorker creates a worker. It only connects to public addresses, so a subscription can't point it into our own
+// network. Redirects aren't followed: a receiver that moved has to be updated by its owner.
+func NewWorker(store Store, options Options) *Worker {
+	return newWorker(store, options, article.IsPublicAddress)
+}
+
+// newWorker creates a worker that may only connect to addresses allow accepts. Tests use it to reach httptest servers.
+func newWorker(store Store, options Options, allow func(netip.Addr) bool) *Worker {
+	return &Worker{
+		store: store,
+		client: &http.Client{
+			Transport: article.NewSafeTransport(options.Timeout, allow),
+			Timeout:   options.Timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		options: options,
+		now:     time.Now,
+	}
+}
+
+// Run delivers due events right away and then at every interval, until ctx is done.
+// Every pruneInterval, it also deletes delivered events older than the retention.
+// Errors are logged, and the deliveries involved are retried later.
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastPrune time.Time
+	for {
+		if w.now().Sub(lastPrune) >= pruneInterval {
+			lastPrune = w.now()
+			if deleted, err := w.store.DeleteDelivered(ctx, w.options.Retention); err != nil && ctx.Err() == nil {
+				log.Printf("Error: %v", err)
+			} else if deleted > 0 {
+				log.Printf("Deleted %d delivered webhook event(s)", deleted)
+			}
+		}
+
+		// Keep going while there's a backlog, rather than sending one batch per interval
+		for {
+			claimed, err := w.DeliverDue(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Error: %v", err)
+			}
+			if err != nil || claimed < batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue sends one batch of due deliveries and records how each went. It returns how many it claimed.
+func (w *Worker) DeliverDue(ctx context.Context) (int, error) {
+	// Sending the batch one by one can take up to batchSize timeouts, so the lease must outlast that
+	lease := w.options.Timeout*batchSize + time.Minute
+	deliveries, err := w.store.ClaimDueDeliveries(ctx, batchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		if err := w.deliver(ctx, delivery); err != nil {
+			reason := err.Error()
+			if len(reason) > maxReasonLength {
+				reason = reason[:maxReasonLength]
+			}
+			if delivery.Attempts >= w.options.MaxAttempts {
+				err = w.store.MarkDead(ctx, delivery.ID, reason)
+			} else {
+				err = w.store.MarkFailed(ctx, delivery.ID, reason, w.retryDelay(delivery.Attempts))
+			}
+		} else {
+			err = w.store.MarkDelivered(ctx, delivery.ID)
+		}
+		if err != nil {
+			return len(deliveries), err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// deliver makes one attempt at sending a delivery. Any 2xx response counts as accepted.
+func (w *Worker) deliver(ctx context.Context, delivery *models.PendingWebhookDelivery) error {
+	body, err := json.Marshal(Event{
+		ID:        delivery.ID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.CreatedAt,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	timestamp := strconv.FormatInt(w.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "WebAnnotator-Webhooks/1")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(delivery.Secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return requestError(err)
+	}
+	defer resp.Body.Close()
+	// Read a bit of the body so the connection can be reused, but don't let a receiver make us read forever
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// requestError describes why a request failed, without the details: the subscription's owner sees it in the
+// dead-letter view, and the addresses and ports in a dial error would tell them what's on our network.
+func requestError(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, article.ErrBlockedAddress):
+		return errors.New("receiver's address is not public")
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return errors.New("receiver timed out")
+	default:
+		return errors.New("couldn't reach the receiver")
+	}
+}
+
+// retryDelay returns how long to wait after the given number of failed attempts.
+func (w *Worker) retryDelay(attempts int) time.Duration {
+	delay := w.options.RetryDelay
+	for i := 1; i < attempts && delay < w.options.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, w.options.MaxRetryDelay)
+}
+
+// Sign computes the hex HMAC-SHA256 of timestamp + "." + body with the subscription's secret.
+// Receivers should compute the same from the timestamp header and the raw body, compare it to the signature header
+// in constant time, and reject old timestamps to prevent replays.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,248 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/vdavid/web-annotator/backend/internal/models"
+)
+
+// mockStore is an in-memory outbox that hands out its deliveries once and records what happened to them.
+type mockStore struct {
+	pending   []models.PendingWebhookDelivery
+	claimErr  error
+	delivered []int64
+	failed    map[int64]time.Duration
+	dead      map[int64]string
+	pruned    []time.Duration
+}
+
+func newMockStore(pending ...models.PendingWebhookDelivery) *mockStore {
+	return &mockStore{pending: pending, failed: map[int64]time.Duration{}, dead: map[int64]string{}}
+}
+
+func (m *mockStore) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.PendingWebhookDelivery, error) {
+	if m.claimErr != nil {
+		return nil, m.claimErr
+	}
+	claimed := m.pending[:min(limit, len(m.pending))]
+	m.pending = m.pending[len(claimed):]
+	for i := range claimed {
+		claimed[i].Attempts++
+	}
+	return claimed, nil
+}
+
+func (m *mockStore) MarkDelivered(ctx context.Context, deliveryID int64) error {
+	m.delivered = append(m.delivered, deliveryID)
+	return nil
+}
+
+func (m *mockStore) MarkFailed(ctx context.Context, deliveryID int64, reason string, retryIn time.Duration) error {
+	m.failed[deliveryID] = retryIn
+	return nil
+}
+
+func (m *mockStore) MarkDead(ctx context.Context, deliveryID int64, reason string) error {
+	m.dead[deliveryID] = reason
+	return nil
+}
+
+func (m *mockStore) DeleteDelivered(ctx context.Context, olderThan time.Duration) (int64, error) {
+	m.pruned = append(m.pruned, olderThan)
+	return 0, nil
+}
+
+func testOptions() Options {
+	return Options{Timeout: time.Second, MaxAttempts: 3, RetryDelay: time.Minute, MaxRetryDelay: 10 * time.Minute, Retention: 24 * time.Hour}
+}
+
+// newTestWorker creates a worker that may reach httptest servers.
+func newTestWorker(store Store) *Worker {
+	return newWorker(store, testOptions(), func(addr netip.Addr) bool { return addr.IsLoopback() })
+}
+
+func pendingDelivery(id int64, url string, attempts int) models.PendingWebhookDelivery {
+	return models.PendingWebhookDelivery{
+		WebhookDelivery: models.WebhookDelivery{
+			ID:        id,
+			URL:       url,
+			EventType: models.WebhookEventRatingCreated,
+			Payload:   json.RawMessage(`{"url": "https://example.com/article", "score": 8}`),
+			Attempts:  attempts,
+			CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		Secret: "s3cret",
+	}
+}
+
+func TestWorker_DeliversSignedEvents(t *testing.T) {
+	var received *http.Request
+	var receivedBody []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	store := newMockStore(pendingDelivery(7, receiver.URL, 0))
+	worker := newTestWorker(store)
+	worker.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	claimed, err := worker.DeliverDue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed != 1 || len(store.delivered) != 1 || store.delivered[0] != 7 {
+		t.Fatalf("Expected delivery 7 to be delivered, got claimed=%d delivered=%v", claimed, store.delivered)
+	}
+
+	if got := received.Header.Get(HeaderEvent); got != models.WebhookEventRatingCreated {
+		t.Errorf("Expected event header %q, got %q", models.WebhookEventRatingCreated, got)
+	}
+	if got := received.Header.Get(HeaderDelivery); got != "7" {
+		t.Errorf("Expected delivery header 7, got %q", got)
+	}
+	timestamp := received.Header.Get(HeaderTimestamp)
+	if timestamp != "1700000000" {
+		t.Errorf("Expected the signing time as timestamp, got %q", timestamp)
+	}
+	if got, want := received.Header.Get(HeaderSignature), "sha256="+Sign("s3cret", timestamp, receivedBody); got != want {
+		t.Errorf("Expected signature %q, got %q", want, got)
+	}
+
+	var event Event
+	if err := json.Unmarshal(receivedBody, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.ID != 7 || event.Type != models.WebhookEventRatingCreated || !json.Valid(event.Data) {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+}
+
+func TestWorker_RetriesWithBackoffThenDeadLetters(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	// Attempts as stored before the claim, which counts one more
+	store := newMockStore(
+		pendingDelivery(1, receiver.URL, 0),
+		pendingDelivery(2, receiver.URL, 1),
+		pendingDelivery(3, receiver.URL, 2),
+	)
+	worker := newTestWorker(store)
+
+	if _, err := worker.DeliverDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedRetries := map[int64]time.Duration{1: time.Minute, 2: 2 * time.Minute}
+	for id, expected := range expectedRetries {
+		if got := store.failed[id]; got != expected {
+			t.Errorf("Delivery %d: expected a retry in %s, got %s", id, expected, got)
+		}
+	}
+	if reason, ok := store.dead[3]; !ok || reason != "HTTP 503" {
+		t.Errorf("Expected delivery 3 to be dead-lettered with HTTP 503, got %q (dead: %v)", reason, ok)
+	}
+	if len(store.delivered) != 0 {
+		t.Errorf("Expected nothing delivered, got %v", store.delivered)
+	}
+}
+
+func TestWorker_DoesNotFollowRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected the redirect not to be followed")
+	}))
+	defer target.Close()
+	receiver := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer receiver.Close()
+
+	store := newMockStore(pendingDelivery(1, receiver.URL, 0))
+	if _, err := newTestWorker(store).DeliverDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.failed[1]; !ok {
+		t.Error("Expected a redirect to count as a failure")
+	}
+}
+
+func TestWorker_UnreachableReceiver(t *testing.T) {
+	receiver := httptest.NewServer(http.NotFoundHandler())
+	url := receiver.URL
+	receiver.Close()
+
+	store := newMockStore(pendingDelivery(1, url, 0), pendingDelivery(2, url, 2))
+	if _, err := newTestWorker(store).DeliverDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.failed[1]; !ok {
+		t.Error("Expected a connection error to count as a failure")
+	}
+	if reason := store.dead[2]; reason != "couldn't reach the receiver" {
+		t.Errorf("Expected a generic reason without the address, got %q", reason)
+	}
+}
+
+func TestWorker_RefusesPrivateReceivers(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no request to a loopback receiver")
+	}))
+	defer receiver.Close()
+
+	store := newMockStore(pendingDelivery(1, receiver.URL, 2))
+	if _, err := NewWorker(store, testOptions()).DeliverDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if reason := store.dead[1]; reason != "receiver's address is not public" {
+		t.Errorf("Expected delivery 1 to be dead-lettered as not public, got %q", reason)
+	}
+}
+
+func TestWorker_RunPrunesDeliveredEvents(t *testing.T) {
+	store := newMockStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	newTestWorker(store).Run(ctx, time.Hour)
+
+	if len(store.pruned) != 1 || store.pruned[0] != 24*time.Hour {
+		t.Errorf("Expected one prune with the retention, got %v", store.pruned)
+	}
+}
+
+func TestWorker_ClaimError(t *testing.T) {
+	store := newMockStore()
+	store.claimErr = errors.New("database is down")
+	if _, err := newTestWorker(store).DeliverDue(context.Background()); err == nil {
+		t.Error("Expected the claim error")
+	}
+}
+
+func TestWorker_RetryDelay(t *testing.T) {
+	worker := newTestWorker(newMockStore())
+	expected := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute}
+	for i, want := range expected {
+		attempts := i + 1
+		if got := worker.retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Computed with: printf '1700000000.{}' | openssl dgst -sha256 -hmac s3cret
+	const expected = "97926816e98fbb41ccb1673225ff29a2f35369099990e1b1561651e7bd097ebf"
+	if got := Sign("s3cret", "1700000000", []byte("{}")); got != expected {
+		t.Errorf("Sign() = %q, want %q", got, expected)
+	}
+}
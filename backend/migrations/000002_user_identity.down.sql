@@ -0,0 +1,5 @@
+ALTER TABLE users DROP COLUMN IF EXISTS email;
+ALTER TABLE users DROP COLUMN IF EXISTS external_id;
+
+COMMENT ON TABLE users IS 'Stores user accounts. Currently mocked for MVP.';
+COMMENT ON COLUMN users.username IS 'Display name for the user. Currently just "Test User" for MVP.';
@@ -0,0 +1,8 @@
+-- Link users to identities from the auth provider
+ALTER TABLE users ADD COLUMN external_id TEXT UNIQUE;
+ALTER TABLE users ADD COLUMN email VARCHAR(255);
+
+COMMENT ON TABLE users IS 'Stores user accounts. Rows are created on first contact by whichever auth mode is active.';
+COMMENT ON COLUMN users.username IS 'Display name for the user. Comes from the auth provider (for example, the Remote-User header), or "Test User" for users created in the X-User-ID dev mode.';
+COMMENT ON COLUMN users.external_id IS 'Identity at the auth provider, prefixed by the auth mode, for example "proxy|alice". NULL for users created by the X-User-ID dev mode or from JWTs, where the ID itself is derived from the token.';
+COMMENT ON COLUMN users.email IS 'Email address reported by the auth provider (for example, the Remote-Email header). NULL if the provider did not send one.';
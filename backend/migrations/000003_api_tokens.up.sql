@@ -0,0 +1,23 @@
+-- Create api_tokens table
+CREATE TABLE api_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    token_prefix VARCHAR(16) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+COMMENT ON TABLE api_tokens IS 'Personal API tokens that let scripts and integrations act as a user. The token itself is only shown once, on creation; we store its hash.';
+COMMENT ON COLUMN api_tokens.user_id IS 'The user the token acts as.';
+COMMENT ON COLUMN api_tokens.name IS 'User-chosen label, for example "Obsidian export".';
+COMMENT ON COLUMN api_tokens.token_prefix IS 'First few characters of the token, including the "wa_" prefix, so users can tell their tokens apart. Not secret.';
+COMMENT ON COLUMN api_tokens.token_hash IS 'SHA256 hash (hex) of the full token. Used for lookup on each request.';
+COMMENT ON COLUMN api_tokens.scopes IS 'What the token may do, for example {ratings:read,ratings:write}.';
+COMMENT ON COLUMN api_tokens.last_used_at IS 'When the token last authenticated a request, with about a minute of precision. NULL if it was never used.';
+COMMENT ON COLUMN api_tokens.revoked_at IS 'When the user revoked the token. NULL means the token is active.';
+
+CREATE INDEX idx_api_tokens_user_id ON api_tokens(user_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_rating_revisions_page_id;
+DROP INDEX IF EXISTS idx_rating_revisions_user_page;
+DROP TABLE IF EXISTS rating_revisions;
@@ -0,0 +1,27 @@
+-- Create rating_revisions table
+CREATE TABLE rating_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id),
+    page_id BIGINT NOT NULL REFERENCES pages(id),
+    action VARCHAR(16) NOT NULL CHECK (action IN ('created', 'updated', 'deleted')),
+    score INT CHECK (score >= 1 AND score <= 10),
+    comment TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE rating_revisions IS 'Append-only history of every change to a rating, written in the same transaction as the change. Keyed by user and page rather than rating ID, so the history survives deleting and re-rating.';
+COMMENT ON COLUMN rating_revisions.user_id IS 'Who made the change.';
+COMMENT ON COLUMN rating_revisions.page_id IS 'Which page the rating is for.';
+COMMENT ON COLUMN rating_revisions.action IS 'What happened: "created" (first rating, or re-rating after a delete), "updated", or "deleted".';
+COMMENT ON COLUMN rating_revisions.score IS 'The score after the change. NULL for "deleted".';
+COMMENT ON COLUMN rating_revisions.comment IS 'The comment after the change. NULL if there was no comment, or for "deleted".';
+COMMENT ON COLUMN rating_revisions.created_at IS 'When the change happened.';
+
+CREATE INDEX idx_rating_revisions_user_page ON rating_revisions(user_id, page_id, created_at);
+CREATE INDEX idx_rating_revisions_page_id ON rating_revisions(page_id);
+
+-- Backfill: existing ratings become their own first revision
+INSERT INTO rating_revisions (user_id, page_id, action, score, comment, created_at)
+SELECT user_id, page_id, 'created', score, comment, COALESCE(updated_at, created_at, NOW())
+FROM ratings
+WHERE user_id IS NOT NULL AND page_id IS NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE pages
+    DROP COLUMN IF EXISTS score_histogram,
+    DROP COLUMN IF EXISTS score_sum,
+    DROP COLUMN IF EXISTS rating_count;
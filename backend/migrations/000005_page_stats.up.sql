@@ -0,0 +1,31 @@
+-- Keep running totals on pages so reading a page's stats doesn't scan its ratings
+ALTER TABLE pages
+    ADD COLUMN rating_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN score_sum BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN score_histogram INT[] NOT NULL DEFAULT '{0,0,0,0,0,0,0,0,0,0}'
+        CHECK (array_length(score_histogram, 1) = 10);
+
+COMMENT ON COLUMN pages.rating_count IS 'Number of ratings for the page. Kept in sync with ratings in the same transaction as every rating change.';
+COMMENT ON COLUMN pages.score_sum IS 'Sum of all scores for the page. Kept in sync like rating_count.';
+COMMENT ON COLUMN pages.score_histogram IS 'Number of ratings per score: element 1 counts the 1s, element 10 the 10s. Kept in sync like rating_count.';
+
+-- Backfill from the existing ratings
+UPDATE pages p
+SET rating_count = actual.rating_count,
+    score_sum = actual.score_sum,
+    score_histogram = actual.score_histogram
+FROM (
+    SELECT g.page_id,
+        SUM(g.n)::int AS rating_count,
+        SUM(g.n * g.score)::bigint AS score_sum,
+        array_agg(g.n ORDER BY g.score)::int[] AS score_histogram
+    FROM (
+        SELECT p.id AS page_id, s.score, COUNT(r.id)::int AS n
+        FROM pages p
+        CROSS JOIN generate_series(1, 10) AS s(score)
+        LEFT JOIN ratings r ON r.page_id = p.id AND r.score = s.score
+        GROUP BY p.id, s.score
+    ) g
+    GROUP BY g.page_id
+) actual
+WHERE actual.page_id = p.id;
@@ -0,0 +1,43 @@
+-- Create webhook_subscriptions table
+CREATE TABLE webhook_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    events TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE webhook_subscriptions IS 'Endpoints that get an HTTP POST whenever their owner creates, updates, or deletes a rating.';
+COMMENT ON COLUMN webhook_subscriptions.user_id IS 'The owner. Only their own rating events are sent.';
+COMMENT ON COLUMN webhook_subscriptions.url IS 'Where events are POSTed. http or https.';
+COMMENT ON COLUMN webhook_subscriptions.secret IS 'Shared secret for the HMAC-SHA256 signature of each delivery. Stored as is, since signing needs it.';
+COMMENT ON COLUMN webhook_subscriptions.events IS 'Which events to send, for example {rating.created,rating.updated}.';
+
+CREATE INDEX idx_webhook_subscriptions_user_id ON webhook_subscriptions(user_id);
+
+-- Create webhook_deliveries table
+CREATE TABLE webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id BIGINT NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    event_type VARCHAR(32) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP
+);
+
+COMMENT ON TABLE webhook_deliveries IS 'Outbox of webhook events, one row per event and subscription. Written in the same transaction as the rating change, so no event is lost, then sent by the webhook worker.';
+COMMENT ON COLUMN webhook_deliveries.event_type IS 'For example "rating.created".';
+COMMENT ON COLUMN webhook_deliveries.payload IS 'The event data, sent as the "data" field of the request body.';
+COMMENT ON COLUMN webhook_deliveries.status IS '"pending" until the receiver accepts it, then "delivered". "dead" if every attempt failed; these make up the dead-letter view.';
+COMMENT ON COLUMN webhook_deliveries.attempts IS 'How many times we tried to send it.';
+COMMENT ON COLUMN webhook_deliveries.next_attempt_at IS 'When the worker may try next. Also pushed out while a worker is sending, so other replicas leave it alone.';
+COMMENT ON COLUMN webhook_deliveries.last_error IS 'Why the last attempt failed, for example "HTTP 503". NULL if no attempt failed.';
+COMMENT ON COLUMN webhook_deliveries.delivered_at IS 'When the receiver accepted it. NULL unless delivered.';
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_subscription_id ON webhook_deliveries(subscription_id, status);
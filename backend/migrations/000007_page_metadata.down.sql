@@ -0,0 +1,8 @@
+ALTER TABLE pages
+    DROP COLUMN IF EXISTS metadata_updated_at,
+    DROP COLUMN IF EXISTS headline,
+    DROP COLUMN IF EXISTS language,
+    DROP COLUMN IF EXISTS published_at,
+    DROP COLUMN IF EXISTS author,
+    DROP COLUMN IF EXISTS site_name,
+    DROP COLUMN IF EXISTS title;
@@ -0,0 +1,17 @@
+-- Add article metadata to pages
+ALTER TABLE pages
+    ADD COLUMN title TEXT,
+    ADD COLUMN site_name TEXT,
+    ADD COLUMN author TEXT,
+    ADD COLUMN published_at TIMESTAMP,
+    ADD COLUMN language VARCHAR(35),
+    ADD COLUMN headline TEXT,
+    ADD COLUMN metadata_updated_at TIMESTAMP;
+
+COMMENT ON COLUMN pages.title IS 'The og:title, or the document title if there is none. NULL if unknown.';
+COMMENT ON COLUMN pages.site_name IS 'The og:site_name, for example "The Guardian". NULL if unknown.';
+COMMENT ON COLUMN pages.author IS 'The article''s author, as the page names them. NULL if unknown.';
+COMMENT ON COLUMN pages.published_at IS 'When the article was published, in UTC. NULL if unknown.';
+COMMENT ON COLUMN pages.language IS 'The page''s language as a BCP 47 tag, for example "en" or "pt-BR". NULL if unknown.';
+COMMENT ON COLUMN pages.headline IS 'The JSON-LD headline, which is often cleaner than the title. NULL if unknown.';
+COMMENT ON COLUMN pages.metadata_updated_at IS 'When the metadata was first saved or last refreshed. Until it''s older than the refresh age, new metadata only fills in missing fields. NULL if there is none.';
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_pages_fetch_due;
+
+ALTER TABLE pages
+    DROP COLUMN IF EXISTS fetched_at,
+    DROP COLUMN IF EXISTS fetch_error,
+    DROP COLUMN IF EXISTS next_fetch_at,
+    DROP COLUMN IF EXISTS fetch_attempts,
+    DROP COLUMN IF EXISTS fetch_status,
+    DROP COLUMN IF EXISTS canonical_url,
+    DROP COLUMN IF EXISTS jsonld_types,
+    DROP COLUMN IF EXISTS og_type;
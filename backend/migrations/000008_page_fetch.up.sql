@@ -0,0 +1,21 @@
+-- Add what the fetcher reads from pages, and its queue state
+ALTER TABLE pages
+    ADD COLUMN og_type VARCHAR(255),
+    ADD COLUMN jsonld_types TEXT[],
+    ADD COLUMN canonical_url TEXT,
+    ADD COLUMN fetch_status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (fetch_status IN ('pending', 'fetched', 'failed')),
+    ADD COLUMN fetch_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN next_fetch_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    ADD COLUMN fetch_error TEXT,
+    ADD COLUMN fetched_at TIMESTAMP;
+
+COMMENT ON COLUMN pages.og_type IS 'The page''s og:type, lowercased, for example "article". NULL if it has none or we haven''t fetched it.';
+COMMENT ON COLUMN pages.jsonld_types IS 'Every JSON-LD @type on the page, for example {NewsArticle,BreadcrumbList}. NULL until fetched.';
+COMMENT ON COLUMN pages.canonical_url IS 'The page''s <link rel="canonical">, resolved to an absolute URL. Not normalized. NULL if it has none or we haven''t fetched it.';
+COMMENT ON COLUMN pages.fetch_status IS '"pending" until the fetcher reads the page, then "fetched". "failed" if every attempt failed.';
+COMMENT ON COLUMN pages.fetch_attempts IS 'How many times the fetcher tried to read the page.';
+COMMENT ON COLUMN pages.next_fetch_at IS 'When the fetcher may try next. Also pushed out while a fetcher is reading it, so other replicas leave it alone.';
+COMMENT ON COLUMN pages.fetch_error IS 'Why the last attempt failed, for example "HTTP 404". NULL if no attempt failed.';
+COMMENT ON COLUMN pages.fetched_at IS 'When the fetcher read the page. NULL unless fetched.';
+
+CREATE INDEX idx_pages_fetch_due ON pages(next_fetch_at) WHERE fetch_status = 'pending';
@@ -0,0 +1,11 @@
+-- Create url_redirects table
+CREATE TABLE url_redirects (
+    link TEXT PRIMARY KEY,
+    target TEXT NOT NULL,
+    resolved_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE url_redirects IS 'Cache of where link wrappers like t.co redirect to, so each link is only requested once. Only used when URL_RESOLVE_REDIRECTS is on.';
+COMMENT ON COLUMN url_redirects.link IS 'The normalized wrapper link.';
+COMMENT ON COLUMN url_redirects.target IS 'Where it redirects to, as the Location header said. Normalized when read, so rule changes apply to it.';
+COMMENT ON COLUMN url_redirects.resolved_at IS 'When we requested the link.';
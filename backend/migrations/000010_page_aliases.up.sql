@@ -0,0 +1,15 @@
+-- Create page_aliases table
+CREATE TABLE page_aliases (
+    url_hash VARCHAR(64) PRIMARY KEY,
+    normalized_url TEXT NOT NULL,
+    page_id BIGINT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE page_aliases IS 'Other URLs of a page, like its AMP, mobile, or print version. Looking a page up by URL hash follows them. A page merged into another one leaves its URL here.';
+COMMENT ON COLUMN page_aliases.url_hash IS 'SHA256 hash of the alias URL. Never also the url_hash of a row in pages.';
+COMMENT ON COLUMN page_aliases.normalized_url IS 'The alias URL, normalized.';
+COMMENT ON COLUMN page_aliases.page_id IS 'The page the alias leads to.';
+COMMENT ON COLUMN page_aliases.created_at IS 'When the alias was added.';
+
+CREATE INDEX idx_page_aliases_page_id ON page_aliases(page_id);
@@ -0,0 +1,13 @@
+-- Track when anything a page check shows last changed, so conditional checks can skip building the response
+ALTER TABLE pages ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+COMMENT ON COLUMN pages.updated_at IS 'When the page''s stats, metadata, or article signals last changed. Backs the ETag and Last-Modified of GET /api/v1/pages/check.';
+
+-- Backfill with the latest change we can still tell
+UPDATE pages p
+SET updated_at = GREATEST(
+    p.created_at,
+    p.metadata_updated_at,
+    p.fetched_at,
+    (SELECT MAX(r.updated_at) FROM ratings r WHERE r.page_id = p.id)
+);
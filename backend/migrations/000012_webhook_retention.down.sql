@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_delivered_at;
+
+COMMENT ON TABLE webhook_deliveries IS 'Outbox of webhook events, one row per event and subscription. Written in the same transaction as the rating change, so no event is lost, then sent by the webhook worker.';
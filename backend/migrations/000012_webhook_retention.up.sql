@@ -0,0 +1,4 @@
+-- Delivered events are deleted once they're older than WEBHOOKS_RETENTION, so find them without a scan
+CREATE INDEX idx_webhook_deliveries_delivered_at ON webhook_deliveries(delivered_at) WHERE status = 'delivered';
+
+COMMENT ON TABLE webhook_deliveries IS 'Outbox of webhook events, one row per event and subscription. Written in the same transaction as the rating change, so no event is lost, then sent by the webhook worker. Delivered ones are deleted after WEBHOOKS_RETENTION, dead ones stay for the dead-letter view.';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_url_redirects_resolved_at;
+
+COMMENT ON TABLE url_redirects IS 'Cache of where link wrappers like t.co redirect to, so each link is only requested once. Only used when URL_RESOLVE_REDIRECTS is on.';
@@ -0,0 +1,4 @@
+-- Redirects are deleted once they're older than URL_RESOLVE_TTL, so find them without a scan
+CREATE INDEX idx_url_redirects_resolved_at ON url_redirects(resolved_at);
+
+COMMENT ON TABLE url_redirects IS 'Cache of where link wrappers like t.co redirect to, so each link is only requested once. Only written when URL_RESOLVE_REDIRECTS is on, and only by writes: reads use what''s here without requests. Rows are deleted after URL_RESOLVE_TTL.';
@@ -0,0 +1,2 @@
+-- Nothing to undo: the backlog only changed when pages get fetched, and the fetcher works through it either way.
+SELECT 1;
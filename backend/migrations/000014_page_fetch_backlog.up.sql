@@ -0,0 +1,11 @@
+-- Pages that existed before the fetcher all became due at once when it was added. Rather than fetching them all on
+-- deploy, spread the ones it hasn't tried yet out to one per second, most rated first and unrated ones last.
+-- Pages created from now on are due right away, so they go ahead of this backlog.
+UPDATE pages p
+SET next_fetch_at = NOW() + backlog.position * INTERVAL '1 second'
+FROM (
+    SELECT id, ROW_NUMBER() OVER (ORDER BY rating_count DESC, id DESC) AS position
+    FROM pages
+    WHERE fetch_status = 'pending' AND fetch_attempts = 0
+) backlog
+WHERE p.id = backlog.id;
@@ -0,0 +1 @@
+COMMENT ON COLUMN pages.normalized_url IS 'Human-readable normalized URL (lowercase, https, no www, no trailing slash, no fragments, filtered query params).';
@@ -0,0 +1,4 @@
+-- URLs used to be lowercased whole when normalized. Now only the scheme and host are, since paths and query values
+-- can be case-sensitive. Pages stored before that keep their lowercased URL: looking one up by its new URL finds it
+-- by the lowercased URL's hash and adds the new URL as an alias.
+COMMENT ON COLUMN pages.normalized_url IS 'Human-readable normalized URL (https, lowercase host, no www, no trailing slash, no fragments, filtered query params). Pages from before paths and query values kept their case have it all lowercased.';
@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migration files so the server binary can apply them itself.
+// Files follow the golang-migrate naming scheme: {version}_{title}.up.sql and {version}_{title}.down.sql.
+package migrations
+
+import "embed"
+
+// FS holds every migration file in this directory.
+//
+//go:embed *.sql
+var FS embed.FS